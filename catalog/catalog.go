@@ -0,0 +1,439 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/rotation"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultReconcileInterval 默认的全量校对间隔
+const DefaultReconcileInterval = 5 * time.Minute
+
+// rotateWindow 文件被删除或重命名后在该时间内重新出现，视为轮转
+const rotateWindow = 10 * time.Second
+
+// EventType 文件事件类型
+type EventType string
+
+// 文件事件类型
+const (
+	EventCreated EventType = "created" // 新文件
+	EventRemoved EventType = "removed" // 文件被删除或移出目录
+	EventRotated EventType = "rotated" // 文件被轮转：同一路径换成了新文件，或被截断
+	EventChanged EventType = "changed" // 文件大小或修改时间变化
+)
+
+// Event 文件事件
+type Event struct {
+	Type EventType
+	File File
+}
+
+// File 目录中匹配的日志文件
+type File struct {
+	Path    string    // 文件路径，与遍历配置目录得到的路径一致
+	Root    string    // 所属的配置目录
+	Source  string    // 所属日志源名称
+	Size    int64     // 文件大小
+	ModTime time.Time // 修改时间
+}
+
+// Catalog 日志文件目录，启动时遍历一次，之后通过 fsnotify 递归监听保持最新
+// 监听可能丢失事件（如事件队列溢出、目录被移动），因此定期全量遍历进行校对
+type Catalog struct {
+	roots    []Root
+	pattern  *regexp.Regexp
+	rotation *rotation.Matcher
+	interval time.Duration
+
+	mu         sync.RWMutex
+	files      map[string]File
+	absPaths   map[string]string      // 绝对路径 -> 文件路径
+	identities map[string]os.FileInfo // 用于判断同一路径是否换成了新文件
+	removedAt  map[string]time.Time   // 最近被删除或重命名的文件
+	version    uint64
+
+	subMu       sync.Mutex
+	subscribers map[chan Event]struct{}
+	closed      bool
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New 创建文件目录，roots 为要扫描的目录，pattern 匹配未配置包含规则的目录中的文件名，interval 为全量校对间隔（<=0 使用默认值）
+// matcher 不为 nil 时，活动文件名匹配的轮转文件（如 app.log.1.gz）也加入目录
+func New(roots []Root, pattern *regexp.Regexp, matcher *rotation.Matcher, interval time.Duration) *Catalog {
+	if interval <= 0 {
+		interval = DefaultReconcileInterval
+	}
+	cleaned := make([]Root, len(roots))
+	for i, root := range roots {
+		cleaned[i] = root
+		cleaned[i].Path = filepath.Clean(root.Path)
+	}
+	return &Catalog{
+		roots:       cleaned,
+		pattern:     pattern,
+		rotation:    matcher,
+		interval:    interval,
+		files:       make(map[string]File),
+		absPaths:    make(map[string]string),
+		identities:  make(map[string]os.FileInfo),
+		removedAt:   make(map[string]time.Time),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Start 遍历所有目录建立初始目录并开始监听
+// 无法创建监听器时返回错误，此时目录仍可用，只依赖定期校对更新
+func (c *Catalog) Start() error {
+	c.done = make(chan struct{})
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.reconcile()
+		c.wg.Add(1)
+		go c.loop(nil)
+		return fmt.Errorf("创建文件监听失败: %w", err)
+	}
+	c.watcher = watcher
+	c.reconcile()
+
+	c.wg.Add(1)
+	go c.loop(watcher)
+	return nil
+}
+
+// Close 停止监听和定期校对，并关闭所有订阅的事件通道
+func (c *Catalog) Close() {
+	if c.done != nil {
+		close(c.done)
+		if c.watcher != nil {
+			c.watcher.Close()
+		}
+		c.wg.Wait()
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.closed = true
+	for ch := range c.subscribers {
+		close(ch)
+	}
+	c.subscribers = nil
+}
+
+// Subscribe 订阅文件事件，返回事件通道和取消订阅的函数
+// 订阅者处理不及时、通道已满时事件会被丢弃；目录关闭后通道被关闭
+func (c *Catalog) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if c.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	c.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.subMu.Lock()
+			defer c.subMu.Unlock()
+			if _, ok := c.subscribers[ch]; ok {
+				delete(c.subscribers, ch)
+				close(ch)
+			}
+		})
+	}
+}
+
+// emit 将事件发送给所有订阅者
+func (c *Catalog) emit(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for ch := range c.subscribers {
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
+
+// Files 返回按路径排序的文件列表和当前版本号，版本号在文件增删或变化时递增
+func (c *Catalog) Files() ([]File, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	files := make([]File, 0, len(c.files))
+	for _, f := range c.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, c.version
+}
+
+// Lookup 按绝对路径查找目录中的文件
+func (c *Catalog) Lookup(absPath string) (File, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	f, ok := c.files[c.absPaths[absPath]]
+	return f, ok
+}
+
+// Version 返回当前版本号
+func (c *Catalog) Version() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// loop 处理文件事件和定期校对
+func (c *Catalog) loop(watcher *fsnotify.Watcher) {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	var events chan fsnotify.Event
+	var errs chan error
+	if watcher != nil {
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.reconcile()
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			c.handle(event)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			// 事件队列溢出等错误会丢失事件，立即全量校对
+			fmt.Printf("文件监听出错，重新扫描目录: %v\n", err)
+			c.reconcile()
+		}
+	}
+}
+
+// handle 处理单个文件事件
+func (c *Catalog) handle(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+	root, rel, ok := c.rootOf(path)
+	if !ok {
+		return
+	}
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// 重命名后的新路径会收到 Create 事件
+		if c.watcher != nil {
+			c.watcher.Remove(path)
+		}
+		c.removeTree(path)
+		return
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		c.removeTree(path)
+		return
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		// 符号链接取目标的信息，目标不存在时忽略
+		if info, err = os.Stat(path); err != nil {
+			return
+		}
+		if !root.FollowSymlinks && (info.IsDir() || !Allows(c.roots, path)) {
+			return
+		}
+	}
+	if info.IsDir() {
+		if event.Has(fsnotify.Create) && root.enterDir(rel, info.Name()) {
+			c.addTree(root, path, rel)
+		}
+		return
+	}
+	if !info.Mode().IsRegular() {
+		return
+	}
+	if root.acceptFile(rel, filepath.Base(path), c.pattern, c.rotation) {
+		c.put(File{Path: path, Root: root.Path, Source: root.Source, Size: info.Size(), ModTime: info.ModTime()}, info)
+	}
+}
+
+// addTree 遍历新建的目录，添加监听和匹配的文件
+func (c *Catalog) addTree(root *Root, dir, rel string) {
+	w := c.newWalker(root)
+	w.walkDir(dir, rel)
+	for path, f := range w.found {
+		c.put(f, w.infos[path])
+	}
+}
+
+// newWalker 创建按根目录规则遍历的 walker，监听器可用时为遍历到的目录添加监听
+func (c *Catalog) newWalker(root *Root) *walker {
+	w := &walker{
+		root:     root,
+		roots:    c.roots,
+		pattern:  c.pattern,
+		rotation: c.rotation,
+		found:    make(map[string]File),
+		infos:    make(map[string]os.FileInfo),
+		visited:  make(map[string]bool),
+	}
+	if c.watcher != nil {
+		w.watch = func(dir string) {
+			if err := c.watcher.Add(dir); err != nil {
+				fmt.Printf("监听目录失败 %s: %v\n", dir, err)
+			}
+		}
+	}
+	return w
+}
+
+// reconcile 全量遍历所有目录，与当前目录对比后更新
+func (c *Catalog) reconcile() {
+	found := make(map[string]File)
+	infos := make(map[string]os.FileInfo)
+	for i := range c.roots {
+		w := c.newWalker(&c.roots[i])
+		w.walkDir(c.roots[i].Path, "")
+		// 多个目录包含同一文件时以先配置的目录为准
+		for path, f := range w.found {
+			if _, exists := found[path]; !exists {
+				found[path] = f
+				infos[path] = w.infos[path]
+			}
+		}
+	}
+
+	c.mu.Lock()
+	var events []Event
+	for path, f := range found {
+		if eventType := c.update(f, infos[path]); eventType != "" {
+			events = append(events, Event{Type: eventType, File: f})
+		}
+	}
+	for path, f := range c.files {
+		if _, ok := found[path]; !ok {
+			c.remove(path)
+			events = append(events, Event{Type: EventRemoved, File: f})
+		}
+	}
+	if len(events) > 0 {
+		c.version++
+	}
+	c.mu.Unlock()
+
+	c.emit(events)
+}
+
+// update 添加或更新文件，返回对应的事件类型，没有变化时返回空字符串，调用方需持有写锁
+func (c *Catalog) update(f File, info os.FileInfo) EventType {
+	old, exists := c.files[f.Path]
+	oldInfo := c.identities[f.Path]
+	c.files[f.Path] = f
+	c.identities[f.Path] = info
+
+	if !exists {
+		if abs, err := filepath.Abs(f.Path); err == nil {
+			c.absPaths[abs] = f.Path
+		}
+		removedAt, ok := c.removedAt[f.Path]
+		delete(c.removedAt, f.Path)
+		if ok && time.Since(removedAt) < rotateWindow {
+			return EventRotated
+		}
+		return EventCreated
+	}
+	if (oldInfo != nil && info != nil && !os.SameFile(oldInfo, info)) || f.Size < old.Size {
+		return EventRotated
+	}
+	if old != f {
+		return EventChanged
+	}
+	return ""
+}
+
+// remove 删除文件并记录删除时间，调用方需持有写锁
+func (c *Catalog) remove(path string) {
+	delete(c.files, path)
+	delete(c.identities, path)
+	if abs, err := filepath.Abs(path); err == nil {
+		delete(c.absPaths, abs)
+	}
+
+	now := time.Now()
+	for p, t := range c.removedAt {
+		if now.Sub(t) >= rotateWindow {
+			delete(c.removedAt, p)
+		}
+	}
+	c.removedAt[path] = now
+}
+
+// put 添加或更新文件，内容有变化时递增版本号并发送事件
+func (c *Catalog) put(f File, info os.FileInfo) {
+	c.mu.Lock()
+	eventType := c.update(f, info)
+	if eventType != "" {
+		c.version++
+	}
+	c.mu.Unlock()
+
+	if eventType != "" {
+		c.emit([]Event{{Type: eventType, File: f}})
+	}
+}
+
+// removeTree 删除文件，或删除目录下的所有文件
+func (c *Catalog) removeTree(path string) {
+	c.mu.Lock()
+	prefix := path + string(filepath.Separator)
+	var events []Event
+	for p, f := range c.files {
+		if p == path || strings.HasPrefix(p, prefix) {
+			c.remove(p)
+			events = append(events, Event{Type: EventRemoved, File: f})
+		}
+	}
+	if len(events) > 0 {
+		c.version++
+	}
+	c.mu.Unlock()
+
+	c.emit(events)
+}
+
+// rootOf 返回路径所属的配置目录和相对路径
+func (c *Catalog) rootOf(path string) (*Root, string, bool) {
+	for i := range c.roots {
+		root := &c.roots[i]
+		if rel, ok := root.relPath(path); ok {
+			return root, rel, true
+		}
+	}
+	return nil, "", false
+}
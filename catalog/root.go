@@ -0,0 +1,271 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/rotation"
+)
+
+// Root 要扫描的日志目录及其扫描规则
+type Root struct {
+	Path           string   // 目录路径
+	Source         string   // 所属日志源名称
+	Include        []string // 包含的文件，glob 匹配相对于目录的路径，为空时使用全局文件名正则
+	Exclude        []string // 排除的文件或目录，glob 匹配相对于目录的路径
+	MaxDepth       int      // 最大深度，1 表示只扫描目录本身的文件，0 表示不限制
+	FollowSymlinks bool     // 是否进入符号链接指向的目录，指向目录外文件的符号链接也只在开启时包含
+	SkipHidden     bool     // 是否跳过以 . 开头的文件和目录
+}
+
+// CheckGlob 校验 glob 模式，语法同 path.Match，** 匹配任意层目录
+func CheckGlob(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("glob 模式不能为空")
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("无效的 glob 模式 %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchGlob 判断相对路径（以 / 分隔）是否匹配 glob 模式
+func matchGlob(pattern, rel string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments 逐段匹配，** 匹配零个或多个目录
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// matchAny 判断相对路径是否匹配任意一个 glob 模式
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// depth 返回相对路径的层数，目录本身的文件为 1
+func depth(rel string) int {
+	return strings.Count(rel, "/") + 1
+}
+
+// enterDir 判断是否扫描子目录，rel 为子目录相对于根目录的路径
+func (r *Root) enterDir(rel, name string) bool {
+	if r.SkipHidden && strings.HasPrefix(name, ".") {
+		return false
+	}
+	if r.MaxDepth > 0 && depth(rel) >= r.MaxDepth {
+		return false
+	}
+	return !matchAny(r.Exclude, rel)
+}
+
+// acceptFile 判断文件是否加入目录，rel 为文件相对于根目录的路径
+// 文件本身不匹配时，如果是轮转文件则按其活动文件名判断
+func (r *Root) acceptFile(rel, name string, pattern *regexp.Regexp, matcher *rotation.Matcher) bool {
+	if r.SkipHidden && strings.HasPrefix(name, ".") {
+		return false
+	}
+	if r.MaxDepth > 0 && depth(rel) > r.MaxDepth {
+		return false
+	}
+	if matchAny(r.Exclude, rel) {
+		return false
+	}
+	if r.matchName(rel, name, pattern) {
+		return true
+	}
+	if m := matcher.Parse(name, time.Time{}); !m.Active() {
+		baseRel := m.Base
+		if dir := path.Dir(rel); dir != "." {
+			baseRel = dir + "/" + m.Base
+		}
+		return !matchAny(r.Exclude, baseRel) && r.matchName(baseRel, m.Base, pattern)
+	}
+	return false
+}
+
+// matchName 按包含规则或文件名正则判断文件是否匹配
+func (r *Root) matchName(rel, name string, pattern *regexp.Regexp) bool {
+	if len(r.Include) > 0 {
+		return matchAny(r.Include, rel)
+	}
+	return pattern.MatchString(name)
+}
+
+// relPath 返回路径相对于根目录的路径（以 / 分隔），不在根目录内时返回 false
+func (r *Root) relPath(p string) (string, bool) {
+	rel, err := filepath.Rel(r.Path, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// within 判断绝对路径是否位于目录内（不含目录本身），按路径分段比较，/logs2 不在 /logs 内
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realDir 返回目录解析符号链接后的绝对路径
+func realDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// Allows 判断路径是否允许访问：路径位于某个目录内，且解析符号链接后的真实路径也位于某个目录内，
+// 防止通过 .. 或指向目录外的符号链接访问其他文件；所在目录开启了跟随符号链接时不检查真实路径
+func Allows(roots []Root, p string) bool {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	contained := false
+	for _, root := range roots {
+		rootAbs, err := filepath.Abs(root.Path)
+		if err != nil || !within(rootAbs, abs) {
+			continue
+		}
+		if root.FollowSymlinks {
+			return true
+		}
+		contained = true
+	}
+	if !contained {
+		return false
+	}
+
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return false
+	}
+	for _, root := range roots {
+		if rootReal, err := realDir(root.Path); err == nil && within(rootReal, real) {
+			return true
+		}
+	}
+	return false
+}
+
+// walker 按根目录规则遍历目录
+type walker struct {
+	root     *Root
+	roots    []Root // 所有目录，用于检查符号链接是否指向目录外
+	pattern  *regexp.Regexp
+	rotation *rotation.Matcher
+	watch    func(dir string) // 为遍历到的目录添加监听，可以为 nil
+	found    map[string]File
+	infos    map[string]os.FileInfo
+	visited  map[string]bool // 已遍历目录的真实路径，跟随符号链接时防止循环
+}
+
+// walkDir 遍历目录，rel 为目录相对于根目录的路径，根目录为空字符串
+func (w *walker) walkDir(dir, rel string) {
+	if w.root.FollowSymlinks {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			fmt.Printf("解析目录路径失败 %s: %v\n", dir, err)
+			return
+		}
+		if w.visited[real] {
+			return
+		}
+		w.visited[real] = true
+	}
+	if w.watch != nil {
+		w.watch(dir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Printf("访问路径错误 %s: %v\n", dir, err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		p := filepath.Join(dir, name)
+		childRel := name
+		if rel != "" {
+			childRel = rel + "/" + name
+		}
+
+		isDir := entry.IsDir()
+		var info os.FileInfo
+		if entry.Type()&os.ModeSymlink != 0 {
+			// 符号链接取目标的信息，目标不存在时跳过；未开启跟随符号链接时跳过目录和指向目录外的文件
+			if info, err = os.Stat(p); err != nil {
+				continue
+			}
+			isDir = info.IsDir()
+			if !w.root.FollowSymlinks && (isDir || !Allows(w.roots, p)) {
+				continue
+			}
+		}
+
+		if isDir {
+			if w.root.enterDir(childRel, name) {
+				w.walkDir(p, childRel)
+			}
+			continue
+		}
+		// 跳过管道、设备等特殊文件，读取时可能阻塞
+		if (info != nil && !info.Mode().IsRegular()) || (info == nil && !entry.Type().IsRegular()) {
+			continue
+		}
+		if !w.root.acceptFile(childRel, name, w.pattern, w.rotation) {
+			continue
+		}
+		if info == nil {
+			if info, err = entry.Info(); err != nil {
+				continue
+			}
+		}
+		w.found[p] = File{Path: p, Root: w.root.Path, Source: w.root.Source, Size: info.Size(), ModTime: info.ModTime()}
+		w.infos[p] = info
+	}
+}
+
+// Scan 不监听文件变化，遍历一次所有目录返回按路径排序的文件列表
+func Scan(roots []Root, pattern *regexp.Regexp, matcher *rotation.Matcher) []File {
+	c := New(roots, pattern, matcher, 0)
+	c.reconcile()
+	files, _ := c.Files()
+	return files
+}
@@ -0,0 +1,124 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/anjude/log-tools/rotation"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.log", "app.log", true},
+		{"*.log", "sub/app.log", false},
+		{"sub/*.log", "sub/app.log", true},
+		{"**/*.log", "app.log", true},
+		{"**/*.log", "a/b/c/app.log", true},
+		{"**/*.log", "a/b/app.txt", false},
+		{"archive/**", "archive", true},
+		{"archive/**", "archive/2026/10/app.log", true},
+		{"a/**/b/*.log", "a/b/x.log", true},
+		{"a/**/b/*.log", "a/x/y/b/x.log", true},
+		{"a/**/b/*.log", "a/x/y/c/x.log", false},
+		{"app-?.log", "app-1.log", true},
+		{"app-[0-9].log", "app-x.log", false},
+		{"tmp", "tmp/app.log", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ok      bool
+	}{
+		{"**/*.log", true},
+		{"logs/[a-z]*.log", true},
+		{"", false},
+		{"logs/[a-.log", false},
+	}
+	for _, tt := range tests {
+		if err := CheckGlob(tt.pattern); (err == nil) != tt.ok {
+			t.Errorf("CheckGlob(%q) error = %v", tt.pattern, err)
+		}
+	}
+}
+
+func TestAcceptFile(t *testing.T) {
+	pattern := regexp.MustCompile(`\.log$`)
+	matcher := rotation.NewMatcher(rotation.DefaultRules())
+	tests := []struct {
+		name string
+		root Root
+		rel  string
+		want bool
+	}{
+		{"pattern", Root{}, "app.log", true},
+		{"pattern miss", Root{}, "app.txt", false},
+		{"rotated", Root{}, "app.log.1", true},
+		{"hidden", Root{SkipHidden: true}, ".app.log", false},
+		{"depth", Root{MaxDepth: 1}, "sub/app.log", false},
+		{"exclude", Root{Exclude: []string{"**/debug.log"}}, "sub/debug.log", false},
+		{"exclude rotated", Root{Exclude: []string{"debug.log"}}, "debug.log.2", false},
+		{"include", Root{Include: []string{"**/*.out"}}, "sub/app.out", true},
+		{"include miss", Root{Include: []string{"**/*.out"}}, "app.log", false},
+	}
+	for _, tt := range tests {
+		if got := tt.root.acceptFile(tt.rel, filepath.Base(tt.rel), pattern, matcher); got != tt.want {
+			t.Errorf("%s: acceptFile(%q) = %v, want %v", tt.name, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		dir, p string
+		want   bool
+	}{
+		{"/logs", "/logs/app.log", true},
+		{"/logs", "/logs", false},
+		{"/logs", "/logs2/app.log", false},
+		{"/logs", "/logs/../etc/passwd", false},
+	}
+	for _, tt := range tests {
+		if got := within(tt.dir, filepath.Clean(tt.p)); got != tt.want {
+			t.Errorf("within(%q, %q) = %v", tt.dir, tt.p, got)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	root := t.TempDir()
+	logs := filepath.Join(root, "logs")
+	os.MkdirAll(logs, 0755)
+	os.WriteFile(filepath.Join(root, "secret"), nil, 0644)
+	os.WriteFile(filepath.Join(logs, "app.log"), nil, 0644)
+	os.Symlink(filepath.Join(root, "secret"), filepath.Join(logs, "link.log"))
+
+	roots := []Root{{Path: logs}}
+	tests := []struct {
+		p    string
+		want bool
+	}{
+		{filepath.Join(logs, "app.log"), true},
+		{filepath.Join(logs, "..", "secret"), false},
+		{filepath.Join(logs, "link.log"), false},
+	}
+	for _, tt := range tests {
+		if got := Allows(roots, tt.p); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if !Allows([]Root{{Path: logs, FollowSymlinks: true}}, filepath.Join(logs, "link.log")) {
+		t.Error("symlink not allowed with FollowSymlinks")
+	}
+}
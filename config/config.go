@@ -4,17 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/rotation"
 	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 // Config 配置结构体
 type Config struct {
-	Server ServerConfig `mapstructure:"server"`
-	Auth   AuthConfig   `mapstructure:"auth"`
-	Logs   LogsConfig   `mapstructure:"logs"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Logs      LogsConfig      `mapstructure:"logs"`
+	Redaction RedactionConfig `mapstructure:"redaction"`
 }
 
 // ServerConfig 服务器配置
@@ -25,22 +33,225 @@ type ServerConfig struct {
 
 // AuthConfig 认证配置
 type AuthConfig struct {
+	Username string       `mapstructure:"username"`
+	Password string       `mapstructure:"password"`
+	Users    []UserConfig `mapstructure:"users"` // 额外的登录用户
+}
+
+// UserConfig 登录用户
+type UserConfig struct {
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
 }
 
+// RedactionConfig 敏感信息脱敏配置，对所有返回日志内容的接口生效
+type RedactionConfig struct {
+	Enabled     bool            `mapstructure:"enabled"`
+	Detectors   []string        `mapstructure:"detectors"`    // 启用的内置检测器，为空表示全部启用
+	Rules       []RedactionRule `mapstructure:"rules"`        // 自定义脱敏规则
+	ExemptUsers []string        `mapstructure:"exempt_users"` // 不脱敏的特权用户
+	AuditFile   string          `mapstructure:"audit_file"`   // 脱敏审计日志文件，为空时只输出到控制台
+}
+
+// RedactionRule 自定义脱敏规则
+type RedactionRule struct {
+	Name        string `mapstructure:"name"`
+	Regex       string `mapstructure:"regex"`       // 正则表达式，包含名为 secret 的分组时只替换该分组
+	Replacement string `mapstructure:"replacement"` // 替换内容，支持 $1 引用分组，默认 ***
+}
+
+// CheckUser 校验用户名和密码
+func (a AuthConfig) CheckUser(username, password string) bool {
+	if a.Username != "" && username == a.Username && password == a.Password {
+		return true
+	}
+	for _, user := range a.Users {
+		if user.Username != "" && username == user.Username && password == user.Password {
+			return true
+		}
+	}
+	return false
+}
+
 // LogsConfig 日志配置
 type LogsConfig struct {
-	Directories      []string `mapstructure:"directories"` // 支持多个日志目录
-	Directory        string   `mapstructure:"directory"`   // 兼容旧版本，单个目录
-	FixedFiles       []string `mapstructure:"fixed_files"` // 确定的日志文件路径列表
-	Pattern          string   `mapstructure:"pattern"`
-	DefaultLines     int      `mapstructure:"default_lines"`
-	MaxSearchResults int      `mapstructure:"max_search_results"`
+	Directories      []DirectoryConfig   `mapstructure:"directories"` // 支持多个日志目录，每项可以是路径字符串或带扫描规则的对象
+	Directory        string              `mapstructure:"directory"`   // 兼容旧版本，单个目录
+	FixedFiles       []string            `mapstructure:"fixed_files"` // 确定的日志文件路径列表
+	Pattern          string              `mapstructure:"pattern"`
+	DefaultLines     int                 `mapstructure:"default_lines"`
+	MaxSearchResults int                 `mapstructure:"max_search_results"`
+	Parsers          []ParserRule        `mapstructure:"parsers"`           // 日志格式解析器分配规则，按顺序匹配
+	Formats          []FormatConfig      `mapstructure:"formats"`           // 自定义日志格式
+	GrokPatterns     map[string]string   `mapstructure:"grok_patterns"`     // 自定义grok模式，可在 formats 中引用
+	Query            QueryConfig         `mapstructure:"query"`             // 查询语言的执行限制
+	TraceFields      map[string][]string `mapstructure:"trace_fields"`      // 各日志格式中的请求/链路ID字段，键为格式名称，default 适用于所有格式
+	TransformPresets map[string][]string `mapstructure:"transform_presets"` // 行转换预设，键为预设名称
+	Journal          JournalConfig       `mapstructure:"journal"`           // systemd journal 日志源
+	Sources          []SourceConfig      `mapstructure:"sources"`           // 命名日志源，directories 和 fixed_files 作为名为 default 的日志源
+	Rotation         RotationConfig      `mapstructure:"rotation"`          // 轮转文件分组
+	Freshness        string              `mapstructure:"freshness"`         // default 日志源的新鲜度要求，写法同 sources 中的 freshness
+	// 文件目录全量校对间隔（秒），文件变化通过 fsnotify 实时更新，校对用于修正丢失的事件，默认300秒
+	ReconcileInterval int `mapstructure:"reconcile_interval"`
+}
+
+// 内置日志源名称
+const (
+	DefaultSourceName = "default" // directories 和 fixed_files 所在的日志源
+	JournalSourceName = "journal" // systemd journal 虚拟日志源
+)
+
+// SourceConfig 命名日志源，由一组目录和确定的文件组成
+type SourceConfig struct {
+	Name        string            `mapstructure:"name"`         // 唯一名称，用于筛选
+	DisplayName string            `mapstructure:"display_name"` // 显示名称，默认同 name
+	Description string            `mapstructure:"description"`  // 描述
+	Labels      map[string]string `mapstructure:"labels"`       // 标签，如 env: prod，标签名会被转为小写
+	Directories []DirectoryConfig `mapstructure:"directories"`  // 日志目录，写法同 logs.directories
+	Files       []string          `mapstructure:"files"`        // 确定的日志文件路径，显示在文件列表最前面
+	Parser      string            `mapstructure:"parser"`       // 解析器名称，优先于 parsers 规则
+	Encoding    string            `mapstructure:"encoding"`     // 文件编码，如 gbk、gb18030、big5、shift_jis，默认 utf-8
+	Retention   string            `mapstructure:"retention"`    // 保留时长，如 7d、12h，修改时间更早的文件不再显示，为空表示不限制
+	Freshness   string            `mapstructure:"freshness"`    // 新鲜度要求，如 5m、1h，超过该时长没有任何文件写入时视为停止写入，为空表示不检查
+}
+
+// Title 返回日志源的显示名称
+func (s SourceConfig) Title() string {
+	if s.DisplayName != "" {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+// RetentionDuration 解析保留时长，支持 Go 时长格式和以 d 结尾的天数，未配置时返回 0
+func (s SourceConfig) RetentionDuration() (time.Duration, error) {
+	d, err := parseDays(s.Retention)
+	if err != nil {
+		return 0, fmt.Errorf("无效的保留时长: %s", s.Retention)
+	}
+	return d, nil
+}
+
+// FreshnessDuration 解析新鲜度要求，格式同保留时长，未配置时返回 0
+func (s SourceConfig) FreshnessDuration() (time.Duration, error) {
+	d, err := parseDays(s.Freshness)
+	if err != nil {
+		return 0, fmt.Errorf("无效的新鲜度要求: %s", s.Freshness)
+	}
+	return d, nil
+}
+
+// parseDays 解析 Go 时长格式和以 d 结尾的天数，为空时返回 0
+func parseDays(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("无效的时长: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("无效的时长: %s", value)
+	}
+	return d, nil
+}
+
+// DirectoryConfig 日志目录及其扫描规则，配置为字符串时只设置路径，其余使用默认值
+type DirectoryConfig struct {
+	Path           string   `mapstructure:"path"`            // 目录路径
+	Include        []string `mapstructure:"include"`         // 包含的文件，glob 匹配相对于目录的路径（** 匹配任意层目录），为空时使用 pattern 匹配文件名
+	Exclude        []string `mapstructure:"exclude"`         // 排除的文件或目录，glob 匹配相对于目录的路径
+	MaxDepth       int      `mapstructure:"max_depth"`       // 最大深度，1 表示只扫描目录本身的文件，0 表示不限制
+	FollowSymlinks bool     `mapstructure:"follow_symlinks"` // 是否跟随符号链接，不跟随时只包含目标位于日志目录内的文件符号链接
+	SkipHidden     bool     `mapstructure:"skip_hidden"`     // 是否跳过以 . 开头的文件和目录
+}
+
+// JournalConfig systemd journal 配置，journal 中的各单元以 journal://<单元> 虚拟日志源出现在文件列表中
+type JournalConfig struct {
+	Directories []string `mapstructure:"directories"` // journal 文件目录，如 /var/log/journal，为空表示不读取
+	CacheDir    string   `mapstructure:"cache_dir"`   // 转换后文本的缓存目录，默认为系统临时目录下的 log-tools-journal
+}
+
+// RotationConfig 轮转文件分组配置，同一文件轮转产生的文件（app.log.1、app.log-20261017.gz 等）
+// 在文件列表中合并为一项，读取和搜索时按时间顺序连接
+type RotationConfig struct {
+	Disabled   bool                 `mapstructure:"disabled"`     // 关闭分组，轮转文件作为独立的文件显示
+	Rules      []RotationRuleConfig `mapstructure:"rules"`        // 自定义规则，优先于内置规则
+	CacheDir   string               `mapstructure:"cache_dir"`    // 合并后内容的缓存目录，默认为系统临时目录下的 log-tools-rotation
+	CacheMaxMB int                  `mapstructure:"cache_max_mb"` // 缓存目录的大小上限（MB），超过时删除最久未使用的缓存，默认 1024，负数表示不限制
+}
+
+// RotationRuleConfig 轮转文件命名规则
+type RotationRuleConfig struct {
+	Name       string `mapstructure:"name"`
+	Regex      string `mapstructure:"regex"`       // 匹配去掉压缩后缀的文件名，命名分组 base（可加 ext）组成活动文件名，index（越大越旧）或 date 决定先后
+	DateLayout string `mapstructure:"date_layout"` // date 分组的时间格式（Go layout），默认识别 20060102 和 2006010215
+}
+
+// Matcher 根据配置创建轮转文件匹配器，关闭分组时返回 nil
+func (r RotationConfig) Matcher() (*rotation.Matcher, error) {
+	if r.Disabled {
+		return nil, nil
+	}
+	var rules []rotation.Rule
+	for i, rule := range r.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rules[%d]", i)
+		}
+		compiled, err := rotation.CompileRule(name, rule.Regex, rule.DateLayout)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, compiled)
+	}
+	return rotation.NewMatcher(append(rules, rotation.DefaultRules()...)), nil
+}
+
+// QueryConfig 查询语言的执行限制，未配置时使用默认值
+type QueryConfig struct {
+	MaxScanLines  int64 `mapstructure:"max_scan_lines"`  // 单次查询最多扫描的行数
+	MaxGroups     int   `mapstructure:"max_groups"`      // GROUP BY 最多的分组数
+	MaxResultRows int   `mapstructure:"max_result_rows"` // 最多返回的行数
+	Timeout       int   `mapstructure:"timeout"`         // 执行超时（秒）
+}
+
+// FormatConfig 自定义日志格式，grok 和 regex 任选其一
+type FormatConfig struct {
+	Name            string `mapstructure:"name" json:"name"`                                   // 格式名称，在 parsers 规则中引用
+	Grok            string `mapstructure:"grok" json:"grok,omitempty"`                         // grok风格模式
+	Regex           string `mapstructure:"regex" json:"regex,omitempty"`                       // Go正则，使用命名捕获组
+	TimestampField  string `mapstructure:"timestamp_field" json:"timestamp_field,omitempty"`   // 时间字段名
+	TimestampLayout string `mapstructure:"timestamp_layout" json:"timestamp_layout,omitempty"` // 时间格式（Go layout，或 unix、unix_ms）
+	LevelField      string `mapstructure:"level_field" json:"level_field,omitempty"`           // 级别字段名，默认 level
+	MessageField    string `mapstructure:"message_field" json:"message_field,omitempty"`       // 消息字段名，默认 message
+}
+
+// ParserRule 日志格式解析器分配规则，Directory、File、Pattern 任选其一
+type ParserRule struct {
+	Directory string `mapstructure:"directory" json:"directory,omitempty"` // 目录下的所有文件
+	File      string `mapstructure:"file" json:"file,omitempty"`           // 指定文件路径
+	Pattern   string `mapstructure:"pattern" json:"pattern,omitempty"`     // 文件名正则匹配
+	Parser    string `mapstructure:"parser" json:"parser"`                 // 解析器名称，auto 表示自动检测
 }
 
 var globalConfig *Config
 
+// reloadHooks 配置加载和重新加载后执行的回调
+var reloadHooks []func(*Config)
+
+// OnReload 注册配置重新加载后的回调，注册时会立即以当前配置执行一次
+func OnReload(fn func(*Config)) {
+	reloadHooks = append(reloadHooks, fn)
+	if globalConfig != nil {
+		fn(globalConfig)
+	}
+}
+
 // LoadConfig 加载配置文件
 func LoadConfig() (*Config, error) {
 	if globalConfig != nil {
@@ -60,7 +271,7 @@ func LoadConfig() (*Config, error) {
 
 	// 解析配置
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := unmarshalConfig(&config); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
 
@@ -74,13 +285,14 @@ func LoadConfig() (*Config, error) {
 	fmt.Printf("  服务器: %s:%s\n", config.Server.Host, config.Server.Port)
 
 	// 显示日志目录信息
-	if len(config.Logs.Directories) > 0 {
-		fmt.Printf("  日志目录: %v\n", config.Logs.Directories)
-	} else if config.Logs.Directory != "" {
-		fmt.Printf("  日志目录: %s\n", config.Logs.Directory)
+	if len(config.Logs.Directories) > 0 || config.Logs.Directory != "" || len(config.Logs.Sources) > 0 {
+		fmt.Printf("  日志目录: %v\n", config.LogDirectories())
 	} else {
 		fmt.Printf("  日志目录: ./logs (默认)\n")
 	}
+	for _, source := range config.Logs.Sources {
+		fmt.Printf("  日志源: %s (%s) 标签=%v\n", source.Name, source.Title(), source.Labels)
+	}
 
 	// 显示固定文件路径信息
 	if len(config.Logs.FixedFiles) > 0 {
@@ -96,9 +308,12 @@ func LoadConfig() (*Config, error) {
 		fmt.Printf("配置文件已更改: %s\n", e.Name)
 		// 重新加载配置
 		if err := viper.ReadInConfig(); err == nil {
-			if err := viper.Unmarshal(&config); err == nil {
+			if err := unmarshalConfig(&config); err == nil {
 				globalConfig = &config
 				fmt.Println("配置已重新加载")
+				for _, fn := range reloadHooks {
+					fn(globalConfig)
+				}
 			}
 		}
 	})
@@ -107,33 +322,109 @@ func LoadConfig() (*Config, error) {
 	return globalConfig, nil
 }
 
+// unmarshalConfig 解析配置，日志目录支持字符串和对象两种写法
+func unmarshalConfig(config *Config) error {
+	return viper.Unmarshal(config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		stringToDirectoryHook,
+	)))
+}
+
+// stringToDirectoryHook 将字符串形式的日志目录转换为 DirectoryConfig，兼容旧版本配置
+func stringToDirectoryHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(DirectoryConfig{}) {
+		return data, nil
+	}
+	return DirectoryConfig{Path: data.(string)}, nil
+}
+
 // GetConfig 获取全局配置
 func GetConfig() *Config {
 	return globalConfig
 }
 
+// SetConfig 直接设置全局配置，不读取配置文件也不执行重新加载回调，用于测试
+func SetConfig(config *Config) {
+	globalConfig = config
+}
+
 // validateConfig 验证配置
 func validateConfig(config *Config) error {
-	// 检查日志目录
-	var directories []string
-	if len(config.Logs.Directories) > 0 {
-		directories = config.Logs.Directories
-	} else if config.Logs.Directory != "" {
-		directories = []string{config.Logs.Directory}
-	} else {
-		directories = []string{"./logs"}
+	// 检查日志源
+	names := make(map[string]bool)
+	for _, source := range config.rawSources() {
+		if source.Name == "" {
+			return fmt.Errorf("日志源缺少 name")
+		}
+		if names[source.Name] || source.Name == JournalSourceName {
+			return fmt.Errorf("日志源名称重复: %s", source.Name)
+		}
+		names[source.Name] = true
+		if len(source.Directories) == 0 && len(source.Files) == 0 {
+			return fmt.Errorf("日志源 %s 没有配置 directories 或 files", source.Name)
+		}
+		if _, err := source.RetentionDuration(); err != nil {
+			return fmt.Errorf("日志源 %s: %w", source.Name, err)
+		}
+		if _, err := source.FreshnessDuration(); err != nil {
+			return fmt.Errorf("日志源 %s: %w", source.Name, err)
+		}
+		if source.Encoding != "" {
+			if _, err := htmlindex.Get(source.Encoding); err != nil {
+				return fmt.Errorf("日志源 %s 的编码不支持: %s", source.Name, source.Encoding)
+			}
+		}
+		for _, file := range source.Files {
+			if _, err := ExpandPath(file, time.Now()); err != nil {
+				return fmt.Errorf("日志源 %s: %w", source.Name, err)
+			}
+		}
+	}
+
+	// 检查日志目录的扫描规则
+	for _, source := range config.rawSources() {
+		for _, dir := range source.Directories {
+			if _, err := ExpandPath(dir.Path, time.Now()); err != nil {
+				return fmt.Errorf("日志源 %s: %w", source.Name, err)
+			}
+		}
+	}
+	for _, dir := range config.DirectoryConfigs() {
+		if dir.Path == "" {
+			return fmt.Errorf("日志目录缺少 path")
+		}
+		if dir.MaxDepth < 0 {
+			return fmt.Errorf("日志目录 %s 的 max_depth 不能为负数", dir.Path)
+		}
+		for _, pattern := range append(append([]string{}, dir.Include...), dir.Exclude...) {
+			if err := catalog.CheckGlob(pattern); err != nil {
+				return fmt.Errorf("日志目录 %s: %w", dir.Path, err)
+			}
+		}
+	}
+
+	// 检查轮转文件规则
+	if _, err := config.Logs.Rotation.Matcher(); err != nil {
+		return err
 	}
 
-	// 验证所有目录
-	for _, dir := range directories {
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			// 如果是相对路径，尝试创建目录
-			if !filepath.IsAbs(dir) {
-				if err := os.MkdirAll(dir, 0755); err != nil {
-					return fmt.Errorf("无法创建日志目录: %s, 错误: %w", dir, err)
+	// 验证所有目录，使用路径模板的目录（如按日期创建的目录）可能尚未创建，不做检查
+	for _, source := range config.rawSources() {
+		for _, dirConfig := range source.Directories {
+			if IsPathTemplate(dirConfig.Path) {
+				continue
+			}
+			dir := dirConfig.Path
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				// 如果是相对路径，尝试创建目录
+				if !filepath.IsAbs(dir) {
+					if err := os.MkdirAll(dir, 0755); err != nil {
+						return fmt.Errorf("无法创建日志目录: %s, 错误: %w", dir, err)
+					}
+				} else {
+					return fmt.Errorf("日志目录不存在: %s", dir)
 				}
-			} else {
-				return fmt.Errorf("日志目录不存在: %s", dir)
 			}
 		}
 	}
@@ -161,63 +452,55 @@ func validateConfig(config *Config) error {
 	return nil
 }
 
-// GetLogFiles 获取匹配的日志文件列表
-func (c *Config) GetLogFiles() ([]string, error) {
-	var files []string
+// LogDirectories 返回要扫描的日志目录路径列表
+func (c *Config) LogDirectories() []string {
+	dirs := c.DirectoryConfigs()
+	paths := make([]string, len(dirs))
+	for i, dir := range dirs {
+		paths[i] = dir.Path
+	}
+	return paths
+}
+
+// DirectoryConfigs 返回所有日志源的目录及其扫描规则
+func (c *Config) DirectoryConfigs() []DirectoryConfig {
+	var dirs []DirectoryConfig
+	for _, source := range c.LogSources() {
+		dirs = append(dirs, source.Directories...)
+	}
+	return dirs
+}
+
+// LogSources 返回所有日志源，目录和确定文件中的路径模板按当前时间展开
+// 未配置 sources 时，directories（默认 ./logs）和 fixed_files 组成名为 default 的日志源；
+// 配置了 sources 时，只有显式配置了 directories、directory 或 fixed_files 才会加入 default 日志源
+func (c *Config) LogSources() []SourceConfig {
+	return expandSources(c.rawSources(), time.Now())
+}
 
-	// 确定要扫描的目录列表
-	var directories []string
+// rawSources 返回配置中的日志源，路径模板未展开
+func (c *Config) rawSources() []SourceConfig {
+	var dirs []DirectoryConfig
 	if len(c.Logs.Directories) > 0 {
 		// 使用新的多目录配置
-		directories = c.Logs.Directories
+		dirs = c.Logs.Directories
 	} else if c.Logs.Directory != "" {
 		// 兼容旧版本，使用单个目录
-		directories = []string{c.Logs.Directory}
-	} else {
+		dirs = []DirectoryConfig{{Path: c.Logs.Directory}}
+	} else if len(c.Logs.Sources) == 0 {
 		// 默认使用当前目录下的logs文件夹
-		directories = []string{"./logs"}
-	}
-
-	fmt.Printf("开始扫描目录: %v\n", directories)
-	fmt.Printf("使用模式: %s\n", c.Logs.Pattern)
-
-	// 编译正则表达式
-	pattern, err := regexp.Compile(c.Logs.Pattern)
-	if err != nil {
-		return nil, fmt.Errorf("正则表达式编译失败: %w", err)
+		dirs = []DirectoryConfig{{Path: "./logs"}}
 	}
 
-	// 遍历所有目录
-	for _, dir := range directories {
-		fmt.Printf("扫描目录: %s\n", dir)
-
-		err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				fmt.Printf("访问路径错误 %s: %v\n", path, err)
-				return err
-			}
-
-			if !info.IsDir() {
-				fmt.Printf("检查文件: %s (名称: %s)\n", path, info.Name())
-
-				matched := pattern.MatchString(info.Name())
-				fmt.Printf("文件 %s 匹配结果: %v\n", info.Name(), matched)
-
-				if matched {
-					files = append(files, path)
-					fmt.Printf("添加文件: %s\n", path)
-				}
-			}
-
-			return nil
+	var sources []SourceConfig
+	if len(dirs) > 0 || len(c.Logs.FixedFiles) > 0 {
+		sources = append(sources, SourceConfig{
+			Name:        DefaultSourceName,
+			DisplayName: "日志目录",
+			Directories: dirs,
+			Files:       c.Logs.FixedFiles,
+			Freshness:   c.Logs.Freshness,
 		})
-
-		if err != nil {
-			fmt.Printf("扫描目录 %s 时出错: %v\n", dir, err)
-			// 继续扫描其他目录，不中断
-		}
 	}
-
-	fmt.Printf("扫描完成，找到 %d 个文件\n", len(files))
-	return files, err
+	return append(sources, c.Logs.Sources...)
 }
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultDateLayout 路径模板中 date 和 yesterday 未指定格式时使用的日期格式
+const defaultDateLayout = "2006-01-02"
+
+// 已解析的路径模板，按模板内容缓存
+var (
+	pathTemplates   = make(map[string]*template.Template)
+	pathTemplatesMu sync.Mutex
+)
+
+// IsPathTemplate 判断路径是否包含模板变量
+func IsPathTemplate(p string) bool {
+	return strings.Contains(p, "{{")
+}
+
+// parsePathTemplate 解析路径模板，date、yesterday 按执行时的时间求值
+// 支持 {{date "2006-01-02"}}、{{yesterday}}、{{yesterday "20060102"}}、{{hostname}}
+func parsePathTemplate(p string) (*template.Template, error) {
+	pathTemplatesMu.Lock()
+	defer pathTemplatesMu.Unlock()
+	if tmpl, ok := pathTemplates[p]; ok {
+		return tmpl, nil
+	}
+
+	// 函数在解析时只用于校验名称和参数，执行时通过 Funcs 替换为绑定当前时间的版本
+	tmpl, err := template.New("path").Funcs(pathTemplateFuncs(time.Now())).Parse(p)
+	if err != nil {
+		return nil, fmt.Errorf("路径模板错误 %q: %w", p, err)
+	}
+	pathTemplates[p] = tmpl
+	return tmpl, nil
+}
+
+// pathTemplateFuncs 返回路径模板可用的函数
+func pathTemplateFuncs(now time.Time) template.FuncMap {
+	layout := func(layouts []string) string {
+		if len(layouts) > 0 && layouts[0] != "" {
+			return layouts[0]
+		}
+		return defaultDateLayout
+	}
+	return template.FuncMap{
+		"date": func(layouts ...string) string {
+			return now.Format(layout(layouts))
+		},
+		"yesterday": func(layouts ...string) string {
+			return now.AddDate(0, 0, -1).Format(layout(layouts))
+		},
+		"hostname": func() (string, error) {
+			return os.Hostname()
+		},
+	}
+}
+
+// ExpandPath 以指定时间展开路径模板，不含模板变量的路径原样返回
+func ExpandPath(p string, now time.Time) (string, error) {
+	if !IsPathTemplate(p) {
+		return p, nil
+	}
+	tmpl, err := parsePathTemplate(p)
+	if err != nil {
+		return "", err
+	}
+	// Clone 后替换函数，避免并发执行时互相影响
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := clone.Funcs(pathTemplateFuncs(now)).Execute(&b, nil); err != nil {
+		return "", fmt.Errorf("路径模板执行失败 %q: %w", p, err)
+	}
+	return b.String(), nil
+}
+
+// expandSources 以指定时间展开日志源中目录和确定文件的路径模板，展开失败时保留原路径
+func expandSources(sources []SourceConfig, now time.Time) []SourceConfig {
+	expand := func(p string) string {
+		expanded, err := ExpandPath(p, now)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return p
+		}
+		return expanded
+	}
+
+	expanded := make([]SourceConfig, len(sources))
+	for i, source := range sources {
+		expanded[i] = source
+		if len(source.Directories) > 0 {
+			expanded[i].Directories = make([]DirectoryConfig, len(source.Directories))
+			for j, dir := range source.Directories {
+				expanded[i].Directories[j] = dir
+				expanded[i].Directories[j].Path = expand(dir.Path)
+			}
+		}
+		if len(source.Files) > 0 {
+			expanded[i].Files = make([]string, len(source.Files))
+			for j, file := range source.Files {
+				expanded[i].Files[j] = expand(file)
+			}
+		}
+	}
+	return expanded
+}
+
+// HasPathTemplates 判断日志目录或确定文件中是否使用了路径模板
+func (c *Config) HasPathTemplates() bool {
+	for _, source := range c.rawSources() {
+		for _, dir := range source.Directories {
+			if IsPathTemplate(dir.Path) {
+				return true
+			}
+		}
+		for _, file := range source.Files {
+			if IsPathTemplate(file) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExpandPath(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.Local)
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"/var/log/app", "/var/log/app", false},
+		{`/var/log/{{date}}`, "/var/log/2026-03-01", false},
+		{`/var/log/{{date "20060102"}}/app.log`, "/var/log/20260301/app.log", false},
+		// 跨月的前一天
+		{`/var/log/app-{{yesterday}}.log`, "/var/log/app-2026-02-28.log", false},
+		{`/var/log/app-{{yesterday "0102"}}.log`, "/var/log/app-0228.log", false},
+		{`/var/log/{{hostname}}/app.log`, "/var/log/" + hostname + "/app.log", false},
+		{`/var/log/{{date "2006"}}/{{hostname}}-{{date "01"}}.log`, "/var/log/2026/" + hostname + "-03.log", false},
+		{`/var/log/{{unknown}}`, "", true},
+		{`/var/log/{{date`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := ExpandPath(tt.in, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandPath(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPathTime(t *testing.T) {
+	// 缓存的模板按每次传入的时间求值
+	p := `/logs/{{date "20060102"}}`
+	for _, day := range []int{1, 2} {
+		got, err := ExpandPath(p, time.Date(2026, 10, day, 0, 0, 0, 0, time.Local))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := time.Date(2026, 10, day, 0, 0, 0, 0, time.Local).Format("/logs/20060102"); got != want {
+			t.Errorf("ExpandPath = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestExpandSources(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	sources := []SourceConfig{{
+		Name:        "app",
+		Directories: []DirectoryConfig{{Path: `/logs/{{date "2006"}}`}, {Path: "/static"}},
+		Files:       []string{`/logs/app-{{date}}.log`, `/logs/{{bad`},
+	}}
+	expanded := expandSources(sources, now)
+	if got := expanded[0].Directories[0].Path; got != "/logs/2026" {
+		t.Errorf("directory = %q", got)
+	}
+	if got := expanded[0].Files; got[0] != "/logs/app-2026-10-19.log" || got[1] != `/logs/{{bad` {
+		t.Errorf("files = %v", got)
+	}
+	// 原配置不变
+	if sources[0].Directories[0].Path != `/logs/{{date "2006"}}` || sources[0].Files[0] != `/logs/app-{{date}}.log` {
+		t.Errorf("source modified: %+v", sources[0])
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ANSI 处理模式，对应 content 接口的 ansi 参数
+const (
+	ansiStrip = "strip" // 去除转义序列（默认）
+	ansiSpans = "spans" // 去除转义序列，并返回带样式的片段
+	ansiRaw   = "raw"   // 保留原始内容，需要脱敏时按 strip 处理（转义序列会拆开敏感内容，无法可靠脱敏）
+)
+
+// ansiColorNames 标准16色的名称，30-37/40-47 对应前8个，90-97/100-107 对应后8个
+var ansiColorNames = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"bright_black", "bright_red", "bright_green", "bright_yellow",
+	"bright_blue", "bright_magenta", "bright_cyan", "bright_white",
+}
+
+// AnsiStyle 文本样式，颜色为标准色名称或 #rrggbb
+type AnsiStyle struct {
+	Fg        string `json:"fg,omitempty"`
+	Bg        string `json:"bg,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Dim       bool   `json:"dim,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+}
+
+// AnsiSpan 样式相同的一段文本
+type AnsiSpan struct {
+	Text string `json:"text"`
+	AnsiStyle
+}
+
+// stripANSI 去除行中的ANSI转义序列
+func stripANSI(line string) string {
+	if strings.IndexByte(line, 0x1b) < 0 {
+		return line
+	}
+	var sb strings.Builder
+	scanANSI(line, func(text string) { sb.WriteString(text) }, nil)
+	return sb.String()
+}
+
+// parseANSI 解析行中的SGR样式序列，返回去除转义后的样式片段；其他控制序列直接丢弃
+func parseANSI(line string) []AnsiSpan {
+	var spans []AnsiSpan
+	var style AnsiStyle
+	scanANSI(line, func(text string) {
+		if n := len(spans); n > 0 && spans[n-1].AnsiStyle == style {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, AnsiSpan{Text: text, AnsiStyle: style})
+	}, func(params string) {
+		style.apply(params)
+	})
+	return spans
+}
+
+// scanANSI 遍历行内容，text 接收普通文本，sgr 接收SGR序列（ESC[...m）的参数
+// 支持 CSI（ESC[）、OSC（ESC]，以 BEL 或 ESC\ 结束）、带中间字节的 nF 序列以及两字符的转义序列
+func scanANSI(line string, text func(string), sgr func(params string)) {
+	start := 0
+	i := 0
+	for i < len(line) {
+		if line[i] != 0x1b {
+			i++
+			continue
+		}
+		if i > start {
+			text(line[start:i])
+		}
+		if i+1 >= len(line) {
+			i++
+			start = i
+			break
+		}
+
+		switch line[i+1] {
+		case '[':
+			// CSI：参数字节 0x30-0x3F，中间字节 0x20-0x2F，结束字节 0x40-0x7E
+			j := i + 2
+			for j < len(line) && line[j] >= 0x20 && line[j] <= 0x3f {
+				j++
+			}
+			if j < len(line) && line[j] >= 0x40 && line[j] <= 0x7e {
+				if line[j] == 'm' && sgr != nil {
+					sgr(line[i+2 : j])
+				}
+				j++
+			}
+			i = j
+		case ']':
+			j := i + 2
+			for j < len(line) {
+				if line[j] == 0x07 {
+					j++
+					break
+				}
+				if line[j] == 0x1b && j+1 < len(line) && line[j+1] == '\\' {
+					j += 2
+					break
+				}
+				j++
+			}
+			i = j
+		default:
+			// nF 序列（如 tput sgr0 输出的 ESC(B）：中间字节 0x20-0x2F 之后还有一个结束字节
+			j := i + 1
+			for j < len(line) && line[j] >= 0x20 && line[j] <= 0x2f {
+				j++
+			}
+			i = min(j+1, len(line))
+		}
+		start = i
+	}
+	if start < len(line) {
+		text(line[start:])
+	}
+}
+
+// apply 应用SGR参数，如 "1;31"、"38;5;208"、"38;2;255;0;0"，空参数表示重置
+func (s *AnsiStyle) apply(params string) {
+	if params == "" {
+		*s = AnsiStyle{}
+		return
+	}
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			if codes[i] == "" {
+				code = 0
+			} else {
+				continue
+			}
+		}
+		switch {
+		case code == 0:
+			*s = AnsiStyle{}
+		case code == 1:
+			s.Bold = true
+		case code == 2:
+			s.Dim = true
+		case code == 3:
+			s.Italic = true
+		case code == 4:
+			s.Underline = true
+		case code == 22:
+			s.Bold, s.Dim = false, false
+		case code == 23:
+			s.Italic = false
+		case code == 24:
+			s.Underline = false
+		case code >= 30 && code <= 37:
+			s.Fg = ansiColorNames[code-30]
+		case code >= 90 && code <= 97:
+			s.Fg = ansiColorNames[code-90+8]
+		case code == 39:
+			s.Fg = ""
+		case code >= 40 && code <= 47:
+			s.Bg = ansiColorNames[code-40]
+		case code >= 100 && code <= 107:
+			s.Bg = ansiColorNames[code-100+8]
+		case code == 49:
+			s.Bg = ""
+		case code == 38 || code == 48:
+			color, used := extendedColor(codes[i+1:])
+			i += used
+			if code == 38 {
+				s.Fg = color
+			} else {
+				s.Bg = color
+			}
+		}
+	}
+}
+
+// extendedColor 解析 5;n（256色）或 2;r;g;b（真彩色），返回颜色和消耗的参数个数
+func extendedColor(codes []string) (string, int) {
+	if len(codes) == 0 {
+		return "", 0
+	}
+	switch codes[0] {
+	case "5":
+		if len(codes) < 2 {
+			return "", len(codes)
+		}
+		n, err := strconv.Atoi(codes[1])
+		if err != nil || n < 0 || n > 255 {
+			return "", 2
+		}
+		return xterm256Color(n), 2
+	case "2":
+		if len(codes) < 4 {
+			return "", len(codes)
+		}
+		rgb := make([]int, 3)
+		for k := 0; k < 3; k++ {
+			v, err := strconv.Atoi(codes[k+1])
+			if err != nil || v < 0 || v > 255 {
+				return "", 4
+			}
+			rgb[k] = v
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), 4
+	}
+	return "", 1
+}
+
+// xterm256Color 将256色编号转换为颜色，0-15 返回标准色名称，其余返回 #rrggbb
+func xterm256Color(n int) string {
+	if n < 16 {
+		return ansiColorNames[n]
+	}
+	if n >= 232 {
+		v := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+	n -= 16
+	levels := []int{0, 95, 135, 175, 215, 255}
+	return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[(n/6)%6], levels[n%6])
+}
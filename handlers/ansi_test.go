@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"\x1b[31mred\x1b[0m text", "red text"},
+		{"\x1b[1;38;5;208mwarn\x1b[m", "warn"},
+		{"\x1b[2K\x1b[1Gprogress", "progress"},
+		{"\x1b]0;title\x07done", "done"},
+		{"\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"\x1b(Bcharset", "charset"},
+		{"\x1b[1mbold\x1b(B\x1b[m end", "bold end"},
+		{"\x1b7saved\x1b8", "saved"},
+		{"trailing\x1b", "trailing"},
+		{"\x1b[31", ""},
+	}
+	for _, tt := range tests {
+		if got := stripANSI(tt.in); got != tt.want {
+			t.Errorf("stripANSI(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseANSI(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []AnsiSpan
+	}{
+		{"plain", []AnsiSpan{{Text: "plain"}}},
+		{
+			"\x1b[1;31mERROR\x1b[0m done",
+			[]AnsiSpan{{Text: "ERROR", AnsiStyle: AnsiStyle{Fg: "red", Bold: true}}, {Text: " done"}},
+		},
+		{
+			"\x1b[38;5;208;48;2;0;0;255mx\x1b[39my\x1b[49mz",
+			[]AnsiSpan{
+				{Text: "x", AnsiStyle: AnsiStyle{Fg: "#ff8700", Bg: "#0000ff"}},
+				{Text: "y", AnsiStyle: AnsiStyle{Bg: "#0000ff"}},
+				{Text: "z"},
+			},
+		},
+		{
+			"\x1b[92;4mok\x1b[24m\x1b[2K!",
+			[]AnsiSpan{{Text: "ok", AnsiStyle: AnsiStyle{Fg: "bright_green", Underline: true}}, {Text: "!", AnsiStyle: AnsiStyle{Fg: "bright_green"}}},
+		},
+		// 样式相同的相邻片段合并
+		{"\x1b[33ma\x1b[33mb", []AnsiSpan{{Text: "ab", AnsiStyle: AnsiStyle{Fg: "yellow"}}}},
+		{"\x1b[38;5;7mgrey\x1b[38;5;232mdark", []AnsiSpan{{Text: "grey", AnsiStyle: AnsiStyle{Fg: "white"}}, {Text: "dark", AnsiStyle: AnsiStyle{Fg: "#080808"}}}},
+	}
+	for _, tt := range tests {
+		if got := parseANSI(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseANSI(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestANSISearchAndSpans(t *testing.T) {
+	dir := t.TempDir()
+	path := writeLog(t, dir, "app.log",
+		"\x1b[32mINFO\x1b[0m user \x1b[1mlogin\x1b[0m ok",
+		"\x1b[31mERROR\x1b[0m payment fail\x1b[1med\x1b[0m",
+	)
+	useConfig(t, testConfig(dir, false))
+
+	// 搜索在去除转义后的文本上匹配，返回的内容也不包含转义序列
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"\"user login\"", []string{"INFO user login ok"}},
+		{"failed", []string{"ERROR payment failed"}},
+		{"31m", nil},
+	}
+	for _, tt := range tests {
+		code, response := serve(t, SearchLogs, http.MethodPost, "/", map[string]interface{}{"files": []string{path}, "pattern": tt.pattern})
+		if code != http.StatusOK {
+			t.Fatalf("search %q: status %d: %v", tt.pattern, code, response["error"])
+		}
+		results, _ := response["results"].([]interface{})
+		var got []string
+		for _, r := range results {
+			result, _ := r.(map[string]interface{})
+			got = append(got, result["content"].(string))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search %q = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+
+	code, response := serve(t, GetLogContent, http.MethodGet, "/?ansi=spans&lines=1&file="+url.QueryEscape(path), nil)
+	if code != http.StatusOK {
+		t.Fatalf("spans: status %d: %v", code, response["error"])
+	}
+	if content := jsonStrings(response["content"]); len(content) != 1 || content[0] != "ERROR payment failed" {
+		t.Errorf("spans content = %q", content)
+	}
+	styles, _ := response["styles"].([]interface{})
+	spans, _ := styles[0].([]interface{})
+	var texts []string
+	for _, s := range spans {
+		span, _ := s.(map[string]interface{})
+		texts = append(texts, span["text"].(string))
+	}
+	if !reflect.DeepEqual(texts, []string{"ERROR", " payment fail", "ed"}) {
+		t.Errorf("spans = %v", spans)
+	}
+	if first, _ := spans[0].(map[string]interface{}); first["fg"] != "red" {
+		t.Errorf("first span = %v", first)
+	}
+
+	if code, _ := serve(t, GetLogContent, http.MethodGet, "/?ansi=html&file="+url.QueryEscape(path), nil); code != http.StatusBadRequest {
+		t.Errorf("unknown ansi mode: status %d", code)
+	}
+}
@@ -27,10 +27,10 @@ func Login(c *gin.Context) {
 	}
 
 	cfg := config.GetConfig()
-	if req.Username == cfg.Auth.Username && req.Password == cfg.Auth.Password {
+	if cfg.Auth.CheckUser(req.Username, req.Password) {
 		// 生成简单的token
 		token := generateToken()
-		middleware.SetAuthenticated(token)
+		middleware.SetAuthenticated(token, req.Username)
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "登录成功",
@@ -74,10 +74,10 @@ func CheckAuth(c *gin.Context) {
 		token = c.Query("token")
 	}
 
-	if middleware.GetCurrentUser(c) != "" {
+	if user := middleware.GetCurrentUser(c); user != "" {
 		c.JSON(http.StatusOK, gin.H{
 			"authenticated": true,
-			"user":          "milk",
+			"user":          user,
 		})
 		return
 	}
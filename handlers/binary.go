@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// binarySampleSize 判断二进制文件时读取的文件头大小
+	binarySampleSize = 8 * 1024
+	// binaryThreshold 无效UTF-8字节和控制字符占比超过该值时视为二进制文件
+	binaryThreshold = 0.1
+	// hexdumpRowSize 十六进制视图每行的字节数
+	hexdumpRowSize = 16
+	// defaultHexdumpLength 十六进制视图默认每页字节数
+	defaultHexdumpLength = 4096
+	// maxHexdumpLength 十六进制视图每页最大字节数
+	maxHexdumpLength = 64 * 1024
+	// hexdumpRedactMargin 脱敏时在页面前后多读取的字节数，使跨越页面边界的敏感内容也能被识别
+	hexdumpRedactMargin = 1024
+)
+
+// binaryCacheEntry 二进制检测结果缓存，文件大小或修改时间变化后重新检测
+type binaryCacheEntry struct {
+	size    int64
+	modTime time.Time
+	binary  bool
+}
+
+var (
+	binaryCache   = make(map[string]binaryCacheEntry)
+	binaryCacheMu sync.Mutex
+)
+
+// isBinaryFile 判断文件是否为二进制文件，结果按文件大小和修改时间缓存
+func isBinaryFile(absPath string) (bool, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return false, err
+	}
+
+	binaryCacheMu.Lock()
+	cached, ok := binaryCache[absPath]
+	binaryCacheMu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.binary, nil
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	sample := make([]byte, binarySampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	sample = sample[:n]
+	// 日志源配置了其他编码时先转换为 UTF-8 再判断
+	if decoder := sourceDecoder(absPath); decoder != nil {
+		if decoded, err := decoder.Bytes(sample); err == nil {
+			sample = decoded
+		}
+	}
+	binary := isBinaryContent(sample, n == binarySampleSize)
+
+	binaryCacheMu.Lock()
+	binaryCache[absPath] = binaryCacheEntry{size: info.Size(), modTime: info.ModTime(), binary: binary}
+	binaryCacheMu.Unlock()
+	return binary, nil
+}
+
+// isBinaryContent 判断内容是否为二进制：包含NUL字节，或无效UTF-8字节和控制字符占比过高
+// truncated 表示内容是截取的文件头，末尾不完整的多字节字符不计入无效字节
+func isBinaryContent(data []byte, truncated bool) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	suspicious := 0
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b == 0 {
+			return true
+		}
+		if b < utf8.RuneSelf {
+			// 制表、换行、回车、换页、退格和ESC（ANSI转义）属于正常文本
+			if (b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\b' && b != 0x1b) || b == 0x7f {
+				suspicious++
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			if truncated && !utf8.FullRune(data[i:]) {
+				break
+			}
+			suspicious++
+		}
+		i += size
+	}
+	return float64(suspicious)/float64(len(data)) > binaryThreshold
+}
+
+// HexdumpRow 十六进制视图的一行
+type HexdumpRow struct {
+	Offset int64  `json:"offset"` // 行首字节偏移
+	Hex    string `json:"hex"`    // 十六进制，每8字节之间多一个空格
+	ASCII  string `json:"ascii"`  // 可打印字符，其余显示为 .
+}
+
+// GetHexdump 以十六进制/ASCII形式按字节偏移分页查看文件内容
+// 启用脱敏时敏感内容按字节替换为 *，并与其他读取接口一样记录脱敏审计日志
+func GetHexdump(c *gin.Context) {
+	filePath := c.Query("file")
+	if filePath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "文件路径不能为空",
+		})
+		return
+	}
+
+	absFilePath, err := resolveFile(filePath)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	info, err := os.Stat(absFilePath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("文件不存在: %s", filepath.Base(absFilePath)),
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset参数无效",
+		})
+		return
+	}
+	length, err := strconv.Atoi(c.DefaultQuery("length", strconv.Itoa(defaultHexdumpLength)))
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "length参数无效",
+		})
+		return
+	}
+	if length > maxHexdumpLength {
+		length = maxHexdumpLength
+	}
+
+	size := info.Size()
+	if offset > size {
+		offset = size
+	}
+
+	file, err := os.Open(absFilePath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("读取文件失败: %v", err),
+		})
+		return
+	}
+	defer file.Close()
+
+	// 需要脱敏时连同前后的内容一起读取，敏感内容逐字节替换为 *，十六进制和字符列都不暴露原内容
+	redaction := newRedaction(c)
+	start, end := offset, offset+int64(length)
+	if redaction != nil {
+		start = max(offset-hexdumpRedactMargin, 0)
+		end += hexdumpRedactMargin
+	}
+	buf := make([]byte, end-start)
+	read, err := file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("读取文件失败: %v", err),
+		})
+		return
+	}
+	// 只有与返回内容重叠的敏感内容计入统计，前后多读取部分中的命中不计
+	from := int(min(offset-start, int64(read)))
+	buf = redaction.MaskRange(buf[:read], from, from+length)
+	data := buf[from:]
+	data = data[:min(length, len(data))]
+	n := len(data)
+
+	response := gin.H{
+		"file":   filepath.Base(absFilePath),
+		"size":   size,
+		"offset": offset,
+		"length": n,
+		"rows":   hexdumpRows(data, offset),
+	}
+	if next := offset + int64(n); next < size {
+		response["next_offset"] = next
+	}
+	if offset > 0 {
+		prev := offset - int64(length)
+		if prev < 0 {
+			prev = 0
+		}
+		response["prev_offset"] = prev
+	}
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// hexdumpRows 将数据按每行16字节格式化，base 为数据在文件中的起始偏移
+func hexdumpRows(data []byte, base int64) []HexdumpRow {
+	rows := make([]HexdumpRow, 0, (len(data)+hexdumpRowSize-1)/hexdumpRowSize)
+	for start := 0; start < len(data); start += hexdumpRowSize {
+		end := start + hexdumpRowSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[start:end]
+
+		var hex, ascii strings.Builder
+		for i, b := range chunk {
+			if i > 0 {
+				hex.WriteByte(' ')
+				if i == hexdumpRowSize/2 {
+					hex.WriteByte(' ')
+				}
+			}
+			fmt.Fprintf(&hex, "%02x", b)
+			if b >= 0x20 && b < 0x7f {
+				ascii.WriteByte(b)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		rows = append(rows, HexdumpRow{Offset: base + int64(start), Hex: hex.String(), ASCII: ascii.String()})
+	}
+	return rows
+}
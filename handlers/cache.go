@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// convertCacheIdle journal 和轮转文件组的转换缓存超过该时长没有使用时删除
+	convertCacheIdle = 6 * time.Hour
+	// convertCachePruneInterval 检查不再使用的转换缓存的最小间隔
+	convertCachePruneInterval = 10 * time.Minute
+)
+
+// pruneCacheFiles 删除缓存目录中不再使用的转换缓存
+// 只处理本程序生成的文件：以 prefix- 开头、.log 结尾且不在 keep 中的缓存（如已过期或上次运行留下的），
+// 以及超过 convertCacheIdle 没有修改的 .prefix-*.tmp 临时文件（生成缓存时异常退出留下的）
+func pruneCacheFiles(dir, prefix string, keep map[string]bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		switch {
+		case strings.HasPrefix(name, prefix+"-") && strings.HasSuffix(name, ".log"):
+			if keep[path] {
+				continue
+			}
+		case strings.HasPrefix(name, "."+prefix+"-") && strings.HasSuffix(name, ".tmp"):
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < convertCacheIdle {
+				continue
+			}
+		default:
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			fmt.Printf("删除不再使用的缓存: %s\n", path)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/rotation"
+)
+
+// 当前使用的文件目录和轮转文件匹配器，日志目录、文件模式或轮转规则变化时重新创建
+// 新的文件目录在锁外建立（需要全量扫描），完成后再替换，建立期间继续使用原有目录
+var (
+	fileCatalog      *catalog.Catalog
+	fileRotation     *rotation.Matcher
+	fileCatalogKey   string
+	fileCatalogGen   int    // 已替换的文件目录的建立序号
+	fileCatalogNext  int    // 最近一次开始建立的序号
+	fileCatalogBuild string // 正在建立的文件目录对应的配置，为空表示没有
+	fileCatalogMu    sync.Mutex
+)
+
+// pathTemplateCheckInterval 日志目录使用路径模板时，检查展开结果是否变化（如跨过零点）的间隔
+const pathTemplateCheckInterval = time.Minute
+
+var pathTemplateWatch sync.Once
+
+// RegisterCatalog 根据配置创建文件目录并开始监听，目录、文件模式、轮转规则和校对间隔不变时保留原有目录
+// 日志目录中的路径模板展开结果变化后（如日期变化），文件目录会在下次请求或定期检查时重新创建
+func RegisterCatalog(cfg *config.Config) {
+	pathTemplateWatch.Do(func() { go watchPathTemplates() })
+
+	roots := catalogRoots(cfg)
+	key := fmt.Sprintf("%+v|%s|%+v|%d", roots, cfg.Logs.Pattern, cfg.Logs.Rotation, cfg.Logs.ReconcileInterval)
+
+	// 相同配置的文件目录已在使用或正在建立时直接返回；正在建立其他配置时（如配置改回原样）仍需重新建立
+	fileCatalogMu.Lock()
+	if fileCatalog != nil && key == fileCatalogKey && fileCatalogBuild == "" || key == fileCatalogBuild {
+		fileCatalogMu.Unlock()
+		return
+	}
+	fileCatalogNext++
+	gen := fileCatalogNext
+	fileCatalogBuild = key
+	fileCatalogMu.Unlock()
+
+	c, matcher, err := buildCatalog(cfg, roots)
+
+	fileCatalogMu.Lock()
+	if fileCatalogBuild == key {
+		fileCatalogBuild = ""
+	}
+	if err != nil {
+		fileCatalogMu.Unlock()
+		fmt.Printf("%v，继续使用原有文件目录\n", err)
+		return
+	}
+	// 建立期间配置再次变化且更新的文件目录已替换时，丢弃这次的结果
+	if gen < fileCatalogGen {
+		fileCatalogMu.Unlock()
+		c.Close()
+		return
+	}
+	old := fileCatalog
+	fileCatalog = c
+	fileRotation = matcher
+	fileCatalogKey = key
+	fileCatalogGen = gen
+	fileCatalogMu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+
+	// 文件目录建立后开始后台统计文件信息和记录磁盘占用
+	startFileMeta()
+	startUsageSampler()
+}
+
+// buildCatalog 创建文件目录并完成首次扫描
+func buildCatalog(cfg *config.Config, roots []catalog.Root) (*catalog.Catalog, *rotation.Matcher, error) {
+	pattern, err := regexp.Compile(cfg.Logs.Pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("文件模式错误: %v", err)
+	}
+	matcher, err := cfg.Logs.Rotation.Matcher()
+	if err != nil {
+		return nil, nil, fmt.Errorf("轮转文件规则错误: %v", err)
+	}
+
+	started := time.Now()
+	c := catalog.New(roots, pattern, matcher, time.Duration(cfg.Logs.ReconcileInterval)*time.Second)
+	if err := c.Start(); err != nil {
+		fmt.Printf("文件目录将只通过定期扫描更新: %v\n", err)
+	}
+	files, _ := c.Files()
+	fmt.Printf("文件目录已建立: 目录=%v, 文件数=%d, 耗时=%v\n", cfg.LogDirectories(), len(files), time.Since(started))
+	return c, matcher, nil
+}
+
+// currentRotation 返回当前使用的轮转文件匹配器，文件目录尚未建立时根据配置创建，关闭分组时返回 nil
+func currentRotation(cfg *config.Config) *rotation.Matcher {
+	fileCatalogMu.Lock()
+	c, matcher := fileCatalog, fileRotation
+	fileCatalogMu.Unlock()
+	if c != nil {
+		return matcher
+	}
+	matcher, err := cfg.Logs.Rotation.Matcher()
+	if err != nil {
+		fmt.Printf("轮转文件规则错误: %v\n", err)
+		return nil
+	}
+	return matcher
+}
+
+// watchPathTemplates 定期按当前时间展开日志目录的路径模板，变化时重新创建文件目录
+func watchPathTemplates() {
+	ticker := time.NewTicker(pathTemplateCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if cfg := config.GetConfig(); cfg != nil && cfg.HasPathTemplates() {
+			RegisterCatalog(cfg)
+		}
+	}
+}
+
+// catalogFiles 返回日志目录中匹配的文件，文件目录未建立时遍历目录
+func catalogFiles(cfg *config.Config) ([]catalog.File, error) {
+	// 日志目录使用路径模板时，按当前时间确认文件目录仍然有效
+	if cfg.HasPathTemplates() {
+		RegisterCatalog(cfg)
+	}
+
+	fileCatalogMu.Lock()
+	c := fileCatalog
+	fileCatalogMu.Unlock()
+	if c != nil {
+		files, _ := c.Files()
+		return files, nil
+	}
+
+	pattern, err := regexp.Compile(cfg.Logs.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("正则表达式编译失败: %w", err)
+	}
+	return catalog.Scan(catalogRoots(cfg), pattern, currentRotation(cfg)), nil
+}
+
+// catalogRoots 将各日志源的目录配置转换为文件目录的扫描规则
+func catalogRoots(cfg *config.Config) []catalog.Root {
+	var roots []catalog.Root
+	for _, source := range cfg.LogSources() {
+		for _, dir := range source.Directories {
+			roots = append(roots, catalog.Root{
+				Path:           dir.Path,
+				Source:         source.Name,
+				Include:        dir.Include,
+				Exclude:        dir.Exclude,
+				MaxDepth:       dir.MaxDepth,
+				FollowSymlinks: dir.FollowSymlinks,
+				SkipHidden:     dir.SkipHidden,
+			})
+		}
+	}
+	return roots
+}
+
+// catalogLogFile 将文件目录中的文件转换为文件列表项
+func catalogLogFile(f catalog.File) LogFile {
+	file := f.Path
+
+	// 获取相对于所属日志目录的路径，无法获取时使用文件名
+	relPath := filepath.Base(file)
+	var directory string
+	if f.Root != "" {
+		if rel, err := filepath.Rel(f.Root, file); err == nil {
+			relPath = rel
+		}
+		if dir := filepath.Dir(relPath); dir != "." {
+			directory = dir
+		}
+	}
+
+	logFile := LogFile{
+		ID:        fileID(f.Source, f.Root, filepath.ToSlash(relPath)),
+		Path:      relPath, // 使用相对路径
+		Name:      filepath.Base(file),
+		FullPath:  file, // 完整文件路径
+		Directory: directory,
+		Size:      f.Size,
+		ModTime:   f.ModTime.Format("2006-01-02 15:04:05"),
+		Source:    f.Source,
+	}
+	logFile.SourceName = f.Source
+	if source, ok := findSource(config.GetConfig(), f.Source); ok {
+		logFile.SourceName = source.Title()
+		logFile.Labels = source.Labels
+	}
+	logFile.Binary, _ = isBinaryFile(file)
+	logFile.Meta = cachedFileMeta(file)
+
+	// 容器日志显示容器名称和 Pod，而不是ID目录
+	if logFile.Container = containerFormat(file); logFile.Container != "" {
+		if name, group, ok := containerDisplayName(file); ok {
+			logFile.Name = name
+			logFile.Directory = group
+		}
+	}
+
+	return logFile
+}
+
+// quoteETag 生成强校验的 ETag
+func quoteETag(tag string) string {
+	return `"` + tag + `"`
+}
+
+// etagMatches 判断 If-None-Match 请求头是否包含当前 ETag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
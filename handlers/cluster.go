@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+	"github.com/anjude/log-tools/redact"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 模板中变量占位符
+const (
+	wildcardToken = "<*>"
+	maskUUID      = "<UUID>"
+	maskIP        = "<IP>"
+	maskHex       = "<HEX>"
+	maskNum       = "<NUM>"
+	maskTime      = "<TIME>"
+)
+
+// defaultClusterSimilarity 默认的模板相似度阈值
+const defaultClusterSimilarity = 0.5
+
+// maxLogClusters 模板数量上限，每行都要与同组的模板比较，变量未被识别的日志会产生大量模板，
+// 超过上限后无法归入已有模板的行计入 other 模板
+const maxLogClusters = 2000
+
+// otherCluster 超过模板数量上限后无法归入已有模板的行所属的模板ID和模板内容
+const (
+	otherClusterID       = "other"
+	otherClusterTemplate = "<OTHER>"
+)
+
+// 变量token识别规则，按顺序依次替换
+var (
+	timeRegex = regexp.MustCompile(`\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?|\d{2}:\d{2}:\d{2}(?:[.,]\d+)?`)
+	uuidRegex = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	ipRegex   = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d{1,5})?\b|(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b`)
+	hexRegex  = regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]*[0-9][0-9a-f]*[a-f][0-9a-f]*\b|\b[0-9a-f]*[a-f][0-9a-f]*[0-9][0-9a-f]*\b`)
+	numRegex  = regexp.MustCompile(`[-+]?\b\d+(?:\.\d+)?\b`)
+)
+
+// maskLine 将行中的变量部分（时间、UUID、IP、十六进制、数字）替换为占位符
+func maskLine(line string) string {
+	line = timeRegex.ReplaceAllString(line, maskTime)
+	line = uuidRegex.ReplaceAllString(line, maskUUID)
+	line = ipRegex.ReplaceAllString(line, maskIP)
+	line = hexRegex.ReplaceAllStringFunc(line, func(s string) string {
+		// 较短的十六进制串（如 "ab1"）更可能是普通单词，不做替换
+		if len(s) < 8 && !strings.HasPrefix(strings.ToLower(s), "0x") {
+			return s
+		}
+		return maskHex
+	})
+	line = numRegex.ReplaceAllString(line, maskNum)
+	return line
+}
+
+// ClusterPosition 模板出现的位置
+type ClusterPosition struct {
+	File       string `json:"file"`                // 文件名
+	FilePath   string `json:"file_path"`           // 完整文件路径
+	LineNumber int    `json:"line_number"`         // 行号
+	Timestamp  string `json:"timestamp,omitempty"` // 行时间（能识别时）
+}
+
+// LogCluster 日志模板聚类结果
+type LogCluster struct {
+	ID        string          `json:"id"`         // 模板ID，由创建模板的行计算得到，之后的行使模板出现通配符时不变
+	Template  string          `json:"template"`   // 模板内容
+	Count     int             `json:"count"`      // 匹配行数
+	FirstSeen ClusterPosition `json:"first_seen"` // 首次出现位置
+	LastSeen  ClusterPosition `json:"last_seen"`  // 最后出现位置
+	Sample    string          `json:"sample"`     // 示例行
+
+	tokens []string
+}
+
+// logClusterer Drain风格的日志模板提取器
+// 按token数量和首个token分组，组内按相似度合并为模板
+type logClusterer struct {
+	similarity float64
+	max        int // 模板数量上限
+	groups     map[string][]*LogCluster
+	clusters   []*LogCluster
+	ids        map[string]bool
+	other      *LogCluster // 超过模板数量上限后的行，没有超过时为 nil
+}
+
+// newLogClusterer 创建模板提取器
+func newLogClusterer(similarity float64) *logClusterer {
+	if similarity <= 0 || similarity > 1 {
+		similarity = defaultClusterSimilarity
+	}
+	return &logClusterer{
+		similarity: similarity,
+		max:        maxLogClusters,
+		groups:     make(map[string][]*LogCluster),
+		ids:        make(map[string]bool),
+	}
+}
+
+// add 将一行加入聚类，返回该行所属的聚类
+func (lc *logClusterer) add(line string, pos ClusterPosition) *LogCluster {
+	tokens := strings.Fields(maskLine(line))
+	key := clusterGroupKey(tokens)
+
+	var best *LogCluster
+	bestScore := -1.0
+	for _, cluster := range lc.groups[key] {
+		score := tokenSimilarity(cluster.tokens, tokens)
+		if score > bestScore {
+			best = cluster
+			bestScore = score
+		}
+	}
+
+	switch {
+	case (best == nil || bestScore < lc.similarity) && len(lc.clusters) >= lc.max:
+		if lc.other == nil {
+			lc.other = &LogCluster{
+				ID:        otherClusterID,
+				Template:  otherClusterTemplate,
+				FirstSeen: pos,
+				Sample:    strings.TrimSpace(line),
+			}
+		}
+		best = lc.other
+	case best == nil || bestScore < lc.similarity:
+		best = &LogCluster{
+			ID:        lc.newID(tokens),
+			tokens:    tokens,
+			FirstSeen: pos,
+			Sample:    strings.TrimSpace(line),
+		}
+		lc.groups[key] = append(lc.groups[key], best)
+		lc.clusters = append(lc.clusters, best)
+	default:
+		// 不一致的位置替换为通配符
+		for i := range best.tokens {
+			if best.tokens[i] != tokens[i] {
+				best.tokens[i] = wildcardToken
+			}
+		}
+	}
+
+	best.Count++
+	best.LastSeen = pos
+	return best
+}
+
+// newID 根据创建模板的行计算模板ID
+// 模板内容会随后续的行出现通配符，创建时的内容不变，因此同样的数据多次聚类（包括追加了新内容后）得到相同的ID；
+// 与已有模板重复时（已有模板出现通配符后相似度不足）按创建顺序加序号
+func (lc *logClusterer) newID(tokens []string) string {
+	template := strings.Join(tokens, " ")
+	id := templateID(template)
+	for n := 2; lc.ids[id]; n++ {
+		id = templateID(fmt.Sprintf("%s#%d", template, n))
+	}
+	lc.ids[id] = true
+	return id
+}
+
+// truncated 是否超过了模板数量上限
+func (lc *logClusterer) truncated() bool {
+	return lc.other != nil
+}
+
+// results 返回按出现次数排序的聚类结果，超过模板数量上限时 other 模板排在最后
+func (lc *logClusterer) results() []*LogCluster {
+	for _, cluster := range lc.clusters {
+		cluster.Template = strings.Join(cluster.tokens, " ")
+	}
+
+	results := make([]*LogCluster, len(lc.clusters))
+	copy(results, lc.clusters)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Count > results[j].Count
+	})
+	if lc.other != nil {
+		results = append(results, lc.other)
+	}
+	return results
+}
+
+// clusterGroupKey 计算分组键：token数量 + 首个token（首个token为变量时使用通配符）
+func clusterGroupKey(tokens []string) string {
+	if len(tokens) == 0 {
+		return "0"
+	}
+	first := tokens[0]
+	if strings.HasPrefix(first, "<") && strings.HasSuffix(first, ">") {
+		first = wildcardToken
+	}
+	return fmt.Sprintf("%d %s", len(tokens), first)
+}
+
+// tokenSimilarity 计算模板与token序列的相似度，通配符不计入
+func tokenSimilarity(template, tokens []string) float64 {
+	if len(template) == 0 {
+		return 1
+	}
+	same := 0
+	for i := range template {
+		if template[i] == tokens[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(template))
+}
+
+// templateID 根据模板内容计算ID
+func templateID(template string) string {
+	sum := sha1.Sum([]byte(template))
+	return hex.EncodeToString(sum[:6])
+}
+
+// ClusterRequest 模板聚类请求结构
+type ClusterRequest struct {
+	Files      []string `json:"files" binding:"required"` // 要分析的文件路径列表
+	Pattern    string   `json:"pattern"`                  // 可选，仅对搜索结果进行聚类
+	Similarity float64  `json:"similarity"`               // 相似度阈值（0-1），默认0.5
+	Limit      int      `json:"limit"`                    // 返回的模板数量上限
+	Start      string   `json:"start"`                    // 可选，开始时间
+	End        string   `json:"end"`                      // 可选，结束时间
+	TemplateID string   `json:"template_id"`              // 下钻时指定的模板ID
+	Lines      int      `json:"lines"`                    // 下钻时返回的最大行数
+}
+
+// clusterSource 参与聚类的数据范围
+type clusterSource struct {
+	files     []string
+	query     *SearchQuery
+	window    timeRange
+	redaction *redact.Session // 需要脱敏的用户在脱敏后的行上匹配搜索和字段条件
+}
+
+// feed 对数据范围内（匹配搜索条件和时间范围）的行进行聚类，返回处理的行数
+// assign 可选，每行加入聚类后调用，行不会再移到其他聚类
+func (lc *logClusterer) feed(src clusterSource, assign func(filePath string, lineNumber int, line string, cluster *LogCluster)) int {
+	total := 0
+
+	for _, filePath := range src.files {
+		// 结构化格式使用解析出的消息聚类，避免字段顺序、时间等干扰模板
+		p := parserForFile(filePath)
+		structured := p.Name() != parser.NameText
+		tracker := lineTimeTracker{parser: p}
+		err := scanFileLines(filePath, func(lineNumber int, line string) bool {
+			if !tracker.inRange(line, src.window) {
+				return true
+			}
+			if strings.TrimSpace(line) == "" {
+				return true
+			}
+			if src.query != nil && !matchesSearchQuery(line, src.query) {
+				return true
+			}
+			total++
+			pos := ClusterPosition{
+				File:       filepath.Base(filePath),
+				FilePath:   filePath,
+				LineNumber: lineNumber,
+			}
+			if tracker.known {
+				pos.Timestamp = tracker.last.Format("2006-01-02 15:04:05")
+			}
+			text := line
+			if structured {
+				if record, ok := p.Parse(line); ok && record.Message != "" {
+					text = record.Message
+				}
+			}
+			cluster := lc.add(text, pos)
+			if assign != nil {
+				assign(filePath, lineNumber, line, cluster)
+			}
+			return true
+		})
+		if err != nil {
+			fmt.Printf("聚类读取文件失败 %s: %v\n", filePath, err)
+		}
+	}
+
+	return total
+}
+
+// parseClusterRequest 解析并验证聚类请求，返回聚类的数据范围
+func parseClusterRequest(c *gin.Context, req *ClusterRequest) (clusterSource, bool) {
+	var src clusterSource
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return src, false
+	}
+
+	src, err := newClusterSource(req.Files, req.Pattern, req.Start, req.End, newRedaction(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return src, false
+	}
+	return src, true
+}
+
+// newClusterSource 根据文件、搜索模式和时间范围构造聚类数据范围
+func newClusterSource(files []string, pattern, start, end string, redaction *redact.Session) (clusterSource, error) {
+	src := clusterSource{
+		files:     resolveRequestFiles(files),
+		redaction: redaction,
+	}
+	if len(src.files) == 0 {
+		return src, fmt.Errorf("没有找到有效的文件进行分析")
+	}
+
+	if strings.TrimSpace(pattern) != "" {
+		query, err := parseSearchPattern(pattern)
+		if err != nil {
+			return src, fmt.Errorf("搜索模式解析错误: %v", err)
+		}
+		query.Redaction = redaction
+		src.query = query
+	}
+
+	window, err := parseTimeRange(start, end)
+	if err != nil {
+		return src, err
+	}
+	src.window = window
+
+	return src, nil
+}
+
+// ClusterLogs 提取日志模板并统计各模板出现次数
+func ClusterLogs(c *gin.Context) {
+	var req ClusterRequest
+	src, ok := parseClusterRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("聚类请求: 文件=%v, 模式=%s, 相似度=%.2f\n", req.Files, req.Pattern, req.Similarity)
+
+	clusterer := newLogClusterer(req.Similarity)
+	total := clusterer.feed(src, nil)
+	clusters := clusterer.results()
+	templateCount := len(clusterer.clusters)
+
+	if req.Limit > 0 && len(clusters) > req.Limit {
+		clusters = clusters[:req.Limit]
+	}
+
+	redaction := newRedaction(c)
+	for _, cluster := range clusters {
+		cluster.Template = redaction.String(cluster.Template)
+		cluster.Sample = redaction.String(cluster.Sample)
+	}
+
+	response := gin.H{
+		"clusters":  clusters,
+		"templates": templateCount,
+		"lines":     total,
+		"files":     req.Files,
+		"truncated": clusterer.truncated(), // 超过模板数量上限，其余的行计入 other 模板
+	}
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetClusterLines 下钻查询某个模板匹配的日志行
+// 行加入聚类后不会再移到其他聚类，聚类的同时按模板ID收集匹配的行，只保留前 lines 行
+func GetClusterLines(c *gin.Context) {
+	var req ClusterRequest
+	src, ok := parseClusterRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	if req.TemplateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "模板ID不能为空",
+		})
+		return
+	}
+
+	maxLines := req.Lines
+	if maxLines <= 0 || maxLines > config.GetConfig().Logs.MaxSearchResults {
+		maxLines = config.GetConfig().Logs.MaxSearchResults
+	}
+
+	clusterer := newLogClusterer(req.Similarity)
+	var target *LogCluster
+	var results []SearchResult
+	clusterer.feed(src, func(filePath string, lineNumber int, line string, cluster *LogCluster) {
+		if cluster.ID != req.TemplateID {
+			return
+		}
+		target = cluster
+		if len(results) < maxLines {
+			results = append(results, SearchResult{
+				LineNumber: lineNumber,
+				Content:    strings.TrimSpace(line),
+				File:       filepath.Base(filePath),
+				FilePath:   filePath,
+			})
+		}
+	})
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "未找到指定的模板",
+		})
+		return
+	}
+	if target != clusterer.other {
+		target.Template = strings.Join(target.tokens, " ")
+	}
+
+	redaction := newRedaction(c)
+	target.Template = redaction.String(target.Template)
+	target.Sample = redaction.String(target.Sample)
+	for i := range results {
+		results[i].Content = redaction.String(results[i].Content)
+	}
+
+	response := gin.H{
+		"template": target,
+		"results":  results,
+		"count":    len(results),
+	}
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// 模板变化类型
+const (
+	changeAppeared    = "appeared"
+	changeDisappeared = "disappeared"
+	changeIncreased   = "increased"
+	changeDecreased   = "decreased"
+)
+
+// 默认的频率变化阈值和最小行数
+const (
+	defaultCompareRatio    = 2.0
+	defaultCompareMinCount = 5
+)
+
+// CompareWindow 对比的一侧数据范围
+type CompareWindow struct {
+	Files   []string `json:"files" binding:"required"` // 文件路径列表
+	Pattern string   `json:"pattern"`                  // 可选，搜索模式
+	Start   string   `json:"start"`                    // 可选，开始时间
+	End     string   `json:"end"`                      // 可选，结束时间
+}
+
+// CompareRequest 模板对比请求结构
+type CompareRequest struct {
+	Baseline   CompareWindow `json:"baseline" binding:"required"` // 基线范围
+	Target     CompareWindow `json:"target" binding:"required"`   // 目标范围
+	Similarity float64       `json:"similarity"`                  // 相似度阈值（0-1），默认0.5
+	Ratio      float64       `json:"ratio"`                       // 频率变化倍数阈值，默认2
+	MinCount   int           `json:"min_count"`                   // 频率变化判定的最小行数，默认5
+	Limit      int           `json:"limit"`                       // 返回的变化数量上限
+}
+
+// TemplateChange 模板变化结果
+type TemplateChange struct {
+	ID            string  `json:"id"`             // 模板ID
+	Template      string  `json:"template"`       // 模板内容
+	Change        string  `json:"change"`         // 变化类型
+	BaselineCount int     `json:"baseline_count"` // 基线中的行数
+	TargetCount   int     `json:"target_count"`   // 目标中的行数
+	BaselineRate  float64 `json:"baseline_rate"`  // 基线中的占比
+	TargetRate    float64 `json:"target_rate"`    // 目标中的占比
+	Impact        float64 `json:"impact"`         // 影响程度，用于排序
+	Sample        string  `json:"sample"`         // 示例行
+}
+
+// CompareClusters 对比基线和目标两个范围的日志模板，找出新增、消失和频率明显变化的模板
+func CompareClusters(c *gin.Context) {
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	baseline, err := newClusterSource(req.Baseline.Files, req.Baseline.Pattern, req.Baseline.Start, req.Baseline.End, newRedaction(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("基线范围错误: %v", err),
+		})
+		return
+	}
+	target, err := newClusterSource(req.Target.Files, req.Target.Pattern, req.Target.Start, req.Target.End, newRedaction(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("目标范围错误: %v", err),
+		})
+		return
+	}
+
+	ratio := req.Ratio
+	if ratio <= 1 {
+		ratio = defaultCompareRatio
+	}
+	minCount := req.MinCount
+	if minCount <= 0 {
+		minCount = defaultCompareMinCount
+	}
+
+	fmt.Printf("模板对比请求: 基线=%v, 目标=%v\n", req.Baseline.Files, req.Target.Files)
+
+	// 两侧共用一个聚类器，保证同一消息在两侧归入相同的模板
+	clusterer := newLogClusterer(req.Similarity)
+	baselineCounts := make(map[*LogCluster]int)
+	targetCounts := make(map[*LogCluster]int)
+	baselineTotal := clusterer.feed(baseline, func(_ string, _ int, _ string, cluster *LogCluster) {
+		baselineCounts[cluster]++
+	})
+	targetTotal := clusterer.feed(target, func(_ string, _ int, _ string, cluster *LogCluster) {
+		targetCounts[cluster]++
+	})
+
+	var changes []TemplateChange
+	for _, cluster := range clusterer.results() {
+		// other 模板包含各种不同的行，不参与对比
+		if cluster == clusterer.other {
+			continue
+		}
+		change := TemplateChange{
+			ID:            cluster.ID,
+			Template:      cluster.Template,
+			BaselineCount: baselineCounts[cluster],
+			TargetCount:   targetCounts[cluster],
+			BaselineRate:  rate(baselineCounts[cluster], baselineTotal),
+			TargetRate:    rate(targetCounts[cluster], targetTotal),
+			Sample:        cluster.Sample,
+		}
+
+		// 影响程度：目标中实际行数与按基线占比推算的期望行数之差
+		expected := change.BaselineRate * float64(targetTotal)
+		change.Impact = math.Abs(float64(change.TargetCount) - expected)
+
+		switch {
+		case change.BaselineCount == 0 && change.TargetCount > 0:
+			change.Change = changeAppeared
+			change.Impact = float64(change.TargetCount)
+		case change.TargetCount == 0 && change.BaselineCount > 0:
+			change.Change = changeDisappeared
+			change.Impact = float64(change.BaselineCount)
+		case change.BaselineCount+change.TargetCount < minCount:
+			continue
+		case change.TargetRate >= change.BaselineRate*ratio:
+			change.Change = changeIncreased
+		case change.TargetRate*ratio <= change.BaselineRate:
+			change.Change = changeDecreased
+		default:
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Impact > changes[j].Impact
+	})
+
+	if req.Limit > 0 && len(changes) > req.Limit {
+		changes = changes[:req.Limit]
+	}
+
+	redaction := newRedaction(c)
+	for i := range changes {
+		changes[i].Template = redaction.String(changes[i].Template)
+		changes[i].Sample = redaction.String(changes[i].Sample)
+	}
+
+	response := gin.H{
+		"changes":        changes,
+		"count":          len(changes),
+		"baseline_lines": baselineTotal,
+		"target_lines":   targetTotal,
+		"truncated":      clusterer.truncated(),
+	}
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// rate 计算占比
+func rate(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestMaskLine(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"user 42 logged in", "user <NUM> logged in"},
+		{"2026-10-19 08:00:01.123 start", "<TIME> start"},
+		{"from 10.0.0.1:8080 ok", "from <IP> ok"},
+		{"req 3f2504e0-4f89-11d3-9a0c-0305e82c3301 done", "req <UUID> done"},
+		{"addr 0xdeadbeef sha 9f86d081884c7d65", "addr <HEX> sha <HEX>"},
+		{"cafe ab1 word", "cafe ab1 word"},
+	}
+	for _, tt := range tests {
+		if got := maskLine(tt.in); got != tt.want {
+			t.Errorf("maskLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestClusterIDStable(t *testing.T) {
+	lines := []string{
+		"GET /api/users status ok",
+		"GET /api/orders status ok",
+		"payment failed code 7",
+		"GET /api/items status slow",
+	}
+	ids := func(lines []string) map[string]string {
+		lc := newLogClusterer(0.5)
+		for i, line := range lines {
+			lc.add(line, ClusterPosition{LineNumber: i + 1})
+		}
+		result := make(map[string]string)
+		for _, cluster := range lc.results() {
+			result[cluster.Sample] = cluster.ID
+		}
+		return result
+	}
+
+	before := ids(lines[:2])
+	after := ids(lines)
+	// 后续的行使模板出现通配符，ID 不变
+	if before[lines[0]] == "" || before[lines[0]] != after[lines[0]] {
+		t.Errorf("id changed after append: %s -> %s", before[lines[0]], after[lines[0]])
+	}
+	if len(after) != 2 || after[lines[2]] == after[lines[0]] {
+		t.Errorf("clusters = %v", after)
+	}
+}
+
+func TestClusterIDUnique(t *testing.T) {
+	lc := newLogClusterer(0.5)
+	tokens := []string{"job", "<NUM>", "done"}
+	first, second := lc.newID(tokens), lc.newID(tokens)
+	if first == second {
+		t.Fatalf("duplicate template id %s", first)
+	}
+	// 相同的数据按相同的顺序聚类得到相同的ID
+	again := newLogClusterer(0.5)
+	if again.newID(tokens) != first || again.newID(tokens) != second {
+		t.Fatal("template ids depend on more than creation order")
+	}
+}
+
+func TestClusterLimit(t *testing.T) {
+	lc := newLogClusterer(0.5)
+	lc.max = 2
+	lines := []string{
+		"GET /api/users status ok",
+		"payment failed code 7",
+		"cache miss for key users",
+		"GET /api/orders status ok",
+		"disk full on volume data",
+	}
+	for i, line := range lines {
+		lc.add(line, ClusterPosition{LineNumber: i + 1})
+	}
+	if !lc.truncated() {
+		t.Fatal("expected truncated")
+	}
+
+	results := lc.results()
+	if len(results) != 3 {
+		t.Fatalf("%d clusters", len(results))
+	}
+	// 已有模板仍然合并新的行，其余的行计入 other
+	if results[0].Count != 2 || results[0].Template != "GET <*> status ok" {
+		t.Errorf("first cluster = %+v", results[0])
+	}
+	other := results[2]
+	if other.ID != otherClusterID || other.Template != otherClusterTemplate || other.Count != 2 {
+		t.Errorf("other = %+v", other)
+	}
+	if other.FirstSeen.LineNumber != 3 || other.LastSeen.LineNumber != 5 || other.Sample != lines[2] {
+		t.Errorf("other positions = %+v", other)
+	}
+
+	unlimited := newLogClusterer(0.5)
+	for i, line := range lines {
+		unlimited.add(line, ClusterPosition{LineNumber: i + 1})
+	}
+	if unlimited.truncated() || len(unlimited.results()) != 4 {
+		t.Errorf("unlimited: truncated %v, %d clusters", unlimited.truncated(), len(unlimited.results()))
+	}
+}
+
+func TestCompareClusters(t *testing.T) {
+	dir := t.TempDir()
+	var baseline, target []string
+	for i := 0; i < 10; i++ {
+		baseline = append(baseline, fmt.Sprintf("user %d logged in", i))
+		target = append(target, fmt.Sprintf("user %d logged in", i+10))
+	}
+	for i := 0; i < 2; i++ {
+		baseline = append(baseline, fmt.Sprintf("cache miss for key %d", i))
+	}
+	for i := 0; i < 10; i++ {
+		target = append(target, fmt.Sprintf("cache miss for key %d", i))
+	}
+	for i := 0; i < 3; i++ {
+		baseline = append(baseline, fmt.Sprintf("disk full on volume %d", i))
+		target = append(target, fmt.Sprintf("payment failed code %d", i))
+	}
+	baselinePath := writeLog(t, dir, "baseline.log", baseline...)
+	targetPath := writeLog(t, dir, "target.log", target...)
+	useConfig(t, testConfig(dir, false))
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want map[string]string // 模板 -> 变化类型
+	}{
+		{
+			name: "default",
+			body: map[string]interface{}{},
+			// user logged in 的占比从 10/15 降到 10/23，未达到 2 倍，不算变化
+			want: map[string]string{
+				"payment failed code <NUM>": changeAppeared,
+				"disk full on volume <NUM>": changeDisappeared,
+				"cache miss for key <NUM>":  changeIncreased,
+			},
+		},
+		{
+			// 提高倍数阈值后频率变化不再计入
+			name: "ratio",
+			body: map[string]interface{}{"ratio": 10},
+			want: map[string]string{
+				"payment failed code <NUM>": changeAppeared,
+				"disk full on volume <NUM>": changeDisappeared,
+			},
+		},
+		{
+			name: "low ratio",
+			body: map[string]interface{}{"ratio": 1.5},
+			want: map[string]string{
+				"payment failed code <NUM>": changeAppeared,
+				"disk full on volume <NUM>": changeDisappeared,
+				"cache miss for key <NUM>":  changeIncreased,
+				"user <NUM> logged in":      changeDecreased,
+			},
+		},
+		{
+			name: "limit",
+			body: map[string]interface{}{"limit": 1},
+			want: map[string]string{"cache miss for key <NUM>": changeIncreased},
+		},
+	}
+	for _, tt := range tests {
+		tt.body["baseline"] = map[string]interface{}{"files": []string{baselinePath}}
+		tt.body["target"] = map[string]interface{}{"files": []string{targetPath}}
+		code, response := serve(t, CompareClusters, http.MethodPost, "/", tt.body)
+		if code != http.StatusOK {
+			t.Fatalf("%s: status %d: %v", tt.name, code, response["error"])
+		}
+		if response["baseline_lines"] != 15.0 || response["target_lines"] != 23.0 || response["truncated"] != false {
+			t.Errorf("%s: response = %v", tt.name, response)
+		}
+		changes, _ := response["changes"].([]interface{})
+		got := make(map[string]string)
+		for _, c := range changes {
+			change, _ := c.(map[string]interface{})
+			got[change["template"].(string)] = change["change"].(string)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: changes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 按影响程度排序：目标中新增了 8 行 cache miss，超过新出现的 3 行 payment failed
+	_, response := serve(t, CompareClusters, http.MethodPost, "/", map[string]interface{}{
+		"baseline": map[string]interface{}{"files": []string{baselinePath}},
+		"target":   map[string]interface{}{"files": []string{targetPath}},
+	})
+	changes, _ := response["changes"].([]interface{})
+	if first, _ := changes[0].(map[string]interface{}); first["template"] != "cache miss for key <NUM>" {
+		t.Errorf("first change = %v", first)
+	}
+
+	code, _ := serve(t, CompareClusters, http.MethodPost, "/", map[string]interface{}{
+		"baseline": map[string]interface{}{"files": []string{"missing.log"}},
+		"target":   map[string]interface{}{"files": []string{targetPath}},
+	})
+	if code != http.StatusBadRequest {
+		t.Errorf("missing baseline: status %d", code)
+	}
+}
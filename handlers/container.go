@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 容器日志格式
+const (
+	containerDocker = "docker" // Docker json-file：{"log":"内容\n","stream":"stdout","time":"..."}
+	containerCRI    = "cri"    // Kubernetes CRI：<时间> <stdout|stderr> <P|F> <内容>
+)
+
+// 容器输出流
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+)
+
+var (
+	// criLineRegex CRI 日志行，标签的第一项为 P（部分行）或 F（完整行）
+	criLineRegex = regexp.MustCompile(`^(\S+) (stdout|stderr) ([A-Z](?::[A-Za-z0-9]+)*) ?(.*)$`)
+	// podContainerLinkRegex /var/log/containers 下的文件名：<pod>_<namespace>_<容器>-<容器ID>.log
+	podContainerLinkRegex = regexp.MustCompile(`^([^_]+)_([^_]+)_(.+)-[0-9a-f]{64}\.log$`)
+	// dockerIDRegex Docker 容器ID
+	dockerIDRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// containerRotatedRegex 轮转后的容器日志文件名：Docker 的 <ID>-json.log.1、kubelet 的 0.log.20261019-120000，可能再加 .gz
+	containerRotatedRegex = regexp.MustCompile(`^(.+\.log)\.((?:\d+|\d{8}-\d{6})(?:\.gz)?|gz)$`)
+)
+
+// containerFormatEntry 容器日志格式的检测结果
+type containerFormatEntry struct {
+	info   os.FileInfo // 检测时的文件
+	format string
+}
+
+var (
+	containerFormats   = make(map[string]containerFormatEntry)
+	containerFormatsMu sync.Mutex
+)
+
+// containerFormat 检测文件是否为容器日志，返回 docker、cri 或空字符串
+// 根据第一个非空行判断，结果按路径缓存（空文件不缓存）；文件换成了新文件或被截断后重新检测
+func containerFormat(absPath string) string {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return ""
+	}
+	containerFormatsMu.Lock()
+	cached, ok := containerFormats[absPath]
+	containerFormatsMu.Unlock()
+	if ok && os.SameFile(cached.info, info) && info.Size() >= cached.info.Size() {
+		return cached.format
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	var first string
+	for scanner.Scan() {
+		if first = strings.TrimSpace(scanner.Text()); first != "" {
+			break
+		}
+	}
+	if first == "" {
+		forgetContainerFormat(absPath)
+		return ""
+	}
+
+	var format string
+	if _, _, _, ok := decodeDockerLine(first); ok {
+		format = containerDocker
+	} else if _, _, _, ok := decodeCRILine(first); ok {
+		format = containerCRI
+	}
+
+	containerFormatsMu.Lock()
+	containerFormats[absPath] = containerFormatEntry{info: info, format: format}
+	containerFormatsMu.Unlock()
+	return format
+}
+
+// forgetContainerFormat 删除文件的容器日志格式缓存
+func forgetContainerFormat(absPath string) {
+	containerFormatsMu.Lock()
+	delete(containerFormats, absPath)
+	containerFormatsMu.Unlock()
+}
+
+// decodeDockerLine 解析 Docker json-file 日志行，返回输出流、内容和是否为部分行
+// 必须同时包含 log、stream 和 time，避免把恰好有 log 和 time 字段的 JSON 日志当作容器日志
+// 内容不以换行结尾表示被 Docker 拆分的部分行
+func decodeDockerLine(line string) (stream, text string, partial, ok bool) {
+	if !strings.HasPrefix(line, "{") {
+		return "", "", false, false
+	}
+	var entry struct {
+		Log    *string `json:"log"`
+		Stream string  `json:"stream"`
+		Time   string  `json:"time"`
+	}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Log == nil || entry.Stream == "" || entry.Time == "" {
+		return "", "", false, false
+	}
+	text = *entry.Log
+	if strings.HasSuffix(text, "\n") {
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+	} else {
+		partial = true
+	}
+	return entry.Stream, text, partial, true
+}
+
+// decodeCRILine 解析 CRI 日志行，返回输出流、内容和是否为部分行
+func decodeCRILine(line string) (stream, text string, partial, ok bool) {
+	m := criLineRegex.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false, false
+	}
+	if _, err := time.Parse(time.RFC3339Nano, m[1]); err != nil {
+		return "", "", false, false
+	}
+	tag, _, _ := strings.Cut(m[3], ":")
+	return m[2], m[4], tag == "P", true
+}
+
+// parseStream 校验输出流参数，允许为空、stdout 或 stderr
+func parseStream(stream string) (string, error) {
+	stream = strings.ToLower(strings.TrimSpace(stream))
+	if stream != "" && stream != streamStdout && stream != streamStderr {
+		return "", fmt.Errorf("不支持的输出流: %s，可选 stdout 或 stderr", stream)
+	}
+	return stream, nil
+}
+
+// containerLine 解开外层格式后的日志行，行号和偏移为第一个片段所在的物理行
+type containerLine struct {
+	lineNumber int
+	offset     int64
+	stream     string
+	text       string
+}
+
+// containerUnwrapper 解开容器日志的外层格式，并按输出流重新拼接部分行
+type containerUnwrapper struct {
+	decode  func(line string) (stream, text string, partial, ok bool)
+	stream  string                    // 只保留该输出流，为空表示全部
+	pending map[string]*containerLine // 各输出流尚未结束的部分行
+}
+
+// newContainerUnwrapper 创建容器日志解包器，文件不是容器日志时返回 nil
+func newContainerUnwrapper(absPath, stream string) *containerUnwrapper {
+	u := &containerUnwrapper{stream: stream, pending: make(map[string]*containerLine)}
+	switch containerFormat(absPath) {
+	case containerDocker:
+		u.decode = decodeDockerLine
+	case containerCRI:
+		u.decode = decodeCRILine
+	default:
+		return nil
+	}
+	return u
+}
+
+// feed 处理一个物理行，拼接出完整的日志行时返回 true
+// 无法解析的行原样返回，指定输出流时丢弃
+func (u *containerUnwrapper) feed(lineNumber int, offset int64, line string) (containerLine, bool) {
+	stream, text, partial, ok := u.decode(line)
+	if !ok {
+		return containerLine{lineNumber: lineNumber, offset: offset, text: line}, u.stream == ""
+	}
+	if u.stream != "" && stream != u.stream {
+		return containerLine{}, false
+	}
+
+	current := u.pending[stream]
+	if current == nil {
+		current = &containerLine{lineNumber: lineNumber, offset: offset, stream: stream}
+	}
+	current.text += text
+	if partial {
+		u.pending[stream] = current
+		return containerLine{}, false
+	}
+	delete(u.pending, stream)
+	return *current, true
+}
+
+// flush 返回文件末尾尚未结束的部分行（可能仍在写入），按行号排序
+func (u *containerUnwrapper) flush() []containerLine {
+	lines := make([]containerLine, 0, len(u.pending))
+	for _, line := range u.pending {
+		lines = append(lines, *line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].lineNumber < lines[j].lineNumber })
+	u.pending = make(map[string]*containerLine)
+	return lines
+}
+
+// containerDisplayName 返回容器日志在文件列表中的名称和分组
+// Docker 使用容器名称代替ID目录，Kubernetes 使用 命名空间/Pod 分组、容器名称作为文件名
+// 轮转后的文件先去掉轮转后缀再识别，名称后面保留后缀（如 web.1）以区分
+func containerDisplayName(absPath string) (name, group string, ok bool) {
+	dir := filepath.Dir(absPath)
+	base, suffix := filepath.Base(absPath), ""
+	if m := containerRotatedRegex.FindStringSubmatch(base); m != nil {
+		base, suffix = m[1], "."+m[2]
+	}
+
+	// /var/lib/docker/containers/<ID>/<ID>-json.log
+	if id := filepath.Base(dir); dockerIDRegex.MatchString(id) && base == id+"-json.log" {
+		return dockerContainerName(dir, id) + suffix, "Docker 容器", true
+	}
+
+	// /var/log/containers/<pod>_<namespace>_<容器>-<ID>.log
+	if m := podContainerLinkRegex.FindStringSubmatch(base); m != nil {
+		return m[3] + suffix, "Pod " + m[2] + "/" + m[1], true
+	}
+
+	// /var/log/pods/<namespace>_<pod>_<UID>/<容器>/<重启次数>.log
+	container := filepath.Base(dir)
+	podDir := filepath.Base(filepath.Dir(dir))
+	if filepath.Base(filepath.Dir(filepath.Dir(dir))) == "pods" {
+		parts := strings.SplitN(podDir, "_", 3)
+		if len(parts) == 3 {
+			name = container
+			if restart := strings.TrimSuffix(base, ".log"); restart != "0" {
+				name += " #" + restart
+			}
+			return name + suffix, "Pod " + parts[0] + "/" + parts[1], true
+		}
+	}
+	return "", "", false
+}
+
+// dockerContainerName 从容器目录的 config.v2.json 读取容器名称，读取失败时使用短ID
+func dockerContainerName(dir, id string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "config.v2.json"))
+	if err == nil {
+		var config struct {
+			Name string `json:"Name"`
+		}
+		if json.Unmarshal(data, &config) == nil && config.Name != "" {
+			return strings.TrimPrefix(config.Name, "/")
+		}
+	}
+	return id[:12]
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeDockerLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		stream  string
+		text    string
+		partial bool
+		ok      bool
+	}{
+		{`{"log":"hello\n","stream":"stdout","time":"2026-10-19T08:00:00.1Z"}`, "stdout", "hello", false, true},
+		{`{"log":"part","stream":"stderr","time":"2026-10-19T08:00:00.1Z"}`, "stderr", "part", true, true},
+		{`{"log":"crlf\r\n","stream":"stdout","time":"2026-10-19T08:00:00.1Z"}`, "stdout", "crlf", false, true},
+		// 普通 JSON 日志恰好有 log 和 time 字段
+		{`{"log":"app started","time":"2026-10-19T08:00:00Z","level":"info"}`, "", "", false, false},
+		{`{"stream":"stdout","time":"2026-10-19T08:00:00Z"}`, "", "", false, false},
+		{`plain text`, "", "", false, false},
+	}
+	for _, tt := range tests {
+		stream, text, partial, ok := decodeDockerLine(tt.line)
+		if stream != tt.stream || text != tt.text || partial != tt.partial || ok != tt.ok {
+			t.Errorf("decodeDockerLine(%s) = %q, %q, %v, %v", tt.line, stream, text, partial, ok)
+		}
+	}
+}
+
+func TestDecodeCRILine(t *testing.T) {
+	tests := []struct {
+		line    string
+		stream  string
+		text    string
+		partial bool
+		ok      bool
+	}{
+		{"2026-10-19T08:00:00.123456789Z stdout F hello world", "stdout", "hello world", false, true},
+		{"2026-10-19T08:00:00Z stderr P part", "stderr", "part", true, true},
+		{"2026-10-19T08:00:00Z stdout F", "stdout", "", false, true},
+		{"yesterday stdout F hello", "", "", false, false},
+		{"2026-10-19T08:00:00Z console F hello", "", "", false, false},
+	}
+	for _, tt := range tests {
+		stream, text, partial, ok := decodeCRILine(tt.line)
+		if stream != tt.stream || text != tt.text || partial != tt.partial || ok != tt.ok {
+			t.Errorf("decodeCRILine(%q) = %q, %q, %v, %v", tt.line, stream, text, partial, ok)
+		}
+	}
+}
+
+func TestContainerDisplayName(t *testing.T) {
+	root := t.TempDir()
+	id := strings.Repeat("ab", 32)
+	dockerDir := filepath.Join(root, "containers", id)
+	os.MkdirAll(dockerDir, 0755)
+	os.WriteFile(filepath.Join(dockerDir, "config.v2.json"), []byte(`{"Name":"/web"}`), 0644)
+
+	tests := []struct {
+		path  string
+		name  string
+		group string
+		ok    bool
+	}{
+		{filepath.Join(dockerDir, id+"-json.log"), "web", "Docker 容器", true},
+		{filepath.Join(dockerDir, id+"-json.log.1"), "web.1", "Docker 容器", true},
+		{filepath.Join(dockerDir, id+"-json.log.2.gz"), "web.2.gz", "Docker 容器", true},
+		{filepath.Join(root, "containers", strings.Repeat("cd", 32), strings.Repeat("cd", 32)+"-json.log"), "cdcdcdcdcdcd", "Docker 容器", true},
+		{"/var/log/containers/api-7d9f_prod_server-" + id + ".log", "server", "Pod prod/api-7d9f", true},
+		{"/var/log/pods/prod_api-7d9f_0a1b/server/0.log", "server", "Pod prod/api-7d9f", true},
+		{"/var/log/pods/prod_api-7d9f_0a1b/server/2.log", "server #2", "Pod prod/api-7d9f", true},
+		{"/var/log/pods/prod_api-7d9f_0a1b/server/0.log.20261019-120000.gz", "server.20261019-120000.gz", "Pod prod/api-7d9f", true},
+		{"/var/log/app/app.log.1", "", "", false},
+	}
+	for _, tt := range tests {
+		name, group, ok := containerDisplayName(tt.path)
+		if name != tt.name || group != tt.group || ok != tt.ok {
+			t.Errorf("containerDisplayName(%s) = %q, %q, %v", tt.path, name, group, ok)
+		}
+	}
+}
+
+func TestContainerFormatCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.log")
+	defer forgetContainerFormat(path)
+	docker := `{"log":"hi\n","stream":"stdout","time":"2026-10-19T08:00:00Z"}` + "\n"
+	cri := "2026-10-19T08:00:00Z stdout F hi\n"
+
+	steps := []struct {
+		name string
+		do   func()
+		want string
+	}{
+		{"empty", func() { os.WriteFile(path, nil, 0644) }, ""},
+		{"docker", func() { os.WriteFile(path, []byte(docker), 0644) }, containerDocker},
+		{"appended", func() {
+			f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			f.WriteString("plain\n")
+			f.Close()
+		}, containerDocker},
+		// 截断后写入了其他格式
+		{"truncated", func() { os.WriteFile(path, []byte(cri), 0644) }, containerCRI},
+		{"replaced", func() {
+			os.WriteFile(path+".new", []byte("plain text log line that is long\n"), 0644)
+			os.Rename(path+".new", path)
+		}, ""},
+	}
+	for _, step := range steps {
+		step.do()
+		if got := containerFormat(path); got != step.want {
+			t.Errorf("%s: containerFormat = %q, want %q", step.name, got, step.want)
+		}
+	}
+}
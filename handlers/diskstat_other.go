@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package handlers
+
+import "errors"
+
+// statDisk 当前平台不支持读取文件系统容量，磁盘占用报告中不返回文件系统信息
+func statDisk(dir string) (diskStat, error) {
+	return diskStat{}, errors.New("当前平台不支持读取文件系统容量")
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// statDisk 通过 statfs 读取目录所在文件系统的容量，设备号用于合并同一文件系统上的多个目录
+func statDisk(dir string) (diskStat, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return diskStat{}, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return diskStat{}, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return diskStat{}, fmt.Errorf("无法获取设备号: %s", dir)
+	}
+	bsize := uint64(fs.Bsize)
+	return diskStat{
+		device:    uint64(st.Dev),
+		total:     fs.Blocks * bsize,
+		free:      fs.Bfree * bsize,
+		available: fs.Bavail * bsize,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// eventFlushInterval 文件大小变化事件的合并间隔，写入频繁的文件每个间隔最多推送一次
+	eventFlushInterval = time.Second
+	// eventHeartbeatInterval 心跳间隔，防止代理因连接空闲而断开，同时检查登录状态
+	eventHeartbeatInterval = 15 * time.Second
+	// eventBufferSize 每个连接的事件缓冲区大小
+	eventBufferSize = 256
+)
+
+// FileEvent 推送给前端的文件事件
+type FileEvent struct {
+	Type string  `json:"type"` // created、removed、rotated、changed
+	File LogFile `json:"file"`
+}
+
+// currentCatalog 返回当前使用的文件目录
+func currentCatalog() *catalog.Catalog {
+	fileCatalogMu.Lock()
+	defer fileCatalogMu.Unlock()
+	return fileCatalog
+}
+
+// GetFileEvents 通过 SSE 推送文件目录中的文件事件
+// EventSource 无法设置请求头，token 通过查询参数传递
+// 配置重新加载后文件目录会重建，此时推送 reset 事件，前端应重新加载文件列表
+// 轮转文件组的成员增删或轮转时同样推送 reset，活动文件的大小变化推送为文件组的 changed 事件
+// 超过日志源保留时长的文件不推送；日志源中确定的文件（可能不在日志目录中）每个合并间隔检查一次
+func GetFileEvents(c *gin.Context) {
+	cat := currentCatalog()
+	if cat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "文件目录尚未建立",
+		})
+		return
+	}
+
+	events, cancel := cat.Subscribe(eventBufferSize)
+	defer func() { cancel() }()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	flushTicker := time.NewTicker(eventFlushInterval)
+	defer flushTicker.Stop()
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// 合并同一文件的大小变化事件
+	changed := make(map[string]catalog.File)
+	var changedOrder []string
+	// 轮转文件组的成员增删或轮转后，文件组在列表中的形态会变化，在下次合并时推送 reset
+	familyReset := false
+	pinned := newPinnedWatch(config.GetConfig())
+
+	c.SSEvent("ready", gin.H{"time": time.Now().Format("2006-01-02 15:04:05")})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+
+		case event, ok := <-events:
+			if !ok {
+				// 文件目录已重建，订阅新的目录
+				next := currentCatalog()
+				if next == nil || next == cat {
+					return false
+				}
+				cat = next
+				events, cancel = cat.Subscribe(eventBufferSize)
+				changed = make(map[string]catalog.File)
+				changedOrder = nil
+				familyReset = false
+				pinned = newPinnedWatch(config.GetConfig())
+				c.SSEvent("reset", gin.H{})
+				return true
+			}
+
+			if !withinRetention(sourceRetentions(config.GetConfig()), event.File, time.Now()) {
+				return true
+			}
+
+			if event.Type == catalog.EventChanged {
+				if _, exists := changed[event.File.Path]; !exists {
+					changedOrder = append(changedOrder, event.File.Path)
+				}
+				changed[event.File.Path] = event.File
+				return true
+			}
+			// 删除或轮转后不再推送之前合并的大小变化
+			delete(changed, event.File.Path)
+			if inFamily(cat, event.File) {
+				familyReset = true
+				return true
+			}
+			c.SSEvent(string(event.Type), FileEvent{Type: string(event.Type), File: catalogLogFile(event.File)})
+			return true
+
+		case <-flushTicker.C:
+			if familyReset {
+				c.SSEvent("reset", gin.H{})
+			} else {
+				// 活动文件的大小变化推送为所在文件组的变化
+				sent := make(map[string]bool)
+				for _, path := range changedOrder {
+					f, ok := changed[path]
+					if !ok {
+						continue
+					}
+					file, isFamily := familyEventFile(cat, f)
+					if !isFamily {
+						file = catalogLogFile(f)
+					}
+					if !sent[file.Path] {
+						sent[file.Path] = true
+						c.SSEvent(string(catalog.EventChanged), FileEvent{Type: string(catalog.EventChanged), File: file})
+					}
+				}
+			}
+			for _, event := range pinned.poll(config.GetConfig()) {
+				c.SSEvent(event.Type, event)
+			}
+			changed = make(map[string]catalog.File)
+			changedOrder = nil
+			familyReset = false
+			return true
+
+		case <-heartbeat.C:
+			// 退出登录后结束推送
+			if middleware.GetCurrentUser(c) == "" {
+				return false
+			}
+			c.SSEvent("ping", gin.H{"time": time.Now().Format("2006-01-02 15:04:05")})
+			return true
+		}
+	})
+}
+
+// inFamily 判断文件是否为已轮转的文件，或是包含已轮转文件的文件组中的活动文件
+func inFamily(cat *catalog.Catalog, f catalog.File) bool {
+	matcher := currentRotation(config.GetConfig())
+	if matcher == nil {
+		return false
+	}
+	if !matcher.Parse(filepath.Base(f.Path), f.ModTime).Active() {
+		return true
+	}
+	_, ok := familyEventFile(cat, f)
+	return ok
+}
+
+// pinnedState 确定的文件上次检查时的状态
+type pinnedState struct {
+	info os.FileInfo
+	file LogFile
+}
+
+// pinnedWatch 检查日志源中确定的文件的变化，这些文件不一定在日志目录中，文件目录不会为其产生事件
+type pinnedWatch struct {
+	files map[string]pinnedState // 文件ID -> 状态
+}
+
+// newPinnedWatch 记录确定的文件的当前状态
+func newPinnedWatch(cfg *config.Config) *pinnedWatch {
+	w := &pinnedWatch{files: make(map[string]pinnedState)}
+	w.poll(cfg)
+	return w
+}
+
+// poll 检查确定的文件，返回与上次检查相比的变化
+// 出现为 created，消失（或从配置中移除）为 removed，换成了新文件或被截断为 rotated，大小或修改时间变化为 changed
+func (w *pinnedWatch) poll(cfg *config.Config) []FileEvent {
+	var events []FileEvent
+	seen := make(map[string]bool)
+	for _, source := range cfg.LogSources() {
+		for _, fixedFile := range source.Files {
+			id := fileID(source.Name, "", fixedScheme+fixedFile)
+			resolvedPath, err := filepath.Abs(fixedFile)
+			if err != nil {
+				continue
+			}
+			info, err := os.Stat(resolvedPath)
+			if err != nil {
+				continue
+			}
+			seen[id] = true
+
+			prev, ok := w.files[id]
+			var eventType catalog.EventType
+			switch {
+			case !ok:
+				eventType = catalog.EventCreated
+			case !os.SameFile(prev.info, info) || info.Size() < prev.info.Size():
+				eventType = catalog.EventRotated
+			case info.Size() != prev.info.Size() || !info.ModTime().Equal(prev.info.ModTime()):
+				eventType = catalog.EventChanged
+			default:
+				continue
+			}
+			file := pinnedLogFile(source, fixedFile, resolvedPath, info)
+			w.files[id] = pinnedState{info: info, file: file}
+			events = append(events, FileEvent{Type: string(eventType), File: file})
+		}
+	}
+	for id, state := range w.files {
+		if !seen[id] {
+			delete(w.files, id)
+			events = append(events, FileEvent{Type: string(catalog.EventRemoved), File: state.file})
+		}
+	}
+	return events
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+)
+
+func TestPinnedWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pinned.log")
+	cfg := &config.Config{Logs: config.LogsConfig{Sources: []config.SourceConfig{{Name: "app", Files: []string{path}}}}}
+	w := newPinnedWatch(cfg)
+
+	types := func() string {
+		var got []string
+		for _, event := range w.poll(cfg) {
+			if !event.File.Pinned || event.File.FullPath != path {
+				t.Errorf("unexpected file %+v", event.File)
+			}
+			got = append(got, event.Type)
+		}
+		return strings.Join(got, ",")
+	}
+	write := func(content string, flag int) {
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(content)
+		f.Close()
+	}
+
+	steps := []struct {
+		name string
+		do   func()
+		want string
+	}{
+		{"created", func() { write("a\n", os.O_TRUNC) }, "created"},
+		{"unchanged", func() {}, ""},
+		{"changed", func() { write("b\n", os.O_APPEND) }, "changed"},
+		{"truncated", func() { write("", os.O_TRUNC) }, "rotated"},
+		{"replaced", func() {
+			next := path + ".new"
+			os.WriteFile(next, []byte("c\n"), 0644)
+			os.Rename(next, path)
+		}, "rotated"},
+		{"removed", func() { os.Remove(path) }, "removed"},
+	}
+	for _, step := range steps {
+		step.do()
+		if got := types(); got != step.want {
+			t.Errorf("%s: events = %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestWithinRetention(t *testing.T) {
+	now := time.Now()
+	retention := map[string]time.Duration{"app": 24 * time.Hour}
+	tests := []struct {
+		file catalog.File
+		want bool
+	}{
+		{catalog.File{Source: "app", ModTime: now.Add(-time.Hour)}, true},
+		{catalog.File{Source: "app", ModTime: now.Add(-48 * time.Hour)}, false},
+		{catalog.File{Source: "other", ModTime: now.Add(-48 * time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if got := withinRetention(retention, tt.file, now); got != tt.want {
+			t.Errorf("withinRetention(%s, %v) = %v", tt.file.Source, now.Sub(tt.file.ModTime), got)
+		}
+	}
+}
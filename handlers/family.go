@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/rotation"
+)
+
+// familyScheme 轮转文件组的路径前缀，格式为 family://<活动文件路径>
+// 读取时按时间顺序连接组内所有文件（压缩的文件先解压），活动文件在最后
+const familyScheme = "family://"
+
+// FamilyMember 文件列表中轮转文件组的成员
+type FamilyMember struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ModTime     string `json:"mod_time"`
+	Compression string `json:"compression,omitempty"` // 压缩格式，未压缩为空
+	Active      bool   `json:"active"`                // 是否为活动文件
+}
+
+// family 同一文件轮转产生的一组文件
+type family struct {
+	active  string         // 活动文件路径，文件可能不存在
+	members []familyMember // 按时间顺序，最旧的在前
+}
+
+// familyMember 文件组中的一个文件
+type familyMember struct {
+	file   catalog.File
+	member rotation.Member
+}
+
+// familyCacheEntry 文件组合并后的缓存
+// 已轮转的文件不变且正在写入的文件只是追加内容时，只把新增的内容追加到缓存
+// 每个缓存单独加锁，合并或追加一个文件组时不阻塞其他文件组
+type familyCacheEntry struct {
+	mu        sync.Mutex
+	origin    string      // 活动文件的绝对路径
+	signature string      // 已轮转文件的路径、大小和修改时间
+	tail      string      // 正在写入的文件：活动文件，没有活动文件时为最新的按日期命名的文件，为空表示还没有生成
+	active    os.FileInfo // 写入缓存时正在写入的文件，不存在时为 nil
+	copied    int64       // 已写入缓存的正在写入的文件字节数
+	used      time.Time   // 最近一次使用的时间，由 familyMu 保护
+}
+
+// defaultFamilyCacheMaxMB 缓存目录大小上限的默认值（MB）
+const defaultFamilyCacheMaxMB = 1024
+
+var (
+	familyCaches = make(map[string]*familyCacheEntry) // 缓存路径 -> 缓存
+	familyPruned time.Time
+	familyMu     sync.Mutex
+)
+
+// isFamilySource 判断路径是否为轮转文件组
+func isFamilySource(filePath string) bool {
+	return strings.HasPrefix(filePath, familyScheme)
+}
+
+// groupFamilies 将轮转文件按活动文件分组，返回不属于任何文件组的文件和包含已轮转文件的文件组
+func groupFamilies(files []catalog.File, matcher *rotation.Matcher) ([]catalog.File, []*family) {
+	if matcher == nil {
+		return files, nil
+	}
+
+	byActive := make(map[string]*family)
+	var order []string
+	for _, f := range files {
+		m := matcher.Parse(filepath.Base(f.Path), f.ModTime)
+		active := filepath.Join(filepath.Dir(f.Path), m.Base)
+		fam, ok := byActive[active]
+		if !ok {
+			fam = &family{active: active}
+			byActive[active] = fam
+			order = append(order, active)
+		}
+		fam.members = append(fam.members, familyMember{file: f, member: m})
+	}
+
+	var singles []catalog.File
+	var families []*family
+	for _, active := range order {
+		fam := byActive[active]
+		if len(fam.members) == 1 && fam.members[0].member.Active() {
+			singles = append(singles, fam.members[0].file)
+			continue
+		}
+		fam.sort()
+		families = append(families, fam)
+	}
+	return singles, families
+}
+
+// sort 按时间顺序排列成员
+func (f *family) sort() {
+	members := make([]rotation.Member, len(f.members))
+	byName := make(map[string]familyMember, len(f.members))
+	for i, m := range f.members {
+		members[i] = m.member
+		byName[m.member.Name] = m
+	}
+	rotation.Sort(members)
+	for i, m := range members {
+		f.members[i] = byName[m.Name]
+	}
+}
+
+// familyLogFile 将文件组转换为文件列表项，大小为各文件之和，修改时间为最新的修改时间
+func familyLogFile(f *family) LogFile {
+	first := f.members[0].file
+	merged := catalog.File{Path: f.active, Root: first.Root, Source: first.Source}
+	members := make([]FamilyMember, 0, len(f.members))
+	for _, m := range f.members {
+		merged.Size += m.file.Size
+		if m.file.ModTime.After(merged.ModTime) {
+			merged.ModTime = m.file.ModTime
+		}
+		members = append(members, FamilyMember{
+			Name:        m.member.Name,
+			Size:        m.file.Size,
+			ModTime:     m.file.ModTime.Format("2006-01-02 15:04:05"),
+			Compression: m.member.Compression,
+			Active:      m.member.Active(),
+		})
+	}
+
+	logFile := catalogLogFile(merged)
+	logFile.ID = fileID(logFile.Source, first.Root, familyScheme+filepath.ToSlash(logFile.Path))
+	logFile.Path = familyScheme + f.active
+	logFile.FullPath = logFile.Path
+	logFile.Family = members
+	logFile.Meta = familyMeta(f)
+	return logFile
+}
+
+// familyOf 返回活动文件所在的文件组，没有已轮转的文件时返回 false
+func familyOf(files []catalog.File, matcher *rotation.Matcher, absActive string) (*family, bool) {
+	_, families := groupFamilies(files, matcher)
+	for _, fam := range families {
+		if abs, err := filepath.Abs(fam.active); err == nil && abs == absActive {
+			return fam, true
+		}
+	}
+	return nil, false
+}
+
+// familyCacheDir 返回文件组合并后的缓存目录
+func familyCacheDir(cfg *config.Config) string {
+	if cfg.Logs.Rotation.CacheDir != "" {
+		return cfg.Logs.Rotation.CacheDir
+	}
+	return filepath.Join(os.TempDir(), "log-tools-rotation")
+}
+
+// familyOrigin 返回文件组缓存对应的活动文件路径，用于按活动文件查找日志源和解析器；其他文件原样返回
+func familyOrigin(absPath string) string {
+	cfg := config.GetConfig()
+	if cfg == nil || !pathWithin(familyCacheDir(cfg), absPath) {
+		return absPath
+	}
+	familyMu.Lock()
+	defer familyMu.Unlock()
+	if cached, ok := familyCaches[absPath]; ok {
+		return cached.origin
+	}
+	return absPath
+}
+
+// resolveFamilySource 将轮转文件组合并为缓存文件并返回其路径
+func resolveFamilySource(filePath string) (string, error) {
+	cfg := config.GetConfig()
+	absActive, err := filepath.Abs(filepath.Clean(strings.TrimPrefix(filePath, familyScheme)))
+	if err != nil {
+		return "", fmt.Errorf("文件路径格式错误")
+	}
+	allowed := false
+	for _, dir := range cfg.LogDirectories() {
+		if pathWithin(dir, absActive) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("文件路径不在允许的目录内")
+	}
+
+	files, err := catalogFiles(cfg)
+	if err != nil {
+		return "", err
+	}
+	fam, ok := familyOf(files, currentRotation(cfg), absActive)
+	if !ok {
+		return "", fmt.Errorf("文件不存在或没有轮转文件")
+	}
+
+	// 已轮转的文件作为签名，正在写入的文件单独按追加处理
+	tail := fam.tail(absActive)
+	var sig strings.Builder
+	var rotated []familyMember
+	for _, m := range fam.members {
+		if m.member.Active() {
+			continue
+		}
+		if abs, err := filepath.Abs(m.file.Path); err == nil && abs == tail {
+			continue
+		}
+		rotated = append(rotated, m)
+		info, err := os.Stat(m.file.Path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sig, "%s:%d:%d;", m.file.Path, info.Size(), info.ModTime().UnixNano())
+	}
+	activeInfo, _ := os.Stat(tail)
+
+	sum := sha1.Sum([]byte(absActive))
+	name := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, filepath.Base(absActive))
+	cacheDir, err := filepath.Abs(familyCacheDir(cfg))
+	if err != nil {
+		return "", err
+	}
+	cachePath := filepath.Join(cacheDir, fmt.Sprintf("family-%s-%s.log", name, hex.EncodeToString(sum[:4])))
+
+	cache := familyCacheFor(cachePath, cacheDir, absActive)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	defer evictFamilyCaches(cacheDir, familyCacheLimit(cfg))
+
+	if cache.signature == sig.String() && cache.tail == tail {
+		if _, err := os.Stat(cachePath); err == nil {
+			switch {
+			case activeInfo == nil && cache.active == nil:
+				return cachePath, nil
+			case activeInfo != nil && cache.active != nil && os.SameFile(activeInfo, cache.active) && activeInfo.Size() >= cache.copied:
+				copied, err := appendFamilyCache(cachePath, tail, cache.copied, activeInfo.Size())
+				if err == nil {
+					cache.active = activeInfo
+					cache.copied = copied
+					return cachePath, nil
+				}
+				fmt.Printf("追加轮转文件组缓存失败，重新生成 %s: %v\n", cachePath, err)
+			}
+		}
+	}
+
+	cache.tail = ""
+	if err := os.MkdirAll(cacheDir, 0750); err != nil {
+		return "", fmt.Errorf("创建轮转文件缓存目录失败: %v", err)
+	}
+	copied, err := writeFamilyCache(cachePath, rotated, tail, activeInfo)
+	if err != nil {
+		return "", err
+	}
+	cache.signature, cache.tail, cache.active, cache.copied = sig.String(), tail, activeInfo, copied
+	fmt.Printf("轮转文件组 %s 已合并: %s, 共 %d 个文件\n", absActive, cachePath, len(fam.members))
+	return cachePath, nil
+}
+
+// tail 返回文件组中正在写入、按追加处理的文件的绝对路径
+// 通常是活动文件；按日期命名、没有不带日期的活动文件时（如每天写入 app-2026-10-19.log），
+// 最新的未压缩的日期文件仍在写入，同样按追加处理，避免每次写入都重新合并整个文件组
+func (f *family) tail(absActive string) string {
+	last := f.members[len(f.members)-1]
+	if last.member.Active() || last.member.Date.IsZero() || last.member.Compression != "" {
+		return absActive
+	}
+	if abs, err := filepath.Abs(last.file.Path); err == nil {
+		return abs
+	}
+	return absActive
+}
+
+// familyCacheFor 返回缓存路径对应的缓存记录并更新使用时间
+func familyCacheFor(cachePath, cacheDir, origin string) *familyCacheEntry {
+	familyMu.Lock()
+	defer familyMu.Unlock()
+
+	now := time.Now()
+	cache, ok := familyCaches[cachePath]
+	if !ok {
+		cache = &familyCacheEntry{origin: origin}
+		familyCaches[cachePath] = cache
+	}
+	cache.used = now
+	pruneFamilyCaches(cacheDir, now)
+	return cache
+}
+
+// pruneFamilyCaches 每隔 convertCachePruneInterval 删除超过 convertCacheIdle 没有使用的缓存和缓存目录中遗留的文件，需持有 familyMu
+func pruneFamilyCaches(cacheDir string, now time.Time) {
+	if now.Sub(familyPruned) < convertCachePruneInterval {
+		return
+	}
+	familyPruned = now
+	keep := make(map[string]bool, len(familyCaches))
+	for path, entry := range familyCaches {
+		// 正在合并的缓存不删除
+		if now.Sub(entry.used) > convertCacheIdle && entry.mu.TryLock() {
+			delete(familyCaches, path)
+			entry.mu.Unlock()
+			continue
+		}
+		keep[path] = true
+	}
+	pruneCacheFiles(cacheDir, "family", keep)
+}
+
+// familyCacheLimit 返回缓存目录的大小上限（字节），配置为负数时不限制
+func familyCacheLimit(cfg *config.Config) int64 {
+	limit := cfg.Logs.Rotation.CacheMaxMB
+	if limit == 0 {
+		limit = defaultFamilyCacheMaxMB
+	}
+	return int64(limit) << 20
+}
+
+// evictFamilyCaches 缓存总大小超过上限时按最近使用时间从旧到新删除缓存，正在使用的缓存不删除
+// 缓存是已轮转文件解压后的完整副本，不限制时会随文件组数量增长占满临时目录
+func evictFamilyCaches(cacheDir string, limit int64) {
+	if limit <= 0 {
+		return
+	}
+	familyMu.Lock()
+	defer familyMu.Unlock()
+
+	type cached struct {
+		path  string
+		entry *familyCacheEntry
+		size  int64
+	}
+	var caches []cached
+	var total int64
+	for path, entry := range familyCaches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		caches = append(caches, cached{path, entry, info.Size()})
+		total += info.Size()
+	}
+	if total <= limit {
+		return
+	}
+	sort.Slice(caches, func(i, j int) bool { return caches[i].entry.used.Before(caches[j].entry.used) })
+	for _, c := range caches {
+		if total <= limit {
+			break
+		}
+		if !c.entry.mu.TryLock() {
+			continue
+		}
+		delete(familyCaches, c.path)
+		c.entry.mu.Unlock()
+		if err := os.Remove(c.path); err == nil {
+			fmt.Printf("缓存目录超过 %d MB，删除最久未使用的缓存: %s\n", limit>>20, c.path)
+		}
+		total -= c.size
+	}
+}
+
+// writeFamilyCache 按顺序写入已轮转的文件和正在写入的文件，先写临时文件再替换，返回写入的正在写入的文件字节数
+// 文件末尾没有换行时补一个换行，避免与下一个文件的第一行连在一起
+func writeFamilyCache(cachePath string, rotated []familyMember, tail string, activeInfo os.FileInfo) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".family-*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("创建轮转文件缓存失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := &lastByteWriter{w: tmp}
+	var copied int64
+	var writeErr error
+	for _, m := range rotated {
+		r, err := rotation.Open(m.file.Path, m.member)
+		if err != nil {
+			fmt.Printf("读取轮转文件失败 %s: %v\n", m.file.Path, err)
+			continue
+		}
+		_, err = io.Copy(w, r)
+		r.Close()
+		if err != nil {
+			// 压缩文件损坏时保留已解压的部分
+			fmt.Printf("读取轮转文件失败 %s: %v\n", m.file.Path, err)
+		}
+		if writeErr = w.endLine(); writeErr != nil {
+			break
+		}
+	}
+	if writeErr == nil && activeInfo != nil {
+		var file *os.File
+		if file, writeErr = os.Open(tail); writeErr == nil {
+			copied, writeErr = io.CopyN(w, file, activeInfo.Size())
+			if writeErr == io.EOF {
+				writeErr = nil
+			}
+			file.Close()
+		}
+	}
+	if err := tmp.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		return 0, fmt.Errorf("写入轮转文件缓存失败: %v", writeErr)
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return 0, fmt.Errorf("写入轮转文件缓存失败: %v", err)
+	}
+	return copied, nil
+}
+
+// appendFamilyCache 将正在写入的文件 [from, to) 范围的内容追加到缓存，返回已写入的字节数
+func appendFamilyCache(cachePath, tail string, from, to int64) (int64, error) {
+	if to == from {
+		return from, nil
+	}
+	file, err := os.Open(tail)
+	if err != nil {
+		return from, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(from, io.SeekStart); err != nil {
+		return from, err
+	}
+
+	cache, err := os.OpenFile(cachePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return from, err
+	}
+	n, err := io.CopyN(cache, file, to-from)
+	if closeErr := cache.Close(); err == nil {
+		err = closeErr
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return from + n, err
+}
+
+// lastByteWriter 记录最后写入的字节，用于在文件之间补充换行
+type lastByteWriter struct {
+	w    io.Writer
+	last byte
+	n    int64
+}
+
+// Write 写入内容
+func (l *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	if n > 0 {
+		l.last = p[n-1]
+		l.n += int64(n)
+	}
+	return n, err
+}
+
+// endLine 已写入内容且不以换行结尾时写入换行
+func (l *lastByteWriter) endLine() error {
+	if l.n == 0 || l.last == '\n' {
+		return nil
+	}
+	_, err := l.Write([]byte{'\n'})
+	return err
+}
+
+// familyEventFile 返回文件事件对应的文件组，文件不属于包含已轮转文件的文件组时返回 false
+func familyEventFile(cat *catalog.Catalog, f catalog.File) (LogFile, bool) {
+	matcher := currentRotation(config.GetConfig())
+	if matcher == nil {
+		return LogFile{}, false
+	}
+	m := matcher.Parse(filepath.Base(f.Path), time.Time{})
+	absActive, err := filepath.Abs(filepath.Join(filepath.Dir(f.Path), m.Base))
+	if err != nil {
+		return LogFile{}, false
+	}
+	files, _ := cat.Files()
+	fam, ok := familyOf(files, matcher, absActive)
+	if !ok {
+		return LogFile{}, false
+	}
+	return familyLogFile(fam), true
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/rotation"
+)
+
+func TestFamilyTail(t *testing.T) {
+	matcher := rotation.NewMatcher(rotation.DefaultRules())
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		files []string
+		tail  string
+	}{
+		{"active", []string{"app.log", "app.log.1", "app.log.2.gz"}, "app.log"},
+		{"daily without active", []string{"app-2026-10-18.log", "app-2026-10-19.log"}, "app-2026-10-19.log"},
+		// 最新的日期文件已压缩时说明已经轮转
+		{"daily compressed", []string{"app-2026-10-18.log", "app-2026-10-19.log.gz"}, "app.log"},
+		{"numeric without active", []string{"app.log.1", "app.log.2"}, "app.log"},
+	}
+	for _, tt := range tests {
+		var files []catalog.File
+		for _, name := range tt.files {
+			files = append(files, catalog.File{Path: filepath.Join(dir, name)})
+		}
+		_, families := groupFamilies(files, matcher)
+		if len(families) != 1 {
+			t.Fatalf("%s: %d families", tt.name, len(families))
+		}
+		if got := families[0].tail(filepath.Join(dir, "app.log")); got != filepath.Join(dir, tt.tail) {
+			t.Errorf("%s: tail = %s, want %s", tt.name, got, tt.tail)
+		}
+	}
+}
+
+func TestPruneFamilyCaches(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	used, idle, stale := filepath.Join(dir, "family-a-1.log"), filepath.Join(dir, "family-b-2.log"), filepath.Join(dir, "family-c-3.log")
+	for _, path := range []string{used, idle, stale} {
+		os.WriteFile(path, nil, 0644)
+	}
+
+	familyMu.Lock()
+	defer familyMu.Unlock()
+	saved, savedPruned := familyCaches, familyPruned
+	defer func() { familyCaches, familyPruned = saved, savedPruned }()
+	familyCaches = map[string]*familyCacheEntry{
+		used: {used: now.Add(-time.Minute)},
+		idle: {used: now.Add(-2 * convertCacheIdle)},
+	}
+	familyPruned = time.Time{}
+
+	pruneFamilyCaches(dir, now)
+	if _, ok := familyCaches[idle]; ok {
+		t.Error("idle cache entry kept")
+	}
+	for path, want := range map[string]bool{used: true, idle: false, stale: false} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(path), err == nil, want)
+		}
+	}
+}
+
+func TestEvictFamilyCaches(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	oldest, older, locked, newest := filepath.Join(dir, "family-a-1.log"), filepath.Join(dir, "family-b-2.log"), filepath.Join(dir, "family-c-3.log"), filepath.Join(dir, "family-d-4.log")
+	for _, path := range []string{oldest, older, locked, newest} {
+		os.WriteFile(path, make([]byte, 1<<20), 0644)
+	}
+
+	familyMu.Lock()
+	saved := familyCaches
+	familyCaches = map[string]*familyCacheEntry{
+		oldest: {used: now.Add(-4 * time.Minute)},
+		locked: {used: now.Add(-3 * time.Minute)},
+		older:  {used: now.Add(-2 * time.Minute)},
+		newest: {used: now.Add(-time.Minute)},
+	}
+	// 正在合并的缓存即使最久未使用也不删除
+	familyCaches[locked].mu.Lock()
+	familyMu.Unlock()
+	defer func() {
+		familyMu.Lock()
+		familyCaches = saved
+		familyMu.Unlock()
+	}()
+
+	evictFamilyCaches(dir, 2<<20)
+	for path, want := range map[string]bool{oldest: false, older: false, locked: true, newest: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(path), err == nil, want)
+		}
+		if _, ok := familyCaches[path]; ok != want {
+			t.Errorf("%s cached = %v, want %v", filepath.Base(path), ok, want)
+		}
+	}
+
+	// 不限制时不删除
+	evictFamilyCaches(dir, 0)
+	if _, err := os.Stat(newest); err != nil {
+		t.Error("cache removed without limit")
+	}
+}
+
+func TestFamilyCacheLimit(t *testing.T) {
+	tests := []struct {
+		configured int
+		want       int64
+	}{
+		{0, defaultFamilyCacheMaxMB << 20},
+		{10, 10 << 20},
+		{-1, -1 << 20},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		cfg.Logs.Rotation.CacheMaxMB = tt.configured
+		if got := familyCacheLimit(cfg); got != tt.want {
+			t.Errorf("familyCacheLimit(%d) = %d, want %d", tt.configured, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/anjude/log-tools/stats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字段统计的默认参数，适用于nginx访问日志
+var (
+	defaultTopFields     = []string{"status", "path", "remote_addr"}
+	defaultNumericFields = []string{"request_time", "body_bytes_sent"}
+)
+
+// 字段统计的默认TopN数量和状态码字段
+const (
+	defaultTopN        = 10
+	defaultStatusField = "status"
+)
+
+// FieldStatsRequest 字段统计请求结构
+type FieldStatsRequest struct {
+	Files         []string          `json:"files" binding:"required"` // 要统计的文件路径列表
+	Start         string            `json:"start"`                    // 可选，开始时间
+	End           string            `json:"end"`                      // 可选，结束时间
+	Pattern       string            `json:"pattern"`                  // 可选，仅统计匹配搜索模式的行
+	Fields        map[string]string `json:"fields"`                   // 可选，按字段过滤
+	TopFields     []string          `json:"top_fields"`               // 统计TopN的字段
+	NumericFields []string          `json:"numeric_fields"`           // 统计数值摘要的字段
+	TopN          int               `json:"top_n"`                    // TopN数量，默认10
+	StatusField   string            `json:"status_field"`             // 状态码字段，默认 status
+}
+
+// FieldStatsResult 字段统计结果
+type FieldStatsResult struct {
+	Scanned       int64                         `json:"scanned"`            // 扫描的行数
+	Matched       int64                         `json:"matched"`            // 参与统计的行数
+	Top           map[string][]stats.ValueCount `json:"top"`                // 各字段TopN值
+	Distinct      map[string]int                `json:"distinct"`           // 各字段不同值数量
+	Numeric       map[string]stats.Summary      `json:"numeric"`            // 数值字段摘要
+	StatusClasses map[string]int64              `json:"status_classes"`     // 状态码分类统计
+	Formats       map[string]string             `json:"formats"`            // 各文件使用的格式
+	Redacted      int                           `json:"redacted,omitempty"` // 脱敏命中次数
+}
+
+// GetFieldStats 统计解析后字段的TopN值、数值分布和状态码分类
+// 逐行流式处理，不会将文件整体读入内存
+func GetFieldStats(c *gin.Context) {
+	var req FieldStatsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	src, err := newClusterSource(req.Files, req.Pattern, req.Start, req.End, newRedaction(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	topFields := req.TopFields
+	numericFields := req.NumericFields
+	if len(topFields) == 0 && len(numericFields) == 0 {
+		topFields = defaultTopFields
+		numericFields = defaultNumericFields
+	}
+	topN := req.TopN
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+	statusField := req.StatusField
+	if statusField == "" {
+		statusField = defaultStatusField
+	}
+
+	fmt.Printf("字段统计请求: 文件=%v, 字段=%v, 数值字段=%v\n", req.Files, topFields, numericFields)
+
+	counters := make(map[string]*stats.Counter)
+	for _, field := range topFields {
+		counters[field] = stats.NewCounter(0)
+	}
+	sketches := make(map[string]*stats.Sketch)
+	for _, field := range numericFields {
+		sketches[field] = stats.NewSketch()
+	}
+
+	result := FieldStatsResult{
+		StatusClasses: make(map[string]int64),
+		Formats:       make(map[string]string),
+	}
+
+	for _, filePath := range src.files {
+		p := parserForFile(filePath)
+		result.Formats[filePath] = p.Name()
+
+		var tracker lineTimeTracker
+		err := scanFileLines(filePath, func(lineNumber int, line string) bool {
+			if strings.TrimSpace(line) == "" {
+				return true
+			}
+			result.Scanned++
+
+			record := parseFileLine(p, lineNumber, line)
+			if !tracker.inRangeRecord(record, src.window) {
+				return true
+			}
+			if src.query != nil && !matchesSearchQuery(line, src.query) {
+				return true
+			}
+			if len(req.Fields) > 0 {
+				filtered := record
+				if matchLine := src.redaction.Silent(line); matchLine != line {
+					filtered = parseLine(p, matchLine)
+				}
+				if !matchesFieldFilters(filtered, req.Fields) {
+					return true
+				}
+			}
+			result.Matched++
+
+			for field, counter := range counters {
+				if value, ok := record.Field(field); ok {
+					counter.Add(normalizeFieldValue(value))
+				}
+			}
+			for field, sketch := range sketches {
+				if value, ok := record.Field(field); ok {
+					if v, err := strconv.ParseFloat(normalizeFieldValue(value), 64); err == nil {
+						sketch.Add(v)
+					}
+				}
+			}
+			if status, ok := record.Field(statusField); ok {
+				if class := statusClass(normalizeFieldValue(status)); class != "" {
+					result.StatusClasses[class]++
+				}
+			}
+			return true
+		})
+		if err != nil {
+			fmt.Printf("字段统计读取文件失败 %s: %v\n", filePath, err)
+		}
+	}
+
+	redaction := newRedaction(c)
+	result.Top = make(map[string][]stats.ValueCount)
+	result.Distinct = make(map[string]int)
+	for field, counter := range counters {
+		top := counter.Top(topN)
+		for i := range top {
+			top[i].Value = redaction.String(top[i].Value)
+		}
+		result.Top[field] = top
+		result.Distinct[field] = counter.Distinct()
+	}
+	result.Numeric = make(map[string]stats.Summary)
+	for field, sketch := range sketches {
+		result.Numeric[field] = sketch.Summary()
+	}
+
+	result.Redacted = redaction.Total()
+	auditRedaction(c, redaction)
+	c.JSON(http.StatusOK, result)
+}
+
+// normalizeFieldValue 规范化字段值，去除首尾空白和成对的引号、合并连续空白，
+// 使不同格式或写法（如 "200"、 200 、"GET  /a"）的同一个值计为一项
+func normalizeFieldValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+	if strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+		value = strings.Join(strings.Fields(value), " ")
+	}
+	return value
+}
+
+// statusClass 返回HTTP状态码分类，如 "2xx"，非状态码返回空字符串
+func statusClass(status string) string {
+	code, err := strconv.Atoi(status)
+	if err != nil || code < 100 || code > 599 {
+		return ""
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
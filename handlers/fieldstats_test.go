@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNormalizeFieldValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"200", "200"},
+		{" 200\t", "200"},
+		{`"200"`, "200"},
+		{`'/api/users'`, "/api/users"},
+		{`" GET  /a "`, "GET /a"},
+		{`"unbalanced`, `"unbalanced`},
+		{`""`, ""},
+		{`"`, `"`},
+	}
+	for _, tt := range tests {
+		if got := normalizeFieldValue(tt.in); got != tt.want {
+			t.Errorf("normalizeFieldValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetFieldStats(t *testing.T) {
+	dir := t.TempDir()
+	path := writeLog(t, dir, "access.log",
+		`{"status":200,"path":"/api/users","request_time":0.1,"user":"a"}`,
+		`{"status":" 200","path":"\"/api/users\"","request_time":"0.3","user":"b"}`,
+		`{"status":"404","path":"/api/users ","request_time":0.2,"user":"a"}`,
+		`{"status":500,"path":"/api/orders","request_time":"slow","user":"a"}`,
+		``,
+	)
+	useConfig(t, testConfig(dir, false))
+
+	tests := []struct {
+		name     string
+		body     map[string]interface{}
+		matched  float64
+		top      map[string]map[string]float64
+		classes  map[string]float64
+		distinct map[string]float64
+		count    float64 // request_time 中能解析为数值的行数
+	}{
+		{
+			name:     "all",
+			body:     map[string]interface{}{"top_fields": []string{"status", "path"}, "numeric_fields": []string{"request_time"}},
+			matched:  4,
+			top:      map[string]map[string]float64{"status": {"200": 2, "404": 1, "500": 1}, "path": {"/api/users": 3, "/api/orders": 1}},
+			classes:  map[string]float64{"2xx": 2, "4xx": 1, "5xx": 1},
+			distinct: map[string]float64{"status": 3, "path": 2},
+			count:    3,
+		},
+		{
+			name:     "field filter",
+			body:     map[string]interface{}{"top_fields": []string{"status"}, "numeric_fields": []string{"request_time"}, "fields": map[string]string{"user": "a"}},
+			matched:  3,
+			top:      map[string]map[string]float64{"status": {"200": 1, "404": 1, "500": 1}},
+			classes:  map[string]float64{"2xx": 1, "4xx": 1, "5xx": 1},
+			distinct: map[string]float64{"status": 3},
+			count:    2,
+		},
+		{
+			name:     "pattern",
+			body:     map[string]interface{}{"top_fields": []string{"path"}, "numeric_fields": []string{"request_time"}, "pattern": "orders"},
+			matched:  1,
+			top:      map[string]map[string]float64{"path": {"/api/orders": 1}},
+			classes:  map[string]float64{"5xx": 1},
+			distinct: map[string]float64{"path": 1},
+			count:    0,
+		},
+	}
+	for _, tt := range tests {
+		tt.body["files"] = []string{path}
+		code, response := serve(t, GetFieldStats, http.MethodPost, "/", tt.body)
+		if code != http.StatusOK {
+			t.Fatalf("%s: status %d: %v", tt.name, code, response["error"])
+		}
+		if response["scanned"] != 4.0 || response["matched"] != tt.matched {
+			t.Errorf("%s: scanned %v, matched %v, want 4, %v", tt.name, response["scanned"], response["matched"], tt.matched)
+		}
+		top, _ := response["top"].(map[string]interface{})
+		for field, want := range tt.top {
+			got := make(map[string]float64)
+			values, _ := top[field].([]interface{})
+			for _, v := range values {
+				item, _ := v.(map[string]interface{})
+				got[item["value"].(string)] = item["count"].(float64)
+			}
+			if !equalCounts(got, want) {
+				t.Errorf("%s: top %s = %v, want %v", tt.name, field, got, want)
+			}
+		}
+		classes, _ := response["status_classes"].(map[string]interface{})
+		if !equalCounts(toCounts(classes), tt.classes) {
+			t.Errorf("%s: status classes = %v, want %v", tt.name, classes, tt.classes)
+		}
+		distinct, _ := response["distinct"].(map[string]interface{})
+		if !equalCounts(toCounts(distinct), tt.distinct) {
+			t.Errorf("%s: distinct = %v, want %v", tt.name, distinct, tt.distinct)
+		}
+		numeric, _ := response["numeric"].(map[string]interface{})
+		summary, _ := numeric["request_time"].(map[string]interface{})
+		if summary["count"] != tt.count {
+			t.Errorf("%s: request_time count = %v, want %v", tt.name, summary["count"], tt.count)
+		}
+	}
+
+	if code, _ := serve(t, GetFieldStats, http.MethodPost, "/", map[string]interface{}{"files": []string{"missing.log"}}); code != http.StatusBadRequest {
+		t.Errorf("missing file: status %d", code)
+	}
+}
+
+// toCounts 将 JSON 对象转换为计数
+func toCounts(m map[string]interface{}) map[string]float64 {
+	counts := make(map[string]float64, len(m))
+	for k, v := range m {
+		counts[k], _ = v.(float64)
+	}
+	return counts
+}
+
+func equalCounts(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
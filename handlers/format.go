@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+
+	"github.com/gin-gonic/gin"
+)
+
+// detectSampleLines 自动检测格式时读取的样本行数
+const detectSampleLines = 50
+
+// detectedFormat 自动检测结果缓存，文件修改时间变化后重新检测
+type detectedFormat struct {
+	modTime time.Time
+	parser  parser.Parser
+}
+
+var (
+	detectCache   = make(map[string]detectedFormat)
+	detectCacheMu sync.Mutex
+)
+
+// parserForFile 获取文件对应的解析器：按配置规则顺序匹配，未匹配或配置为 auto 时自动检测
+// 非文本格式的解析器会记录该文件的解析成功和失败次数
+func parserForFile(absPath string) parser.Parser {
+	name := assignedParserName(absPath)
+	p, err := parser.Lookup(name)
+	if err != nil {
+		fmt.Printf("文件 %s 的解析器配置错误: %v\n", absPath, err)
+	}
+	if p == nil {
+		p = detectParser(absPath)
+	}
+	if p.Name() == parser.NameText {
+		return p
+	}
+	return &trackedParser{Parser: p, stats: formatStatsFor(absPath, p.Name())}
+}
+
+// assignedParserName 按配置规则查找文件分配的解析器名称
+func assignedParserName(absPath string) string {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return ""
+	}
+
+	// journal 转换后的文本为 RFC5424 syslog 格式
+	if isJournalCache(absPath) {
+		return parser.NameSyslog
+	}
+
+	// 轮转文件组的缓存按其活动文件分配解析器
+	absPath = familyOrigin(absPath)
+
+	// 日志源配置的解析器优先于 parsers 规则
+	if source, ok := sourceOf(cfg, absPath); ok && source.Parser != "" {
+		return source.Parser
+	}
+
+	for _, rule := range cfg.Logs.Parsers {
+		switch {
+		case rule.File != "":
+			if samePath(rule.File, absPath) {
+				return rule.Parser
+			}
+		case rule.Directory != "":
+			if pathWithin(rule.Directory, absPath) {
+				return rule.Parser
+			}
+		case rule.Pattern != "":
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				fmt.Printf("解析器规则正则错误 %s: %v\n", rule.Pattern, err)
+				continue
+			}
+			if re.MatchString(filepath.Base(absPath)) {
+				return rule.Parser
+			}
+		}
+	}
+	return ""
+}
+
+// samePath 判断配置中的路径与绝对路径是否指向同一文件
+func samePath(configured, absPath string) bool {
+	abs, err := filepath.Abs(configured)
+	if err != nil {
+		return false
+	}
+	return abs == absPath
+}
+
+// pathWithin 判断绝对路径是否位于配置的目录内
+func pathWithin(dir, absPath string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// detectParser 读取文件开头的样本行自动检测格式
+func detectParser(absPath string) parser.Parser {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return parser.Detect(nil)
+	}
+
+	detectCacheMu.Lock()
+	cached, ok := detectCache[absPath]
+	detectCacheMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.parser
+	}
+
+	var samples []string
+	scanFileLines(absPath, func(_ int, line string) bool {
+		samples = append(samples, line)
+		return len(samples) < detectSampleLines
+	})
+	p := parser.Detect(samples)
+
+	detectCacheMu.Lock()
+	detectCache[absPath] = detectedFormat{modTime: info.ModTime(), parser: p}
+	detectCacheMu.Unlock()
+
+	return p
+}
+
+// parseLine 使用解析器解析一行，解析失败时退回文本解析
+func parseLine(p parser.Parser, line string) *parser.Record {
+	if record, ok := p.Parse(line); ok {
+		return record
+	}
+	text, _ := parser.Get(parser.NameText)
+	record, _ := text.Parse(line)
+	return record
+}
+
+// parseFileLine 解析文件的第 lineNumber 行，记录格式解析统计；无法解析时返回只包含消息的记录
+func parseFileLine(p parser.Parser, lineNumber int, line string) *parser.Record {
+	t, ok := p.(*trackedParser)
+	if !ok {
+		return parseLine(p, line)
+	}
+	if record, ok := t.ParseLine(lineNumber, line); ok {
+		return record
+	}
+	return parseLine(t.Parser, line)
+}
+
+// matchesFieldFilters 检查记录字段是否满足过滤条件（不区分大小写的相等比较）
+func matchesFieldFilters(record *parser.Record, filters map[string]string) bool {
+	for name, expected := range filters {
+		value, ok := record.Field(name)
+		if !ok || !strings.EqualFold(value, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetLogFormats 获取所有可用的日志格式解析器
+func GetLogFormats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"formats": parser.Names(),
+		"rules":   config.GetConfig().Logs.Parsers,
+	})
+}
+
+// maxFailureSamples 每个文件保留的解析失败样本数
+const maxFailureSamples = 5
+
+// FormatStats 单个文件的格式解析统计
+type FormatStats struct {
+	File     string   `json:"file"`      // 文件路径
+	Format   string   `json:"format"`    // 使用的格式
+	Parsed   int64    `json:"parsed"`    // 解析成功行数
+	Failed   int64    `json:"failed"`    // 解析失败行数
+	Samples  []string `json:"samples"`   // 最近的解析失败样本
+	LastFail string   `json:"last_fail"` // 最近一次解析失败的时间
+
+	counted lineSet // 已统计的行号
+}
+
+// lineRange 连续的行号区间 [from, to]
+type lineRange struct {
+	from, to int
+}
+
+// lineSet 行号集合，按连续区间升序保存，顺序读取的行合并为一个区间
+type lineSet []lineRange
+
+// add 加入行号，已存在时返回 false
+func (s *lineSet) add(n int) bool {
+	ranges := *s
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].to >= n-1 })
+	switch {
+	case i < len(ranges) && ranges[i].from <= n && n <= ranges[i].to:
+		return false
+	case i < len(ranges) && ranges[i].to == n-1:
+		ranges[i].to = n
+		if i+1 < len(ranges) && ranges[i+1].from == n+1 {
+			ranges[i].to = ranges[i+1].to
+			ranges = append(ranges[:i+1], ranges[i+2:]...)
+		}
+	case i < len(ranges) && ranges[i].from == n+1:
+		ranges[i].from = n
+	default:
+		ranges = append(ranges, lineRange{})
+		copy(ranges[i+1:], ranges[i:])
+		ranges[i] = lineRange{from: n, to: n}
+	}
+	*s = ranges
+	return true
+}
+
+var (
+	formatStats   = make(map[string]*FormatStats)
+	formatStatsMu sync.Mutex
+)
+
+// forgetFormatStats 删除文件的解析统计，文件被删除或截断后行号不再对应原来的内容
+func forgetFormatStats(absPath string) {
+	formatStatsMu.Lock()
+	defer formatStatsMu.Unlock()
+	delete(formatStats, absPath)
+}
+
+// formatStatsFor 获取文件的解析统计，格式变化时重新计数
+func formatStatsFor(absPath, format string) *FormatStats {
+	formatStatsMu.Lock()
+	defer formatStatsMu.Unlock()
+	stats, ok := formatStats[absPath]
+	if !ok || stats.Format != format {
+		stats = &FormatStats{File: absPath, Format: format}
+		formatStats[absPath] = stats
+	}
+	return stats
+}
+
+// trackedParser 记录解析统计的解析器包装
+// Parse 不记录统计，用于时间、级别跟踪等辅助解析；读取文件内容时通过 ParseLine 按行号记录，
+// 同一文件的同一行无论被读取多少次只统计一次
+type trackedParser struct {
+	parser.Parser
+	stats *FormatStats
+}
+
+// ParseLine 解析文件的第 lineNumber 行并记录统计，已统计过的行不再计数
+func (t *trackedParser) ParseLine(lineNumber int, line string) (*parser.Record, bool) {
+	record, ok := t.Parser.Parse(line)
+
+	formatStatsMu.Lock()
+	defer formatStatsMu.Unlock()
+	if !t.stats.counted.add(lineNumber) {
+		return record, ok
+	}
+	if ok {
+		t.stats.Parsed++
+		return record, ok
+	}
+	if strings.TrimSpace(line) == "" {
+		return record, ok
+	}
+	t.stats.Failed++
+	t.stats.LastFail = time.Now().Format("2006-01-02 15:04:05")
+	t.stats.Samples = append(t.stats.Samples, line)
+	if len(t.stats.Samples) > maxFailureSamples {
+		t.stats.Samples = t.stats.Samples[1:]
+	}
+	return record, ok
+}
+
+// RegisterFormats 根据配置注册自定义日志格式，配置重新加载时调用
+func RegisterFormats(cfg *config.Config) {
+	// viper 会将map的键转为小写，grok模式名称统一使用大写
+	patterns := make(map[string]string)
+	for name, pattern := range cfg.Logs.GrokPatterns {
+		patterns[strings.ToUpper(name)] = pattern
+	}
+
+	defs := make([]parser.Definition, 0, len(cfg.Logs.Formats))
+	for _, format := range cfg.Logs.Formats {
+		defs = append(defs, formatDefinition(format, patterns))
+	}
+
+	for _, err := range parser.RegisterCustom(defs) {
+		fmt.Printf("自定义日志格式加载失败: %v\n", err)
+	}
+	fmt.Printf("日志格式: %v\n", parser.Names())
+
+	// 格式定义变化后，之前的自动检测结果不再可靠
+	detectCacheMu.Lock()
+	detectCache = make(map[string]detectedFormat)
+	detectCacheMu.Unlock()
+}
+
+// formatDefinition 将配置中的自定义格式转换为解析器定义
+func formatDefinition(format config.FormatConfig, patterns map[string]string) parser.Definition {
+	return parser.Definition{
+		Name:            format.Name,
+		Grok:            format.Grok,
+		Regex:           format.Regex,
+		TimestampField:  format.TimestampField,
+		TimestampLayout: format.TimestampLayout,
+		LevelField:      format.LevelField,
+		MessageField:    format.MessageField,
+		Patterns:        patterns,
+	}
+}
+
+// GetFormatStats 获取各文件的格式解析统计
+// 解析失败样本保存的是原始行，返回前按当前用户脱敏
+func GetFormatStats(c *gin.Context) {
+	formatStatsMu.Lock()
+	stats := make([]FormatStats, 0, len(formatStats))
+	for _, s := range formatStats {
+		item := *s
+		item.Samples = append([]string(nil), s.Samples...)
+		stats = append(stats, item)
+	}
+	formatStatsMu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].File < stats[j].File
+	})
+
+	redaction := newRedaction(c)
+	for i := range stats {
+		for j := range stats[i].Samples {
+			stats[i].Samples[j] = redaction.String(stats[i].Samples[j])
+		}
+	}
+
+	response := gin.H{
+		"stats": stats,
+	}
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// FormatTestRequest 格式测试请求结构
+// 指定 Format 时测试已注册的格式，否则使用请求中的 grok/regex 定义
+type FormatTestRequest struct {
+	Format          string   `json:"format"`                     // 已注册的格式名称
+	Grok            string   `json:"grok"`                       // grok风格模式
+	Regex           string   `json:"regex"`                      // Go正则
+	TimestampField  string   `json:"timestamp_field"`            // 时间字段名
+	TimestampLayout string   `json:"timestamp_layout"`           // 时间格式
+	LevelField      string   `json:"level_field"`                // 级别字段名
+	MessageField    string   `json:"message_field"`              // 消息字段名
+	Samples         []string `json:"samples" binding:"required"` // 样本行
+}
+
+// FormatTestResult 单行格式测试结果
+type FormatTestResult struct {
+	Line    string         `json:"line"`             // 样本行
+	Matched bool           `json:"matched"`          // 是否解析成功
+	Record  *parser.Record `json:"record,omitempty"` // 解析结果
+	Error   string         `json:"error,omitempty"`  // 失败原因
+}
+
+// TestFormat 使用样本行测试日志格式
+func TestFormat(c *gin.Context) {
+	var req FormatTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	var p parser.Parser
+	if req.Format != "" {
+		found, ok := parser.Get(req.Format)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("未知的日志格式: %s", req.Format),
+			})
+			return
+		}
+		p = found
+	} else {
+		patterns := make(map[string]string)
+		for name, pattern := range config.GetConfig().Logs.GrokPatterns {
+			patterns[strings.ToUpper(name)] = pattern
+		}
+		custom, err := parser.NewCustomParser(formatDefinition(config.FormatConfig{
+			Name:            "test",
+			Grok:            req.Grok,
+			Regex:           req.Regex,
+			TimestampField:  req.TimestampField,
+			TimestampLayout: req.TimestampLayout,
+			LevelField:      req.LevelField,
+			MessageField:    req.MessageField,
+		}, patterns))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		p = custom
+	}
+
+	results := make([]FormatTestResult, 0, len(req.Samples))
+	matched := 0
+	for _, line := range req.Samples {
+		result := FormatTestResult{Line: line}
+		if explainer, ok := p.(parser.Explainer); ok {
+			record, err := explainer.Explain(line)
+			result.Record = record
+			if err != nil {
+				result.Error = err.Error()
+			}
+		} else if record, ok := p.Parse(line); ok {
+			result.Record = record
+		} else {
+			result.Error = fmt.Sprintf("行与格式 %s 不匹配", p.Name())
+		}
+		result.Matched = result.Error == ""
+		if result.Matched {
+			matched++
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"format":  p.Name(),
+		"results": results,
+		"matched": matched,
+		"total":   len(req.Samples),
+	})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/anjude/log-tools/parser"
+)
+
+func TestLineSet(t *testing.T) {
+	tests := []struct {
+		add  []int
+		want string
+	}{
+		{[]int{1, 2, 3}, "[{1 3}]"},
+		{[]int{3, 1, 2}, "[{1 3}]"},
+		{[]int{5, 1, 3}, "[{1 1} {3 3} {5 5}]"},
+		{[]int{5, 1, 3, 2, 4}, "[{1 5}]"},
+		{[]int{10, 9, 20, 11}, "[{9 11} {20 20}]"},
+	}
+	for _, tt := range tests {
+		var s lineSet
+		for _, n := range tt.add {
+			if !s.add(n) {
+				t.Errorf("%v: add(%d) reported duplicate", tt.add, n)
+			}
+		}
+		if got := fmt.Sprint([]lineRange(s)); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.add, got, tt.want)
+		}
+		for _, n := range tt.add {
+			if s.add(n) {
+				t.Errorf("%v: add(%d) twice counted again", tt.add, n)
+			}
+		}
+	}
+}
+
+func TestTrackedParserCountsLinesOnce(t *testing.T) {
+	p, _ := parser.Get(parser.NameJSON)
+	tracked := &trackedParser{Parser: p, stats: &FormatStats{}}
+	lines := []string{`{"level":"info"}`, `not json`, `{"level":"warn"}`}
+
+	// 同一批行被多次读取（重复请求、辅助解析）只统计一次
+	for round := 0; round < 3; round++ {
+		for i, line := range lines {
+			parseFileLine(tracked, i+1, line)
+			tracked.Parse(line)
+		}
+	}
+	if tracked.stats.Parsed != 2 || tracked.stats.Failed != 1 || len(tracked.stats.Samples) != 1 {
+		t.Fatalf("parsed=%d failed=%d samples=%v", tracked.stats.Parsed, tracked.stats.Failed, tracked.stats.Samples)
+	}
+}
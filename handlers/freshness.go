@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/gin-gonic/gin"
+)
+
+// SourceFreshness 配置了新鲜度要求的日志源的写入情况
+type SourceFreshness struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Freshness   string `json:"freshness"`            // 新鲜度要求
+	LastWrite   string `json:"last_write,omitempty"` // 最近一次写入时间，日志源中没有文件时为空
+	LastFile    string `json:"last_file,omitempty"`  // 最近写入的文件
+	Silence     int64  `json:"silence"`              // 距最近一次写入的秒数，日志源中没有文件时为 -1
+	Stale       bool   `json:"stale"`                // 是否已超过新鲜度要求没有写入
+}
+
+// lastWrite 日志源中最近写入的文件
+type lastWrite struct {
+	path string
+	at   time.Time
+}
+
+// sourceLastWrites 返回各日志源最近写入的文件
+// 目录中的文件使用文件目录记录的修改时间（随文件事件更新），确定的文件读取文件信息
+func sourceLastWrites(cfg *config.Config) map[string]lastWrite {
+	writes := make(map[string]lastWrite)
+	observe := func(source, path string, at time.Time) {
+		if last, ok := writes[source]; !ok || at.After(last.at) {
+			writes[source] = lastWrite{path: path, at: at}
+		}
+	}
+
+	files, _ := catalogFiles(cfg)
+	for _, f := range files {
+		observe(f.Source, f.Path, f.ModTime)
+	}
+	for _, source := range cfg.LogSources() {
+		for _, file := range source.Files {
+			absPath, err := filepath.Abs(file)
+			if err != nil {
+				continue
+			}
+			if info, err := os.Stat(absPath); err == nil {
+				observe(source.Name, file, info.ModTime())
+			}
+		}
+	}
+	return writes
+}
+
+// sourceFreshness 返回配置了新鲜度要求的日志源的写入情况，按配置顺序
+func sourceFreshness(cfg *config.Config, now time.Time) []SourceFreshness {
+	var result []SourceFreshness
+	var writes map[string]lastWrite
+	for _, source := range cfg.LogSources() {
+		freshness, err := source.FreshnessDuration()
+		if err != nil || freshness == 0 {
+			continue
+		}
+		if writes == nil {
+			writes = sourceLastWrites(cfg)
+		}
+
+		item := SourceFreshness{
+			Name:        source.Name,
+			DisplayName: source.Title(),
+			Freshness:   source.Freshness,
+			Silence:     -1,
+			Stale:       true,
+		}
+		if last, ok := writes[source.Name]; ok {
+			silence := now.Sub(last.at)
+			item.LastWrite = last.at.Format("2006-01-02 15:04:05")
+			item.LastFile = last.path
+			item.Silence = int64(max(silence, 0) / time.Second)
+			item.Stale = silence > freshness
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// GetStaleSources 列出当前已停止写入的日志源：超过新鲜度要求没有任何文件写入，或日志源中没有文件
+func GetStaleSources(c *gin.Context) {
+	cfg := config.GetConfig()
+	now := time.Now()
+	all := sourceFreshness(cfg, now)
+	stale := make([]SourceFreshness, 0, len(all))
+	for _, item := range all {
+		if item.Stale {
+			stale = append(stale, item)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sources": stale,
+		"checked": len(all),
+		"time":    now.Format("2006-01-02 15:04:05"),
+	})
+}
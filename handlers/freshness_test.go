@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+)
+
+func TestSourceFreshness(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	touch := func(path string, age time.Duration) string {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x\n"), 0644)
+		at := now.Add(-age)
+		os.Chtimes(path, at, at)
+		return path
+	}
+	touch(filepath.Join(root, "web", "old.log"), time.Hour)
+	fresh := touch(filepath.Join(root, "web", "new.log"), time.Minute)
+	stale := touch(filepath.Join(root, "batch", "run.log"), 3*time.Hour)
+	pinned := touch(filepath.Join(root, "pinned.log"), 10*time.Minute)
+	os.MkdirAll(filepath.Join(root, "empty"), 0755)
+
+	cfg := &config.Config{Logs: config.LogsConfig{
+		Pattern: `\.log$`,
+		Sources: []config.SourceConfig{
+			{Name: "web", Directories: []config.DirectoryConfig{{Path: filepath.Join(root, "web")}}, Freshness: "5m"},
+			{Name: "batch", Directories: []config.DirectoryConfig{{Path: filepath.Join(root, "batch")}}, Freshness: "2h"},
+			{Name: "empty", Directories: []config.DirectoryConfig{{Path: filepath.Join(root, "empty")}}, Freshness: "1d"},
+			// 确定的文件同样计入
+			{Name: "pinned", Files: []string{pinned}, Freshness: "5m"},
+			{Name: "unchecked", Directories: []config.DirectoryConfig{{Path: filepath.Join(root, "batch")}}},
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		stale    bool
+		lastFile string
+		silence  int64
+	}{
+		{"web", false, fresh, 60},
+		{"batch", true, stale, 3 * 3600},
+		{"empty", true, "", -1},
+		{"pinned", true, pinned, 600},
+	}
+	got := sourceFreshness(cfg, now)
+	if len(got) != len(tests) {
+		t.Fatalf("%d sources, want %d: %+v", len(got), len(tests), got)
+	}
+	for i, tt := range tests {
+		item := got[i]
+		if item.Name != tt.name || item.Stale != tt.stale || item.LastFile != tt.lastFile {
+			t.Errorf("%s: %+v", tt.name, item)
+		}
+		// 修改时间的精度取决于文件系统
+		if item.Silence < tt.silence-1 || item.Silence > tt.silence+1 {
+			t.Errorf("%s: silence %d, want %d", tt.name, item.Silence, tt.silence)
+		}
+		if (item.LastWrite == "") != (tt.lastFile == "") {
+			t.Errorf("%s: last write %q", tt.name, item.LastWrite)
+		}
+	}
+
+	useConfig(t, cfg)
+	code, response := serve(t, GetStaleSources, http.MethodGet, "/", nil)
+	if code != http.StatusOK {
+		t.Fatalf("status %d: %v", code, response["error"])
+	}
+	var names []string
+	sources, _ := response["sources"].([]interface{})
+	for _, s := range sources {
+		source, _ := s.(map[string]interface{})
+		names = append(names, source["name"].(string))
+	}
+	if response["checked"] != 4.0 || len(names) != 3 || names[0] != "batch" || names[1] != "empty" || names[2] != "pinned" {
+		t.Errorf("stale sources = %v, checked %v", names, response["checked"])
+	}
+
+	// 文件列表中的日志源信息同样标记
+	for _, info := range sourceInfos(cfg) {
+		if want := info.Name != "web" && info.Name != "unchecked"; info.Stale != want {
+			t.Errorf("source info %s: stale %v, want %v", info.Name, info.Stale, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anjude/log-tools/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testConfig 返回以 dir 为 default 日志源目录的配置，脱敏按 redaction 开启
+func testConfig(dir string, redaction bool) *config.Config {
+	return &config.Config{
+		Redaction: config.RedactionConfig{Enabled: redaction},
+		Logs: config.LogsConfig{
+			Directories:      []config.DirectoryConfig{{Path: dir}},
+			Pattern:          `\.log$`,
+			DefaultLines:     200,
+			MaxSearchResults: 1000,
+		},
+	}
+}
+
+// useConfig 设置测试使用的全局配置和脱敏规则，测试结束后恢复
+func useConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	old := config.GetConfig()
+	config.SetConfig(cfg)
+	RegisterRedaction(cfg)
+	t.Cleanup(func() {
+		config.SetConfig(old)
+		if old != nil {
+			RegisterRedaction(old)
+		} else {
+			RegisterRedaction(&config.Config{})
+		}
+	})
+}
+
+// writeLog 在目录中写入日志文件，返回绝对路径
+func writeLog(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serve 调用接口，body 不为空时作为 JSON 请求体，返回状态码和解析后的响应
+func serve(t *testing.T, handler gin.HandlerFunc, method, target string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, reader)
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%s %s: invalid response %q", method, target, w.Body.String())
+	}
+	return w.Code, response
+}
+
+// jsonStrings 将 JSON 数组转换为字符串列表
+func jsonStrings(v interface{}) []string {
+	list, _ := v.([]interface{})
+	result := make([]string, len(list))
+	for i, item := range list {
+		result[i], _ = item.(string)
+	}
+	return result
+}
@@ -0,0 +1,631 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/journal"
+
+	"github.com/gin-gonic/gin"
+)
+
+// journalScheme journal 虚拟日志源的路径前缀
+// 格式为 journal://<单元>?priority=<优先级>&boot=<启动ID>，单元为空表示全部单元
+const journalScheme = "journal://"
+
+// journalSourceTitle 文件列表中 journal 虚拟日志源的显示名称
+const journalSourceTitle = "systemd journal"
+
+// journalStat 单元或启动的条目统计
+type journalStat struct {
+	entries int
+	bytes   int64 // 转换为文本后的大小
+	first   time.Time
+	last    time.Time
+}
+
+// add 累加一个条目
+func (s *journalStat) add(t time.Time, size int) {
+	s.entries++
+	s.bytes += int64(size)
+	if s.first.IsZero() || t.Before(s.first) {
+		s.first = t
+	}
+	if t.After(s.last) {
+		s.last = t
+	}
+}
+
+// merge 合并另一个统计
+func (s *journalStat) merge(other *journalStat) {
+	s.entries += other.entries
+	s.bytes += other.bytes
+	if s.first.IsZero() || (!other.first.IsZero() && other.first.Before(s.first)) {
+		s.first = other.first
+	}
+	if other.last.After(s.last) {
+		s.last = other.last
+	}
+}
+
+// journalFileSummary 单个 journal 文件的统计缓存
+// 文件大小和修改时间不变时直接使用；正在写入的文件从上次读取到的位置继续统计新条目
+type journalFileSummary struct {
+	path     string
+	size     int64
+	modTime  time.Time
+	position journal.Position
+	units    map[string]*journalStat
+	boots    map[string]*journalStat
+}
+
+// journalCacheEntry 转换后的文本缓存
+// signature 记录各 journal 文件的大小和修改时间，没有变化时直接使用缓存；
+// 有变化时按各文件（文件ID）已转换到的位置只追加新写入的条目
+type journalCacheEntry struct {
+	mu        sync.Mutex
+	signature string
+	positions map[string]journal.Position // 文件ID -> 已转换到的位置，为 nil 时需要重新生成
+	last      time.Time                   // 已转换的最后一个条目的时间
+	used      time.Time                   // 最近一次使用的时间
+}
+
+var (
+	journalSummaries = make(map[string]*journalFileSummary) // 文件ID -> 统计
+	journalSummaryMu sync.Mutex
+	journalCaches    = make(map[string]*journalCacheEntry) // 缓存路径 -> 缓存
+	journalPruned    time.Time
+	journalMu        sync.Mutex
+)
+
+// isJournalSource 判断路径是否为 journal 虚拟日志源
+func isJournalSource(filePath string) bool {
+	return strings.HasPrefix(filePath, journalScheme)
+}
+
+// parseJournalSource 解析 journal 虚拟日志源路径
+func parseJournalSource(filePath string) (journal.Filter, error) {
+	filter := journal.Filter{Priority: -1}
+	spec := strings.TrimPrefix(filePath, journalScheme)
+	unit, rawQuery, _ := strings.Cut(spec, "?")
+	if strings.ContainsAny(unit, "/\\") {
+		return filter, fmt.Errorf("无效的journal单元: %s", unit)
+	}
+	filter.Unit = unit
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return filter, fmt.Errorf("无效的journal参数: %v", err)
+	}
+	if priority := values.Get("priority"); priority != "" {
+		filter.Priority, err = journal.ParsePriority(priority)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if boot := values.Get("boot"); boot != "" {
+		if len(boot) > 32 || strings.Trim(strings.ToLower(boot), "0123456789abcdef") != "" {
+			return filter, fmt.Errorf("无效的启动ID: %s", boot)
+		}
+		filter.BootID = strings.ToLower(boot)
+	}
+	return filter, nil
+}
+
+// journalSourcePath 返回过滤条件对应的规范化路径
+func journalSourcePath(filter journal.Filter) string {
+	values := url.Values{}
+	if filter.Priority >= 0 {
+		values.Set("priority", journal.PriorityNames[filter.Priority])
+	}
+	if filter.BootID != "" {
+		values.Set("boot", filter.BootID)
+	}
+	if len(values) == 0 {
+		return journalScheme + filter.Unit
+	}
+	return journalScheme + filter.Unit + "?" + values.Encode()
+}
+
+// journalFiles 返回配置的 journal 目录中的所有 journal 文件
+func journalFiles(cfg *config.Config) []string {
+	var files []string
+	for _, dir := range cfg.Logs.Journal.Directories {
+		found, err := journal.FindFiles(dir)
+		if err != nil {
+			fmt.Printf("查找journal文件失败 %s: %v\n", dir, err)
+		}
+		files = append(files, found...)
+	}
+	return files
+}
+
+// openJournalFiles 打开 journal 文件，无法打开的文件会被跳过
+func openJournalFiles(paths []string) []*journal.File {
+	var files []*journal.File
+	for _, path := range paths {
+		f, err := journal.Open(path)
+		if err != nil {
+			fmt.Printf("打开journal文件失败: %v\n", err)
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// closeJournal 关闭读取器并输出读取过程中的错误
+func closeJournal(r *journal.Reader) {
+	for _, err := range r.Errors() {
+		fmt.Printf("读取journal文件出错: %v\n", err)
+	}
+	r.Close()
+}
+
+// renderJournalEntry 将条目转换为 RFC5424 syslog 格式的文本行，MSGID 位置为单元名称
+// 这样 syslog 解析器可以识别时间、级别、主机、程序和进程号，时间和级别过滤与普通文件一致
+// 多行消息（如堆栈）的后续行缩进4个空格，作为续行沿用该条目的时间和级别
+func renderJournalEntry(e *journal.Entry) string {
+	facility, err := strconv.Atoi(e.Fields["SYSLOG_FACILITY"])
+	if err != nil || facility < 0 || facility > 23 {
+		facility = 3 // daemon
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s - %s",
+		facility*8+e.Priority(),
+		e.Realtime.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogToken(e.Fields["_HOSTNAME"]),
+		syslogToken(e.Identifier()),
+		syslogToken(e.PID()),
+		syslogToken(e.Unit()),
+		journalMessage(e.Message()))
+}
+
+// journalMessage 去除消息末尾的换行，后续行缩进，避免续行被当作新的条目
+func journalMessage(message string) string {
+	message = strings.TrimRight(message, "\r\n")
+	if !strings.ContainsAny(message, "\r\n") {
+		return message
+	}
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	message = strings.ReplaceAll(message, "\r", "\n")
+	return strings.ReplaceAll(message, "\n", "\n    ")
+}
+
+// syslogToken 转换为不含空白的 syslog 头部字段，空值使用 -
+func syslogToken(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.Join(strings.Fields(value), "_")
+}
+
+// summarizeJournal 统计所有 journal 文件中各单元和各启动的条目
+func summarizeJournal(cfg *config.Config) (map[string]*journalStat, map[string]*journalStat, int) {
+	paths := journalFiles(cfg)
+	units := make(map[string]*journalStat)
+	boots := make(map[string]*journalStat)
+
+	journalSummaryMu.Lock()
+	defer journalSummaryMu.Unlock()
+
+	byPath := make(map[string]string, len(journalSummaries))
+	for id, summary := range journalSummaries {
+		byPath[summary.path] = id
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		id, ok := byPath[path]
+		summary := journalSummaries[id]
+		if !ok || summary.size != info.Size() || !summary.modTime.Equal(info.ModTime()) {
+			if id, summary = summarizeJournalFile(path, info); summary == nil {
+				continue
+			}
+		}
+		seen[id] = true
+		for unit, stat := range summary.units {
+			if units[unit] == nil {
+				units[unit] = &journalStat{}
+			}
+			units[unit].merge(stat)
+		}
+		for boot, stat := range summary.boots {
+			if boots[boot] == nil {
+				boots[boot] = &journalStat{}
+			}
+			boots[boot].merge(stat)
+		}
+	}
+
+	// 清理已删除文件的缓存
+	for id := range journalSummaries {
+		if !seen[id] {
+			delete(journalSummaries, id)
+		}
+	}
+	return units, boots, len(paths)
+}
+
+// summarizeJournalFile 统计单个 journal 文件并更新缓存，返回文件ID和统计，需持有 journalSummaryMu
+// 同一文件（文件ID相同，包括归档后重命名的文件）已统计过时只统计之后追加的条目
+func summarizeJournalFile(path string, info os.FileInfo) (string, *journalFileSummary) {
+	f, err := journal.Open(path)
+	if err != nil {
+		fmt.Printf("打开journal文件失败: %v\n", err)
+		return "", nil
+	}
+	defer f.Close()
+
+	summary := journalSummaries[f.ID()]
+	if summary != nil {
+		if err := f.Seek(summary.position); err != nil {
+			summary = nil
+		}
+	}
+	if summary == nil {
+		summary = &journalFileSummary{
+			units: make(map[string]*journalStat),
+			boots: make(map[string]*journalStat),
+		}
+		journalSummaries[f.ID()] = summary
+	}
+	summary.path = path
+	summary.size = info.Size()
+	summary.modTime = info.ModTime()
+
+	for {
+		entry, err := f.Next()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("读取journal文件出错: %v\n", err)
+			}
+			break
+		}
+		// 只记录完整读取的条目之后的位置，末尾不完整的条目下次重新读取
+		summary.position = f.Position()
+		size := len(renderJournalEntry(entry)) + 1
+		unit := entry.Unit()
+		if summary.units[unit] == nil {
+			summary.units[unit] = &journalStat{}
+		}
+		summary.units[unit].add(entry.Realtime, size)
+		if summary.boots[entry.BootID] == nil {
+			summary.boots[entry.BootID] = &journalStat{}
+		}
+		summary.boots[entry.BootID].add(entry.Realtime, size)
+	}
+	return f.ID(), summary
+}
+
+// collectJournalSources 返回文件列表中的 journal 虚拟日志源，每个单元一项
+func collectJournalSources(cfg *config.Config) []LogFile {
+	if len(cfg.Logs.Journal.Directories) == 0 {
+		return nil
+	}
+
+	units, _, _ := summarizeJournal(cfg)
+	var sources []LogFile
+	for unit, stat := range units {
+		if unit == "" {
+			continue
+		}
+		sourcePath := journalScheme + unit
+		sources = append(sources, LogFile{
+			ID:         fileID(config.JournalSourceName, "", sourcePath),
+			Path:       sourcePath,
+			Name:       unit,
+			FullPath:   sourcePath,
+			Size:       stat.bytes,
+			ModTime:    stat.last.Format("2006-01-02 15:04:05"),
+			Source:     config.JournalSourceName,
+			SourceName: journalSourceTitle,
+		})
+	}
+	return sources
+}
+
+// journalCacheDir 返回转换后文本的缓存目录
+func journalCacheDir(cfg *config.Config) string {
+	if cfg.Logs.Journal.CacheDir != "" {
+		return cfg.Logs.Journal.CacheDir
+	}
+	return filepath.Join(os.TempDir(), "log-tools-journal")
+}
+
+// isJournalCache 判断文件是否为 journal 转换后的文本缓存
+func isJournalCache(absPath string) bool {
+	cfg := config.GetConfig()
+	if cfg == nil || len(cfg.Logs.Journal.Directories) == 0 {
+		return false
+	}
+	return pathWithin(journalCacheDir(cfg), absPath)
+}
+
+// resolveJournalSource 将 journal 虚拟日志源转换为文本缓存文件并返回其路径
+// journal 文件没有变化时直接使用已有的缓存，有新写入的条目时只追加新条目
+// 各日志源的缓存分别加锁，转换一个日志源不会阻塞其他日志源
+func resolveJournalSource(filePath string) (string, error) {
+	cfg := config.GetConfig()
+	if len(cfg.Logs.Journal.Directories) == 0 {
+		return "", fmt.Errorf("未配置journal目录")
+	}
+	filter, err := parseJournalSource(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	paths := journalFiles(cfg)
+	var sig strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sig, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	normalized := journalSourcePath(filter)
+	sum := sha1.Sum([]byte(normalized))
+	name := "all"
+	if filter.Unit != "" {
+		name = strings.Map(func(r rune) rune {
+			if r == '.' || r == '-' || r == '_' || r == '@' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+				return r
+			}
+			return '_'
+		}, filter.Unit)
+	}
+	cacheDir, err := filepath.Abs(journalCacheDir(cfg))
+	if err != nil {
+		return "", err
+	}
+	cachePath := filepath.Join(cacheDir, fmt.Sprintf("journal-%s-%s.log", name, hex.EncodeToString(sum[:4])))
+
+	cache := journalCacheFor(cachePath, cacheDir)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if _, err := os.Stat(cachePath); err != nil {
+		cache.positions = nil
+	}
+	if cache.positions != nil && cache.signature == sig.String() {
+		return cachePath, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0750); err != nil {
+		return "", fmt.Errorf("创建journal缓存目录失败: %v", err)
+	}
+	if cache.positions != nil {
+		count, err := appendJournalCache(cachePath, cache, paths, filter)
+		if err == nil {
+			cache.signature = sig.String()
+			if count > 0 {
+				fmt.Printf("journal日志源 %s 已追加 %d 条\n", normalized, count)
+			}
+			return cachePath, nil
+		}
+		fmt.Printf("追加journal缓存失败，重新生成 %s: %v\n", cachePath, err)
+	}
+
+	count, err := writeJournalCache(cachePath, cache, paths, filter)
+	if err != nil {
+		return "", err
+	}
+	cache.signature = sig.String()
+	fmt.Printf("journal日志源 %s 已转换: %s, 共 %d 条\n", normalized, cachePath, count)
+	return cachePath, nil
+}
+
+// journalCacheFor 返回缓存路径对应的缓存记录并更新使用时间
+// 每隔 convertCachePruneInterval 删除超过 convertCacheIdle 没有使用的缓存和缓存目录中遗留的文件
+func journalCacheFor(cachePath, cacheDir string) *journalCacheEntry {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	now := time.Now()
+	cache, ok := journalCaches[cachePath]
+	if !ok {
+		cache = &journalCacheEntry{}
+		journalCaches[cachePath] = cache
+	}
+	cache.used = now
+
+	if now.Sub(journalPruned) < convertCachePruneInterval {
+		return cache
+	}
+	journalPruned = now
+	keep := make(map[string]bool, len(journalCaches))
+	for path, entry := range journalCaches {
+		// 正在转换的缓存不删除
+		if now.Sub(entry.used) > convertCacheIdle && entry.mu.TryLock() {
+			delete(journalCaches, path)
+			entry.mu.Unlock()
+			continue
+		}
+		keep[path] = true
+	}
+	pruneCacheFiles(cacheDir, "journal", keep)
+	return cache
+}
+
+// writeJournalCache 将满足条件的条目按时间顺序写入缓存文件，先写临时文件再替换，需持有 cache.mu
+func writeJournalCache(cachePath string, cache *journalCacheEntry, paths []string, filter journal.Filter) (int, error) {
+	cache.positions = nil
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".journal-*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("创建journal缓存失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	r := journal.NewReader(openJournalFiles(paths))
+	defer closeJournal(r)
+
+	count, last, writeErr := copyJournalEntries(tmp, r, filter)
+	if err := tmp.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		return 0, fmt.Errorf("写入journal缓存失败: %v", writeErr)
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return 0, fmt.Errorf("写入journal缓存失败: %v", err)
+	}
+	cache.positions = r.Positions()
+	cache.last = last
+	return count, nil
+}
+
+// appendJournalCache 从各文件上次转换到的位置读取新写入的条目追加到缓存，需持有 cache.mu
+// journal 文件被删除、内容与记录的位置不符，或新出现的文件包含早于已转换条目的内容（追加会打乱时间顺序）时返回错误，由调用方重新生成
+func appendJournalCache(cachePath string, cache *journalCacheEntry, paths []string, filter journal.Filter) (int, error) {
+	files := openJournalFiles(paths)
+	found := make(map[string]bool, len(files))
+	var seekErr error
+	for _, f := range files {
+		found[f.ID()] = true
+		if pos, ok := cache.positions[f.ID()]; ok {
+			if err := f.Seek(pos); err != nil {
+				seekErr = err
+				break
+			}
+		} else if head := f.HeadTime(); !head.IsZero() && head.Before(cache.last) {
+			seekErr = fmt.Errorf("新的journal文件 %s 包含更早的条目", f.Path())
+			break
+		}
+	}
+	for id := range cache.positions {
+		if !found[id] && seekErr == nil {
+			seekErr = fmt.Errorf("journal文件已删除")
+		}
+	}
+	r := journal.NewReader(files)
+	defer closeJournal(r)
+	if seekErr != nil {
+		return 0, seekErr
+	}
+
+	out, err := os.OpenFile(cachePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return 0, err
+	}
+	// 写入失败时缓存内容不完整，需要重新生成
+	cache.positions = nil
+	count, last, writeErr := copyJournalEntries(out, r, filter)
+	if err := out.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		return 0, writeErr
+	}
+	cache.positions = r.Positions()
+	if last.After(cache.last) {
+		cache.last = last
+	}
+	return count, nil
+}
+
+// copyJournalEntries 将读取器中满足条件的条目写入 w，返回写入的条目数和读取到的最后一个条目的时间
+func copyJournalEntries(w io.Writer, r *journal.Reader, filter journal.Filter) (int, time.Time, error) {
+	bw := bufio.NewWriter(w)
+	count := 0
+	var last time.Time
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			break
+		}
+		if entry.Realtime.After(last) {
+			last = entry.Realtime
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+		if _, err := bw.WriteString(renderJournalEntry(entry) + "\n"); err != nil {
+			return count, last, err
+		}
+		count++
+	}
+	return count, last, bw.Flush()
+}
+
+// JournalUnit journal 单元统计
+type JournalUnit struct {
+	Unit    string `json:"unit"`
+	Path    string `json:"path"` // 虚拟日志源路径
+	Entries int    `json:"entries"`
+	First   string `json:"first"`
+	Last    string `json:"last"`
+}
+
+// JournalBoot journal 启动统计
+type JournalBoot struct {
+	BootID  string `json:"boot_id"`
+	Entries int    `json:"entries"`
+	First   string `json:"first"`
+	Last    string `json:"last"`
+}
+
+// GetJournalInfo 获取 journal 中的单元、启动和优先级，用于构造虚拟日志源的过滤条件
+func GetJournalInfo(c *gin.Context) {
+	cfg := config.GetConfig()
+	if len(cfg.Logs.Journal.Directories) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": false,
+		})
+		return
+	}
+
+	unitStats, bootStats, fileCount := summarizeJournal(cfg)
+
+	units := make([]JournalUnit, 0, len(unitStats))
+	for unit, stat := range unitStats {
+		if unit == "" {
+			continue
+		}
+		units = append(units, JournalUnit{
+			Unit:    unit,
+			Path:    journalScheme + unit,
+			Entries: stat.entries,
+			First:   stat.first.Format("2006-01-02 15:04:05"),
+			Last:    stat.last.Format("2006-01-02 15:04:05"),
+		})
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].Unit < units[j].Unit })
+
+	boots := make([]JournalBoot, 0, len(bootStats))
+	for boot, stat := range bootStats {
+		boots = append(boots, JournalBoot{
+			BootID:  boot,
+			Entries: stat.entries,
+			First:   stat.first.Format("2006-01-02 15:04:05"),
+			Last:    stat.last.Format("2006-01-02 15:04:05"),
+		})
+	}
+	// 最近的启动排在前面
+	sort.Slice(boots, func(i, j int) bool { return boots[i].Last > boots[j].Last })
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":    true,
+		"files":      fileCount,
+		"units":      units,
+		"boots":      boots,
+		"priorities": journal.PriorityNames,
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anjude/log-tools/journal"
+)
+
+func TestJournalMessage(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Started nginx", "Started nginx"},
+		{"trailing newline\n", "trailing newline"},
+		{"panic: boom\ngoroutine 1 [running]:\nmain.main()", "panic: boom\n    goroutine 1 [running]:\n    main.main()"},
+		{"crlf\r\nnext\r\n", "crlf\n    next"},
+		{"cr\rnext", "cr\n    next"},
+	}
+	for _, tt := range tests {
+		if got := journalMessage(tt.in); got != tt.want {
+			t.Errorf("journalMessage(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderJournalEntry(t *testing.T) {
+	e := &journal.Entry{
+		Realtime: time.Date(2026, 10, 19, 8, 0, 0, 1000, time.UTC),
+		Fields: map[string]string{
+			"_HOSTNAME":         "web 1",
+			"_SYSTEMD_UNIT":     "app.service",
+			"SYSLOG_IDENTIFIER": "app",
+			"_PID":              "901",
+			"PRIORITY":          "3",
+			"SYSLOG_FACILITY":   "1",
+			"MESSAGE":           "panic: boom\nmain.main()",
+		},
+	}
+	want := "<11>1 2026-10-19T08:00:00.000001Z web_1 app 901 app.service - panic: boom\n    main.main()"
+	if got := renderJournalEntry(e); got != want {
+		t.Errorf("renderJournalEntry = %q, want %q", got, want)
+	}
+}
+
+func TestParseJournalSource(t *testing.T) {
+	tests := []struct {
+		path string
+		want string // 规范化路径，为空表示解析失败
+	}{
+		{"journal://", "journal://"},
+		{"journal://nginx.service", "journal://nginx.service"},
+		{"journal://nginx?priority=warn", "journal://nginx?priority=warning"},
+		{"journal://?boot=ABCDEF&priority=3", "journal://?boot=abcdef&priority=err"},
+		{"journal://../etc", ""},
+		{"journal://x?priority=loud", ""},
+		{"journal://x?boot=xyz", ""},
+	}
+	for _, tt := range tests {
+		filter, err := parseJournalSource(tt.path)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseJournalSource(%q) should fail", tt.path)
+			}
+			continue
+		}
+		if err != nil || journalSourcePath(filter) != tt.want {
+			t.Errorf("parseJournalSource(%q) = %q, %v", tt.path, journalSourcePath(filter), err)
+		}
+	}
+}
+
+func TestJournalCacheAppend(t *testing.T) {
+	dir := t.TempDir()
+	samples := filepath.Join("..", "journal", "testdata")
+	plain := filepath.Join(samples, "plain.journal")
+	cachePath := filepath.Join(dir, "journal-all.log")
+	filter := journal.Filter{Priority: -1}
+
+	cache := &journalCacheEntry{}
+	count, err := writeJournalCache(cachePath, cache, []string{plain}, filter)
+	if err != nil || count != 5 {
+		t.Fatalf("writeJournalCache = %d, %v", count, err)
+	}
+	content, _ := os.ReadFile(cachePath)
+	// 多行消息的续行缩进，条目数仍为5
+	if n := strings.Count(string(content), "<"); n != 5 {
+		t.Fatalf("cache has %d entries:\n%s", n, content)
+	}
+
+	// 文件没有新条目时不追加
+	if count, err := appendJournalCache(cachePath, cache, []string{plain}, filter); err != nil || count != 0 {
+		t.Fatalf("appendJournalCache = %d, %v", count, err)
+	}
+	if after, _ := os.ReadFile(cachePath); string(after) != string(content) {
+		t.Fatal("cache changed without new entries")
+	}
+
+	// 新出现的文件包含更早的条目，不能追加
+	if _, err := appendJournalCache(cachePath, cache, []string{plain, filepath.Join(samples, "xz.journal")}, filter); err == nil {
+		t.Fatal("appending older entries should fail")
+	}
+	// 已转换的文件被删除时需要重新生成
+	cache.positions = map[string]journal.Position{"gone": {}}
+	if _, err := appendJournalCache(cachePath, cache, []string{plain}, filter); err == nil {
+		t.Fatal("removed journal file should force a rewrite")
+	}
+}
+
+func TestPruneCacheFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"journal-a-1.log", "journal-b-2.log", ".journal-x.tmp", ".journal-y.tmp", "family-c-3.log", "other.log"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	old := time.Now().Add(-2 * convertCacheIdle)
+	os.Chtimes(filepath.Join(dir, ".journal-y.tmp"), old, old)
+
+	pruneCacheFiles(dir, "journal", map[string]bool{filepath.Join(dir, "journal-a-1.log"): true})
+
+	var left []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	want := ".journal-x.tmp family-c-3.log journal-a-1.log other.log"
+	if strings.Join(left, " ") != want {
+		t.Errorf("left %v, want %s", left, want)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/parser"
+
+	"github.com/gin-gonic/gin"
+)
+
+// levelUnknown 无法识别级别的行
+const levelUnknown = "UNKNOWN"
+
+// levelTracker 跟踪行的日志级别，无法识别级别的行（如堆栈）沿用上一行的级别
+type levelTracker struct {
+	parser parser.Parser
+	last   string
+}
+
+// newLevelTracker 创建文件的级别跟踪器
+func newLevelTracker(filePath string) *levelTracker {
+	return &levelTracker{parser: parserForFile(filePath)}
+}
+
+// levelOf 返回一行的日志级别
+func (t *levelTracker) levelOf(line string) string {
+	level := parseLine(t.parser, line).Level
+	if level == "" && t.parser.Name() != parser.NameText {
+		level = parser.DetectLevel(line)
+	}
+	if level != "" {
+		t.last = level
+		return level
+	}
+	return t.last
+}
+
+// matches 判断一行是否满足级别过滤条件，allowed 为空表示不过滤
+func (t *levelTracker) matches(line string, allowed map[string]bool) bool {
+	level := t.levelOf(line)
+	if len(allowed) == 0 {
+		return true
+	}
+	return allowed[level]
+}
+
+// LevelCounts 单个文件各级别的行数
+type LevelCounts struct {
+	File   string         `json:"file"`   // 文件名
+	Path   string         `json:"path"`   // 请求中的文件路径
+	Counts map[string]int `json:"counts"` // 各级别行数
+	Total  int            `json:"total"`  // 总行数
+}
+
+// levelCountsCache 级别统计缓存，文件大小或修改时间变化后重新统计
+type levelCountsCache struct {
+	size    int64
+	modTime time.Time
+	counts  map[string]int
+	total   int
+}
+
+var (
+	levelCache   = make(map[string]levelCountsCache)
+	levelCacheMu sync.Mutex
+)
+
+// countLevels 统计文件中各级别的行数
+func countLevels(absPath string) (map[string]int, int, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	levelCacheMu.Lock()
+	cached, ok := levelCache[absPath]
+	levelCacheMu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.counts, cached.total, nil
+	}
+
+	counts := make(map[string]int)
+	total := 0
+	tracker := newLevelTracker(absPath)
+	err = scanFileLines(absPath, func(_ int, line string) bool {
+		if strings.TrimSpace(line) == "" {
+			return true
+		}
+		total++
+		level := tracker.levelOf(line)
+		if level == "" {
+			level = levelUnknown
+		}
+		counts[level]++
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	levelCacheMu.Lock()
+	levelCache[absPath] = levelCountsCache{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		counts:  counts,
+		total:   total,
+	}
+	levelCacheMu.Unlock()
+
+	return counts, total, nil
+}
+
+// GetLevelStats 获取文件各日志级别的行数统计，支持多个 file 参数
+func GetLevelStats(c *gin.Context) {
+	files := c.QueryArray("file")
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "文件路径不能为空",
+		})
+		return
+	}
+
+	var stats []LevelCounts
+	for _, filePath := range files {
+		absFilePath, err := resolveFile(filePath)
+		if err != nil {
+			fmt.Printf("文件路径验证失败 %s: %v\n", filePath, err)
+			continue
+		}
+		if binary, _ := isBinaryFile(absFilePath); binary {
+			fmt.Printf("跳过二进制文件: %s\n", absFilePath)
+			continue
+		}
+
+		counts, total, err := countLevels(absFilePath)
+		if err != nil {
+			fmt.Printf("统计日志级别失败 %s: %v\n", absFilePath, err)
+			continue
+		}
+
+		stats = append(stats, LevelCounts{
+			File:   filepath.Base(absFilePath),
+			Path:   filePath,
+			Counts: counts,
+			Total:  total,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats":  stats,
+		"levels": parser.Levels,
+	})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// levelLines 测试用的日志，堆栈行沿用上一行的级别
+var levelLines = []string{
+	"2026-10-19 08:00:00 INFO server started",
+	"2026-10-19 08:00:01 [W] slow request /api",
+	"2026-10-19 08:00:02 ERROR request failed",
+	"\tat com.example.Handler.run(Handler.java:42)",
+	"\tat com.example.Server.serve(Server.java:7)",
+	"2026-10-19 08:00:03 level=debug msg=retry",
+}
+
+func TestGetLevelStats(t *testing.T) {
+	dir := t.TempDir()
+	path := writeLog(t, dir, "app.log", levelLines...)
+	empty := writeLog(t, dir, "empty.log")
+	useConfig(t, testConfig(dir, false))
+
+	code, response := serve(t, GetLevelStats, http.MethodGet, "/?file="+url.QueryEscape(path)+"&file="+url.QueryEscape(empty)+"&file=missing.log", nil)
+	if code != http.StatusOK {
+		t.Fatalf("status %d: %v", code, response["error"])
+	}
+	stats, _ := response["stats"].([]interface{})
+	if len(stats) != 2 {
+		t.Fatalf("%d stats, want 2", len(stats))
+	}
+	first, _ := stats[0].(map[string]interface{})
+	counts, _ := first["counts"].(map[string]interface{})
+	want := map[string]float64{"INFO": 1, "WARN": 1, "ERROR": 3, "DEBUG": 1}
+	if first["total"] != 6.0 || !equalCounts(toCounts(counts), want) {
+		t.Errorf("stats = %v, want total 6, %v", first, want)
+	}
+	if second, _ := stats[1].(map[string]interface{}); second["total"] != 0.0 {
+		t.Errorf("empty file stats = %v", second)
+	}
+
+	if code, _ := serve(t, GetLevelStats, http.MethodGet, "/", nil); code != http.StatusBadRequest {
+		t.Errorf("no file: status %d", code)
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	dir := t.TempDir()
+	path := writeLog(t, dir, "app.log", levelLines...)
+	useConfig(t, testConfig(dir, false))
+
+	tests := []struct {
+		levels string
+		want   []int // 期望的行号
+	}{
+		{"", []int{1, 2, 3, 4, 5, 6}},
+		{"error", []int{3, 4, 5}},
+		{"warn+", []int{2, 3, 4, 5}},
+		{"debug,info", []int{1, 6}},
+		{"fatal", nil},
+	}
+	for _, tt := range tests {
+		// 内容接口
+		code, response := serve(t, GetLogContent, http.MethodGet, "/?mode=structured&levels="+url.QueryEscape(tt.levels)+"&file="+url.QueryEscape(path), nil)
+		if code != http.StatusOK {
+			t.Fatalf("content %q: status %d: %v", tt.levels, code, response["error"])
+		}
+		entries, _ := response["entries"].([]interface{})
+		var got []int
+		for _, e := range entries {
+			entry, _ := e.(map[string]interface{})
+			got = append(got, int(entry["line_number"].(float64)))
+		}
+		if !equalInts(got, tt.want) {
+			t.Errorf("content levels %q = %v, want %v", tt.levels, got, tt.want)
+		}
+
+		// 搜索接口
+		body := map[string]interface{}{"files": []string{path}, "pattern": "2026 or at"}
+		if tt.levels != "" {
+			body["levels"] = []string{tt.levels}
+		}
+		code, response = serve(t, SearchLogs, http.MethodPost, "/", body)
+		if code != http.StatusOK {
+			t.Fatalf("search %q: status %d: %v", tt.levels, code, response["error"])
+		}
+		results, _ := response["results"].([]interface{})
+		got = nil
+		for _, r := range results {
+			result, _ := r.(map[string]interface{})
+			got = append(got, int(result["line_number"].(float64)))
+		}
+		if !equalInts(got, tt.want) {
+			t.Errorf("search levels %q = %v, want %v", tt.levels, got, tt.want)
+		}
+	}
+
+	if code, _ := serve(t, GetLogContent, http.MethodGet, "/?levels=verbose&file="+url.QueryEscape(path), nil); code != http.StatusBadRequest {
+		t.Errorf("unknown level: status %d", code)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,49 +13,105 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/anjude/log-tools/catalog"
 	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+	"github.com/anjude/log-tools/redact"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxLineSize 单行最大长度，超过此长度的行会导致读取失败
+const maxLineSize = 1024 * 1024
+
 // LogFile 日志文件信息
 type LogFile struct {
-	Path      string `json:"path"`      // 相对路径
-	Name      string `json:"name"`      // 文件名
-	FullPath  string `json:"full_path"` // 完整文件路径
-	Directory string `json:"directory"` // 所属目录
-	Size      int64  `json:"size"`
-	ModTime   string `json:"mod_time"`
+	ID         string            `json:"id"`        // 不透明的文件ID，由日志源和文件在日志源中的路径组成，各接口的 file/files 参数优先使用
+	Path       string            `json:"path"`      // 相对路径
+	Name       string            `json:"name"`      // 文件名
+	FullPath   string            `json:"full_path"` // 完整文件路径
+	Directory  string            `json:"directory"` // 在日志源目录中的子目录，位于目录本身时为空
+	Size       int64             `json:"size"`
+	ModTime    string            `json:"mod_time"`
+	Binary     bool              `json:"binary"`              // 是否为二进制文件
+	Container  string            `json:"container,omitempty"` // 容器日志格式：docker 或 cri
+	Source     string            `json:"source"`              // 所属日志源名称
+	SourceName string            `json:"source_name"`         // 日志源显示名称
+	Labels     map[string]string `json:"labels,omitempty"`    // 日志源的标签
+	Pinned     bool              `json:"pinned"`              // 是否为日志源中配置的确定文件，显示在所在分组的最前面
+	Group      string            `json:"group"`               // 文件列表中的分组，由 group_by 参数决定
+	Family     []FamilyMember    `json:"family,omitempty"`    // 轮转文件组的成员，按时间顺序，最旧的在前
+	Meta       *FileMeta         `json:"meta,omitempty"`      // 行数、时间范围、格式等统计信息，后台计算完成前为空
 }
 
 // GetLogFiles 获取日志文件列表
+// 支持按日志源（source=名称，可多个）和标签（label=env:prod，可多个，需同时满足）筛选，
+// group_by 指定分组方式：directory（默认）、source 或 label:<标签名>
 func GetLogFiles(c *gin.Context) {
 	cfg := config.GetConfig()
+	labels, err := parseLabelFilters(c.QueryArray("label"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
-	// 添加调试信息
-	fmt.Printf("配置的日志目录: %s\n", cfg.Logs.Directory)
-	fmt.Printf("配置的文件模式: %s\n", cfg.Logs.Pattern)
-	fmt.Printf("配置的固定文件: %v\n", cfg.Logs.FixedFiles)
+	logFiles, err := collectLogFiles(cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("获取日志文件失败: %v", err),
+		})
+		return
+	}
+	allLabels := labelValues(logFiles)
+	logFiles = filterLogFiles(logFiles, c.QueryArray("source"), labels)
+	if err := groupLogFiles(logFiles, c.Query("group_by")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	body, err := json.Marshal(gin.H{
+		"files":   logFiles,
+		"sources": sourceInfos(cfg),
+		"labels":  allLabels,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("获取日志文件失败: %v", err),
+		})
+		return
+	}
 
+	// 前端轮询时带上 If-None-Match，列表未变化时返回 304
+	sum := sha1.Sum(body)
+	etag := quoteETag(hex.EncodeToString(sum[:]))
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// collectLogFiles 收集各日志源中确定的文件和目录中扫描到的文件，确定的文件排在最前面
+// 修改时间早于日志源保留时长的文件不包含在内，轮转文件按活动文件合并为一项
+func collectLogFiles(cfg *config.Config) ([]LogFile, error) {
 	var logFiles []LogFile
 
-	// 首先处理固定文件路径
-	if len(cfg.Logs.FixedFiles) > 0 {
-		for _, fixedFile := range cfg.Logs.FixedFiles {
-			// 解析路径（支持相对路径和绝对路径）
-			var resolvedPath string
-			if filepath.IsAbs(fixedFile) {
-				// 绝对路径
-				resolvedPath = fixedFile
-			} else {
-				// 相对路径，相对于程序运行目录
-				absPath, err := filepath.Abs(fixedFile)
-				if err != nil {
-					fmt.Printf("解析固定文件路径失败 %s: %v\n", fixedFile, err)
-					continue
-				}
-				resolvedPath = absPath
+	// 首先处理各日志源中确定的文件
+	for _, source := range cfg.LogSources() {
+		for _, fixedFile := range source.Files {
+			// 解析路径（支持相对路径和绝对路径），相对路径相对于程序运行目录
+			resolvedPath, err := filepath.Abs(fixedFile)
+			if err != nil {
+				fmt.Printf("解析固定文件路径失败 %s: %v\n", fixedFile, err)
+				continue
 			}
 
 			// 检查文件是否存在
@@ -62,117 +121,88 @@ func GetLogFiles(c *gin.Context) {
 				continue
 			}
 
-			// 创建固定文件记录
-			logFile := LogFile{
-				Path:      fixedFile, // 使用配置中的原始路径作为显示路径
-				Name:      filepath.Base(resolvedPath),
-				FullPath:  resolvedPath,
-				Directory: "固定文件",
-				Size:      info.Size(),
-				ModTime:   info.ModTime().Format("2006-01-02 15:04:05"),
-			}
-
-			fmt.Printf("添加固定文件: %+v\n", logFile)
-			logFiles = append(logFiles, logFile)
+			logFiles = append(logFiles, pinnedLogFile(source, fixedFile, resolvedPath, info))
 		}
 	}
 
-	// 然后处理扫描到的日志文件
-	files, err := cfg.GetLogFiles()
+	// 然后处理文件目录中的日志文件
+	files, err := catalogFiles(cfg)
 	if err != nil {
 		fmt.Printf("获取日志文件错误: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("获取日志文件失败: %v", err),
-		})
-		return
+		return nil, err
 	}
 
-	fmt.Printf("找到的原始文件路径: %v\n", files)
-
-	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			fmt.Printf("获取文件信息失败 %s: %v\n", file, err)
-			continue
-		}
-
-		// 获取相对于日志目录的路径
-		var relPath string
-		var baseDir string
-		var directory string
-
-		// 确定文件所属的日志目录
-		if len(cfg.Logs.Directories) > 0 {
-			// 使用多目录配置
-			for _, dir := range cfg.Logs.Directories {
-				if strings.HasPrefix(file, dir) {
-					baseDir = dir
-					break
-				}
-			}
-		} else if cfg.Logs.Directory != "" {
-			// 使用单目录配置
-			baseDir = cfg.Logs.Directory
-		} else {
-			// 默认目录
-			baseDir = "./logs"
-		}
-
-		if baseDir != "" {
-			relPath, err = filepath.Rel(baseDir, file)
-			if err != nil {
-				// 如果无法获取相对路径，使用文件名
-				relPath = filepath.Base(file)
-			}
-
-			// 获取目录部分
-			if strings.Contains(relPath, string(filepath.Separator)) {
-				directory = filepath.Dir(relPath)
-			} else {
-				directory = "根目录"
-			}
-		} else {
-			// 如果无法确定基础目录，使用文件名
-			relPath = filepath.Base(file)
-			directory = "未知目录"
-		}
-
-		logFile := LogFile{
-			Path:      relPath, // 使用相对路径
-			Name:      filepath.Base(file),
-			FullPath:  file, // 完整文件路径
-			Directory: directory,
-			Size:      info.Size(),
-			ModTime:   info.ModTime().Format("2006-01-02 15:04:05"),
+	retention := sourceRetentions(cfg)
+	now := time.Now()
+	kept := make([]catalog.File, 0, len(files))
+	for _, f := range files {
+		if withinRetention(retention, f, now) {
+			kept = append(kept, f)
 		}
+	}
 
-		fmt.Printf("处理文件: %+v\n", logFile)
-		logFiles = append(logFiles, logFile)
+	// 同一文件轮转产生的文件合并为一项
+	singles, families := groupFamilies(kept, currentRotation(cfg))
+	for _, f := range singles {
+		logFiles = append(logFiles, catalogLogFile(f))
 	}
+	for _, fam := range families {
+		logFiles = append(logFiles, familyLogFile(fam))
+	}
+
+	// 添加 journal 虚拟日志源
+	logFiles = append(logFiles, collectJournalSources(cfg)...)
 
-	// 按目录和文件名排序，固定文件始终在最前面
+	// 按日志源和目录排序，同一目录中确定的文件在最前面，然后按文件名排序
 	sort.Slice(logFiles, func(i, j int) bool {
-		// 固定文件始终在最前面
-		if logFiles[i].Directory == "固定文件" && logFiles[j].Directory != "固定文件" {
-			return true
+		if logFiles[i].SourceName != logFiles[j].SourceName {
+			return logFiles[i].SourceName < logFiles[j].SourceName
 		}
-		if logFiles[i].Directory != "固定文件" && logFiles[j].Directory == "固定文件" {
-			return false
-		}
-
-		// 如果都是固定文件或都不是固定文件，按目录和文件名排序
 		if logFiles[i].Directory != logFiles[j].Directory {
 			return logFiles[i].Directory < logFiles[j].Directory
 		}
+		if logFiles[i].Pinned != logFiles[j].Pinned {
+			return logFiles[i].Pinned
+		}
 		// 目录相同时按文件名排序
 		return logFiles[i].Name < logFiles[j].Name
 	})
 
-	fmt.Printf("最终返回的文件列表: %+v\n", logFiles)
+	return logFiles, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"files": logFiles,
-	})
+// pinnedLogFile 将日志源中确定的文件转换为文件列表项
+func pinnedLogFile(source config.SourceConfig, fixedFile, resolvedPath string, info os.FileInfo) LogFile {
+	logFile := LogFile{
+		ID:         fileID(source.Name, "", fixedScheme+fixedFile),
+		Path:       fixedFile, // 使用配置中的原始路径作为显示路径
+		Name:       filepath.Base(resolvedPath),
+		FullPath:   resolvedPath,
+		Size:       info.Size(),
+		ModTime:    info.ModTime().Format("2006-01-02 15:04:05"),
+		Source:     source.Name,
+		SourceName: source.Title(),
+		Labels:     source.Labels,
+		Pinned:     true,
+	}
+	logFile.Binary, _ = isBinaryFile(resolvedPath)
+	logFile.Meta = cachedFileMeta(resolvedPath)
+	return logFile
+}
+
+// sourceRetentions 返回各日志源的保留时长，没有配置时为0
+func sourceRetentions(cfg *config.Config) map[string]time.Duration {
+	retention := make(map[string]time.Duration)
+	for _, source := range cfg.LogSources() {
+		retention[source.Name], _ = source.RetentionDuration()
+	}
+	return retention
+}
+
+// withinRetention 判断目录中的文件是否在所属日志源的保留时长内，超过保留时长的文件不在文件列表中显示
+func withinRetention(retention map[string]time.Duration, f catalog.File, now time.Time) bool {
+	keep := retention[f.Source]
+	return keep <= 0 || now.Sub(f.ModTime) <= keep
 }
 
 // GetLogContent 获取日志内容
@@ -192,7 +222,7 @@ func GetLogContent(c *gin.Context) {
 	fmt.Printf("请求的文件路径: %s\n", filePath)
 
 	// 验证文件路径安全性
-	absFilePath, err := validateFilePath(filePath)
+	absFilePath, err := resolveFile(filePath)
 	if err != nil {
 		fmt.Printf("文件路径验证失败: %v\n", err)
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -210,6 +240,15 @@ func GetLogContent(c *gin.Context) {
 		return
 	}
 
+	// 二进制文件默认拒绝按行读取，应使用十六进制视图；force=true 时仍按文本读取
+	if binary, _ := isBinaryFile(absFilePath); binary && c.Query("force") != "true" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":  "该文件是二进制文件，请使用十六进制视图查看",
+			"binary": true,
+		})
+		return
+	}
+
 	lines, err := strconv.Atoi(linesStr)
 	if err != nil {
 		lines = 200
@@ -221,8 +260,64 @@ func GetLogContent(c *gin.Context) {
 
 	reverse := reverseStr == "true"
 
-	// 读取文件最后N行
-	content, err := readLastNLines(absFilePath, lines, reverse)
+	// ANSI转义序列处理：strip（默认）去除，spans 去除并返回样式片段，raw 保留原始内容
+	ansiMode := c.DefaultQuery("ansi", ansiStrip)
+	if ansiMode != ansiStrip && ansiMode != ansiSpans && ansiMode != ansiRaw {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("不支持的ansi参数: %s", ansiMode),
+		})
+		return
+	}
+	// 转义序列可能把敏感内容拆开（如 "138\x1b[0m00138000"），需要脱敏时不返回原始内容，去除转义后整行脱敏
+	redaction := newRedaction(c)
+	if ansiMode == ansiRaw && redaction != nil {
+		ansiMode = ansiStrip
+	}
+
+	// 容器日志按输出流过滤
+	stream, err := parseStream(c.Query("stream"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 按日志级别过滤，支持 "warn,error" 或 "warn+"
+	allowedLevels, err := parser.ParseLevelFilter(c.Query("levels"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var keep func(line string) bool
+	if len(allowedLevels) > 0 {
+		tracker := newLevelTracker(absFilePath)
+		keep = func(line string) bool {
+			return tracker.matches(line, allowedLevels)
+		}
+	}
+
+	// 行转换管道，支持 transforms 参数（可重复）和配置中的 preset 预设
+	pipeline, err := newTransformPipeline(c.QueryArray("transforms"), c.Query("preset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	structured := c.Query("mode") == "structured"
+	if structured && pipeline != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "结构化模式不支持行转换",
+		})
+		return
+	}
+
+	// 读取文件最后N行（过滤后）
+	rawLines, err := readLastNRawLines(absFilePath, stream, lines, reverse, keep)
 	if err != nil {
 		fmt.Printf("读取文件失败: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -231,29 +326,122 @@ func GetLogContent(c *gin.Context) {
 		return
 	}
 
+	// 样式片段需要从原始内容解析，之后再去除转义序列
+	// 脱敏在解析之前进行，解析出的字段同样不包含敏感信息
+	var styles [][]AnsiSpan
+	if ansiMode == ansiSpans {
+		styles = make([][]AnsiSpan, len(rawLines))
+	}
+	// 样式片段拼接后即为去除转义后的行，按整行脱敏后再切分回各片段，跨越样式边界的敏感内容同样会被替换
+	for i := range rawLines {
+		if styles != nil {
+			styles[i] = parseANSI(rawLines[i].Text)
+			texts := make([]string, len(styles[i]))
+			for j := range styles[i] {
+				texts[j] = styles[i][j].Text
+			}
+			texts = redaction.Segments(texts)
+			for j := range styles[i] {
+				styles[i][j].Text = texts[j]
+			}
+			rawLines[i].Text = strings.Join(texts, "")
+			continue
+		}
+		if ansiMode != ansiRaw {
+			rawLines[i].Text = stripANSI(rawLines[i].Text)
+		}
+		rawLines[i].Text = redaction.String(rawLines[i].Text)
+	}
+
+	// 结构化模式：每行返回行号、偏移和解析后的字段
+	if structured {
+		p := parserForFile(absFilePath)
+		entries := buildLogEntries(p, rawLines)
+		response := gin.H{
+			"entries": entries,
+			"format":  p.Name(),
+			"file":    filepath.Base(absFilePath),
+			"lines":   len(entries),
+			"ansi":    ansiMode,
+		}
+		if styles != nil {
+			response["styles"] = styles
+		}
+		finishRedaction(c, redaction, response)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	content := make([]string, 0, len(rawLines))
+	for _, line := range rawLines {
+		content = append(content, line.Text)
+	}
+
 	fmt.Printf("成功读取文件 %s，共 %d 行\n", absFilePath, len(content))
 
-	c.JSON(http.StatusOK, gin.H{
+	// 按需返回解析后的记录，与content一一对应，记录从转换前的原始行解析
+	var records []*parser.Record
+	if c.Query("parse") == "true" {
+		p := parserForFile(absFilePath)
+		records = make([]*parser.Record, len(content))
+		for i, line := range content {
+			records[i] = parseFileLine(p, rawLines[i].LineNumber, line)
+		}
+	}
+
+	// 执行行转换，折叠连续相同的行后记录和样式只保留每组的第一行
+	if pipeline != nil {
+		var kept []int
+		content, kept = pipeline.apply(content, parserForFile(absFilePath))
+		if records != nil {
+			records = keepIndexes(records, kept)
+		}
+		if styles != nil {
+			styles = keepIndexes(styles, kept)
+		}
+	}
+
+	response := gin.H{
 		"content": content,
 		"file":    filepath.Base(absFilePath),
 		"lines":   len(content),
-	})
+		"ansi":    ansiMode, // 实际使用的处理模式，需要脱敏时 raw 按 strip 处理
+	}
+	if styles != nil {
+		response["styles"] = styles
+	}
+	if records != nil {
+		response["format"] = parserForFile(absFilePath).Name()
+		response["records"] = records
+	}
+
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
 }
 
 // SearchRequest 搜索请求结构
 type SearchRequest struct {
-	Files   []string `json:"files" binding:"required"`   // 要搜索的文件路径列表
-	Pattern string   `json:"pattern" binding:"required"` // 搜索模式
-	Reverse bool     `json:"reverse"`                    // 是否倒序搜索
-	Lines   int      `json:"lines"`                      // 限制返回结果的最大数量
+	Files   []string          `json:"files" binding:"required"`   // 要搜索的文件路径列表
+	Pattern string            `json:"pattern" binding:"required"` // 搜索模式
+	Reverse bool              `json:"reverse"`                    // 是否倒序搜索
+	Lines   int               `json:"lines"`                      // 限制返回结果的最大数量
+	Parse   bool              `json:"parse"`                      // 是否返回解析后的记录
+	Fields  map[string]string `json:"fields"`                     // 按解析后的字段过滤
+	Levels  []string          `json:"levels"`                     // 按日志级别过滤，如 ["warn","error"] 或 ["warn+"]
+	// 行转换，如 ["pretty_json", "timezone:Asia/Shanghai", "collapse"]，也可以引用配置中的预设
+	Transforms []string `json:"transforms"`
+	Preset     string   `json:"preset"` // 可选，配置中的转换预设名称
+	Stream     string   `json:"stream"` // 容器日志的输出流，stdout 或 stderr，为空表示全部
 }
 
 // SearchResult 搜索结果结构
 type SearchResult struct {
-	LineNumber int    `json:"line_number"` // 行号
-	Content    string `json:"content"`     // 行内容
-	File       string `json:"file"`        // 文件名
-	FilePath   string `json:"file_path"`   // 完整文件路径
+	LineNumber int            `json:"line_number"`      // 行号
+	Content    string         `json:"content"`          // 行内容
+	File       string         `json:"file"`             // 文件名
+	FilePath   string         `json:"file_path"`        // 完整文件路径
+	Record     *parser.Record `json:"record,omitempty"` // 解析后的记录
+	Repeat     int            `json:"repeat,omitempty"` // 折叠的连续相同行数
 }
 
 // SearchLogs 搜索日志
@@ -270,23 +458,7 @@ func SearchLogs(c *gin.Context) {
 	fmt.Printf("搜索请求: 文件=%v, 模式=%s, 倒序=%v, 最大返回结果数=%d\n", req.Files, req.Pattern, req.Reverse, req.Lines)
 
 	// 验证所有文件路径安全性
-	var validFiles []string
-	for _, filePath := range req.Files {
-		absFilePath, err := validateFilePath(filePath)
-		if err != nil {
-			fmt.Printf("文件路径验证失败 %s: %v\n", filePath, err)
-			continue
-		}
-
-		// 检查文件是否存在
-		if _, err := os.Stat(absFilePath); os.IsNotExist(err) {
-			fmt.Printf("搜索文件不存在: %s\n", absFilePath)
-			continue
-		}
-
-		validFiles = append(validFiles, absFilePath)
-	}
-
+	validFiles := resolveRequestFiles(req.Files)
 	if len(validFiles) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "没有找到有效的文件进行搜索",
@@ -303,6 +475,34 @@ func SearchLogs(c *gin.Context) {
 		return
 	}
 
+	searchQuery.Parse = req.Parse
+	searchQuery.Fields = req.Fields
+	searchQuery.Levels, err = parser.ParseLevelFilter(strings.Join(req.Levels, ","))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	searchQuery.Stream, err = parseStream(req.Stream)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	pipeline, err := newTransformPipeline(req.Transforms, req.Preset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	redaction := newRedaction(c)
+	searchQuery.Redaction = redaction
+
 	// 在所有有效文件中搜索
 	allResults, err := searchInMultipleFiles(validFiles, searchQuery, config.GetConfig().Logs.MaxSearchResults, req.Reverse, req.Lines)
 	if err != nil {
@@ -313,17 +513,38 @@ func SearchLogs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	for i := range allResults {
+		allResults[i].Content = redaction.String(allResults[i].Content)
+		allResults[i].Record = redactRecord(redaction, allResults[i].Record)
+	}
+	if pipeline != nil {
+		allResults = transformSearchResults(pipeline, allResults)
+	}
+
+	response := gin.H{
 		"results": allResults,
 		"count":   len(allResults),
 		"files":   req.Files,
-	})
+	}
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
 }
 
 // SearchQuery 搜索查询结构
 type SearchQuery struct {
 	Keywords []SearchKeyword
-	Logic    string // "and" 或 "or"
+	Logic    string            // "and" 或 "or"
+	Parse    bool              // 是否附带解析后的记录
+	Fields   map[string]string // 解析后的字段过滤条件
+	Levels   map[string]bool   // 允许的日志级别，为空表示不过滤
+	Stream   string            // 容器日志的输出流（stdout 或 stderr），为空表示全部
+	// 需要脱敏的用户在脱敏后的行上匹配关键词和字段条件，避免通过搜索条件推测被隐藏的内容
+	Redaction *redact.Session
+}
+
+// needsParser 是否需要使用格式解析器处理匹配的行
+func (q *SearchQuery) needsParser() bool {
+	return q.Parse || len(q.Fields) > 0
 }
 
 // SearchKeyword 搜索关键词结构
@@ -529,43 +750,71 @@ func isLogicOperator(text string) bool {
 // searchInFileAdvanced 高级文件搜索
 // maxLines参数用于限制返回结果的最大数量，不再限制搜索范围
 func searchInFileAdvanced(filePath string, query *SearchQuery, maxResults int, reverse bool, maxLines int) ([]SearchResult, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
 	var results []SearchResult
-	scanner := bufio.NewScanner(file)
-	lineNumber := 0
 	var allLines []string
+	var lineNumbers []int
 
-	// 读取所有行
-	for scanner.Scan() {
-		lineNumber++
-		allLines = append(allLines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
+	// 读取所有行，容器日志按指定的输出流读取解包后的内容
+	err := scanStreamLines(filePath, query.Stream, func(lineNumber int, _ int64, line string) bool {
+		allLines = append(allLines, line)
+		lineNumbers = append(lineNumbers, lineNumber)
+		return true
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	// 注意：maxLines参数现在用于限制返回结果数量，不再限制搜索范围
 	// 搜索会在整个文件范围内进行
 
-	// 搜索匹配的行
+	var p parser.Parser
+	if query.needsParser() {
+		p = parserForFile(filePath)
+	}
+
+	var levels *levelTracker
+	if len(query.Levels) > 0 {
+		levels = newLevelTracker(filePath)
+	}
+
+	// 搜索匹配的行，搜索和返回的内容都不包含ANSI转义序列
+	// 返回的内容由调用方脱敏，这里只在脱敏后的行上判断关键词和字段条件
 	for i, line := range allLines {
-		lineNum := i + 1
+		line = stripANSI(line)
+		lineNum := lineNumbers[i]
 		if reverse {
 			lineNum = len(allLines) - i
 		}
 
+		// 级别需要按顺序跟踪每一行，以便堆栈等续行沿用上一行的级别
+		if levels != nil && !levels.matches(line, query.Levels) {
+			continue
+		}
+
 		if matchesSearchQuery(line, query) {
+			var record *parser.Record
+			if p != nil {
+				record = parseFileLine(p, lineNumbers[i], line)
+				if len(query.Fields) > 0 {
+					filtered := record
+					if matchLine := query.Redaction.Silent(line); matchLine != line {
+						filtered = parseLine(p, matchLine)
+					}
+					if !matchesFieldFilters(filtered, query.Fields) {
+						continue
+					}
+				}
+				if !query.Parse {
+					record = nil
+				}
+			}
+
 			results = append(results, SearchResult{
 				LineNumber: lineNum,
 				Content:    strings.TrimSpace(line),
 				File:       filepath.Base(filePath), // 只显示文件名，不显示完整路径
 				FilePath:   filePath,                // 完整文件路径
+				Record:     record,
 			})
 
 			// 使用maxLines参数限制返回结果数量，而不是maxResults
@@ -628,6 +877,10 @@ func matchesSearchQuery(line string, query *SearchQuery) bool {
 		return false
 	}
 
+	// 颜色等转义序列会打断关键词，始终在去除转义后的文本上匹配
+	// 需要脱敏时在脱敏后的文本上匹配，被隐藏的内容不能作为搜索条件
+	line = query.Redaction.Silent(stripANSI(line))
+
 	if query.Logic == "or" {
 		// OR逻辑：任一关键词匹配即可
 		for _, keyword := range query.Keywords {
@@ -670,38 +923,6 @@ func matchesKeyword(line string, keyword SearchKeyword) bool {
 	}
 }
 
-// readLastNLines 读取文件最后N行
-func readLastNLines(filePath string, n int, reverse bool) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-		if len(lines) > n {
-			lines = lines[1:] // 保持最新的N行
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	// 如果需要倒序，反转数组
-	if reverse {
-		for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
-			lines[i], lines[j] = lines[j], lines[i]
-		}
-	}
-
-	return lines, nil
-}
-
 // searchInFile 在文件中搜索（保留原有函数以兼容性）
 func searchInFile(filePath string, regex *regexp.Regexp, maxResults int) ([]SearchResult, error) {
 	file, err := os.Open(filePath)
@@ -739,98 +960,97 @@ func searchInFile(filePath string, regex *regexp.Regexp, maxResults int) ([]Sear
 	return results, nil
 }
 
-// getLogDirectories 获取日志目录列表
-func getLogDirectories() []string {
-	cfg := config.GetConfig()
-	var directories []string
-
-	if len(cfg.Logs.Directories) > 0 {
-		directories = cfg.Logs.Directories
-	} else if cfg.Logs.Directory != "" {
-		directories = []string{cfg.Logs.Directory}
-	} else {
-		directories = []string{"./logs"}
-	}
+// resolveRequestFiles 解析请求中的文件（文件ID或路径），返回存在且允许访问的绝对路径
+func resolveRequestFiles(files []string) []string {
+	var validFiles []string
+	for _, filePath := range files {
+		absFilePath, err := resolveFile(filePath)
+		if err != nil {
+			fmt.Printf("文件路径验证失败 %s: %v\n", filePath, err)
+			continue
+		}
 
-	return directories
-}
+		// 检查文件是否存在
+		if _, err := os.Stat(absFilePath); os.IsNotExist(err) {
+			fmt.Printf("文件不存在: %s\n", absFilePath)
+			continue
+		}
 
-// validateFilePath 验证文件路径安全性
-func validateFilePath(filePath string) (string, error) {
-	// 清理文件路径，移除可能的路径遍历攻击
-	cleanPath := filepath.Clean(filePath)
+		// 二进制文件不参与按行的搜索和统计
+		if binary, _ := isBinaryFile(absFilePath); binary {
+			fmt.Printf("跳过二进制文件: %s\n", absFilePath)
+			continue
+		}
 
-	// 添加调试信息
-	fmt.Printf("原始文件路径: %s\n", filePath)
-	fmt.Printf("清理后路径: %s\n", cleanPath)
-
-	// 获取日志目录列表
-	directories := getLogDirectories()
-	fmt.Printf("搜索目录: %v\n", directories)
-
-	// 如果提供的是相对路径，尝试在所有日志目录中查找
-	if !filepath.IsAbs(cleanPath) {
-		for _, dir := range directories {
-			// 处理相对路径的特殊情况
-			var fullPath string
-			if strings.HasPrefix(cleanPath, "logs") && (dir == "./logs" || dir == "logs") {
-				// 如果清理后的路径以"logs"开头，且目录是"./logs"或"logs"，直接使用清理后的路径
-				fullPath = cleanPath
-			} else {
-				// 否则使用filepath.Join组合路径
-				fullPath = filepath.Join(dir, cleanPath)
-			}
+		validFiles = append(validFiles, absFilePath)
+	}
+	return validFiles
+}
 
-			fmt.Printf("尝试路径: %s\n", fullPath)
+// scanFileLines 逐行读取文件并回调，回调返回false时停止读取
+// 传给回调的行已去除ANSI转义序列
+func scanFileLines(filePath string, fn func(lineNumber int, line string) bool) error {
+	return scanFileLinesWithOffset(filePath, func(lineNumber int, _ int64, line string) bool {
+		return fn(lineNumber, stripANSI(line))
+	})
+}
 
-			if _, err := os.Stat(fullPath); err == nil {
-				// 文件存在，验证路径安全性
-				absFilePath, err := filepath.Abs(fullPath)
-				if err != nil {
-					fmt.Printf("获取绝对路径失败: %v\n", err)
-					continue
-				}
+// scanFileLinesWithOffset 逐行读取文件并回调，同时提供每行起始的字节偏移
+func scanFileLinesWithOffset(filePath string, fn func(lineNumber int, offset int64, line string) bool) error {
+	return scanStreamLines(filePath, "", fn)
+}
 
-				absLogDir, err := filepath.Abs(dir)
-				if err != nil {
-					fmt.Printf("获取目录绝对路径失败: %v\n", err)
-					continue
-				}
+// scanStreamLines 逐行读取文件并回调，同时提供每行起始的字节偏移
+// 容器日志（Docker json-file、CRI）会解开外层格式并拼接部分行，行号和偏移为第一个片段所在的行
+// stream 为 stdout 或 stderr 时只返回该输出流的行，对普通文件无效
+// 日志源配置了编码时，每行转换为 UTF-8 后再回调
+func scanStreamLines(filePath, stream string, fn func(lineNumber int, offset int64, line string) bool) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-				fmt.Printf("文件绝对路径: %s\n", absFilePath)
-				fmt.Printf("目录绝对路径: %s\n", absLogDir)
+	var consumed, lineStart int64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			lineStart = consumed
+		}
+		consumed += int64(advance)
+		return advance, token, err
+	})
 
-				// 安全检查：确保文件路径在日志目录内
-				if strings.HasPrefix(absFilePath, absLogDir) {
-					fmt.Printf("路径验证成功: %s\n", absFilePath)
-					return absFilePath, nil
-				} else {
-					fmt.Printf("路径安全检查失败: %s 不在目录 %s 内\n", absFilePath, absLogDir)
-				}
-			} else {
-				fmt.Printf("文件不存在: %s, 错误: %v\n", fullPath, err)
+	unwrap := newContainerUnwrapper(filePath, stream)
+	decoder := sourceDecoder(filePath)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := decodeLine(decoder, scanner.Text())
+		if unwrap == nil {
+			if !fn(lineNumber, lineStart, text) {
+				return nil
+			}
+			continue
+		}
+		if line, ok := unwrap.feed(lineNumber, lineStart, text); ok {
+			if !fn(line.lineNumber, line.offset, line.text) {
+				return nil
 			}
 		}
-		return "", fmt.Errorf("文件不存在或不在允许的目录内")
 	}
-
-	// 如果是绝对路径，验证是否在任一日志目录内
-	absFilePath, err := filepath.Abs(cleanPath)
-	if err != nil {
-		return "", fmt.Errorf("文件路径格式错误")
+	if err := scanner.Err(); err != nil {
+		return err
 	}
 
-	for _, dir := range directories {
-		absLogDir, err := filepath.Abs(dir)
-		if err != nil {
-			continue
-		}
-
-		// 安全检查：确保文件路径在日志目录内
-		if strings.HasPrefix(absFilePath, absLogDir) {
-			return absFilePath, nil
+	if unwrap != nil {
+		for _, line := range unwrap.flush() {
+			if !fn(line.lineNumber, line.offset, line.text) {
+				break
+			}
 		}
 	}
-
-	return "", fmt.Errorf("文件路径不在允许的目录内")
+	return nil
 }
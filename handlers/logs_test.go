@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGetLogContentANSI(t *testing.T) {
+	dir := t.TempDir()
+	path := writeLog(t, dir, "app.log", "call 138\x1b[0m00138000 \x1b[31mfailed\x1b[0m")
+
+	tests := []struct {
+		name      string
+		redaction bool
+		ansi      string
+		wantMode  string
+		want      string
+	}{
+		{"strip", false, "strip", "strip", "call 13800138000 failed"},
+		{"raw", false, "raw", "raw", "call 138\x1b[0m00138000 \x1b[31mfailed\x1b[0m"},
+		// 需要脱敏时 raw 按 strip 处理，被转义序列拆开的手机号同样被替换
+		{"raw redacted", true, "raw", "strip", "call 138****8000 failed"},
+		{"strip redacted", true, "strip", "strip", "call 138****8000 failed"},
+	}
+	for _, tt := range tests {
+		useConfig(t, testConfig(dir, tt.redaction))
+		code, response := serve(t, GetLogContent, http.MethodGet, "/?ansi="+tt.ansi+"&file="+url.QueryEscape(path), nil)
+		if code != http.StatusOK {
+			t.Fatalf("%s: status %d: %v", tt.name, code, response["error"])
+		}
+		if response["ansi"] != tt.wantMode {
+			t.Errorf("%s: ansi = %v, want %s", tt.name, response["ansi"], tt.wantMode)
+		}
+		content := jsonStrings(response["content"])
+		if len(content) != 1 || content[0] != tt.want {
+			t.Errorf("%s: content = %q, want %q", tt.name, content, tt.want)
+		}
+		if tt.redaction && strings.Contains(content[0], "00138000") {
+			t.Errorf("%s: phone number leaked: %q", tt.name, content[0])
+		}
+	}
+}
+
+func TestGetLogContentStructured(t *testing.T) {
+	dir := t.TempDir()
+	lines := []string{
+		`{"time":"2026-10-19T08:00:00Z","level":"info","msg":"started","port":8080}`,
+		`{"time":"2026-10-19T08:00:01Z","level":"warning","msg":"slow request","path":"/api"}`,
+		`{"time":"2026-10-19T08:00:02Z","level":"error","msg":"failed","user":{"id":"7"}}`,
+	}
+	path := writeLog(t, dir, "app.log", lines...)
+	useConfig(t, testConfig(dir, false))
+
+	// 每行长度加换行即为下一行的偏移
+	offsets := []float64{0, float64(len(lines[0]) + 1), float64(len(lines[0]) + len(lines[1]) + 2)}
+	tests := []struct {
+		name  string
+		query string
+		want  []int // 期望的行下标
+	}{
+		{"all", "", []int{0, 1, 2}},
+		{"last lines", "&lines=2", []int{1, 2}},
+		{"reverse", "&lines=2&reverse=true", []int{2, 1}},
+		{"levels", "&levels=warn%2B", []int{1, 2}},
+	}
+	for _, tt := range tests {
+		code, response := serve(t, GetLogContent, http.MethodGet, "/?mode=structured&file="+url.QueryEscape(path)+tt.query, nil)
+		if code != http.StatusOK {
+			t.Fatalf("%s: status %d: %v", tt.name, code, response["error"])
+		}
+		if response["format"] != "json" || response["lines"] != float64(len(tt.want)) {
+			t.Errorf("%s: format %v, lines %v", tt.name, response["format"], response["lines"])
+		}
+		entries, _ := response["entries"].([]interface{})
+		if len(entries) != len(tt.want) {
+			t.Fatalf("%s: %d entries, want %d", tt.name, len(entries), len(tt.want))
+		}
+		for i, index := range tt.want {
+			entry, _ := entries[i].(map[string]interface{})
+			if entry["line_number"] != float64(index+1) || entry["offset"] != offsets[index] || entry["raw"] != lines[index] {
+				t.Errorf("%s: entry %d = %v", tt.name, i, entry)
+			}
+		}
+	}
+
+	_, response := serve(t, GetLogContent, http.MethodGet, "/?mode=structured&lines=1&file="+url.QueryEscape(path), nil)
+	entries, _ := response["entries"].([]interface{})
+	entry, _ := entries[0].(map[string]interface{})
+	fields, _ := entry["fields"].(map[string]interface{})
+	if entry["level"] != "ERROR" || entry["message"] != "failed" || !strings.HasPrefix(entry["timestamp"].(string), "2026-10-19") ||
+		len(fields) != 1 || fields["user.id"] != "7" {
+		t.Errorf("parsed entry = %v", entry)
+	}
+
+	// 结构化模式不支持行转换
+	if code, _ := serve(t, GetLogContent, http.MethodGet, "/?mode=structured&transforms=collapse&file="+url.QueryEscape(path), nil); code != http.StatusBadRequest {
+		t.Errorf("structured with transforms: status %d", code)
+	}
+}
@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+	"github.com/anjude/log-tools/rotation"
+)
+
+const (
+	// metaExactLimit 不超过该大小的内容精确统计行数，更大的文件按开头样本的平均行长估算
+	metaExactLimit = 32 * 1024 * 1024
+	// metaSampleSize 估算行数、检测格式和编码时读取的开头样本大小
+	metaSampleSize = 1024 * 1024
+	// metaTailSize 查找最后一个时间时读取的末尾内容大小
+	metaTailSize = 64 * 1024
+	// metaRateWindow 计算写入速度的时间窗口
+	metaRateWindow = 5 * time.Minute
+	// metaRecheckInterval 不在文件目录中的文件（如日志源中确定的文件）重新检查的最小间隔
+	metaRecheckInterval = 10 * time.Second
+	// metaQueueSize 待刷新文件队列的长度，队列满时丢弃，下次列出文件或文件变化时再加入
+	metaQueueSize = 1024
+	// metaEventBufferSize 订阅文件目录事件的缓冲区大小
+	metaEventBufferSize = 1024
+)
+
+// FileMeta 文件的统计信息，后台增量计算，文件列表中只返回已缓存的结果
+type FileMeta struct {
+	Lines      int64   `json:"lines"`                // 行数
+	LinesExact bool    `json:"lines_exact"`          // 行数是否精确，大文件按平均行长估算
+	FirstTime  string  `json:"first_time,omitempty"` // 第一条日志的时间
+	LastTime   string  `json:"last_time,omitempty"`  // 最后一条日志的时间
+	Format     string  `json:"format,omitempty"`     // 日志格式（解析器名称）
+	Encoding   string  `json:"encoding,omitempty"`   // 文件编码：日志源配置的编码、utf-8、binary 或 unknown
+	Compressed bool    `json:"compressed"`           // 是否为压缩文件
+	WriteRate  float64 `json:"write_rate"`           // 最近的写入速度（字节/秒）
+}
+
+// metaEntry 单个文件的统计缓存，文件追加内容后只读取新增部分
+type metaEntry struct {
+	info        os.FileInfo // 统计时的文件信息，用于判断文件是否被替换
+	scanned     int64       // 已统计的字节数
+	newlines    int64       // 换行符数，估算时为估算值
+	partial     bool        // 最后一行是否没有换行符
+	exact       bool
+	first, last time.Time
+	format      string
+	encoding    string
+	compression string
+	checked     time.Time // 最近一次检查的时间
+}
+
+// lines 返回行数，最后一行没有换行符时也计入
+func (e *metaEntry) lines() int64 {
+	if e.partial {
+		return e.newlines + 1
+	}
+	return e.newlines
+}
+
+// metaSample 文件大小的采样，用于计算写入速度
+type metaSample struct {
+	at   time.Time
+	size int64
+}
+
+var (
+	metaEntries = make(map[string]*metaEntry)
+	metaSamples = make(map[string][]metaSample)
+	metaPending = make(map[string]bool)
+	metaMu      sync.Mutex
+	metaQueue   = make(chan string, metaQueueSize)
+	metaWatch   sync.Once
+)
+
+// startFileMeta 启动文件统计的后台任务：订阅文件目录事件，并逐个刷新待统计的文件
+func startFileMeta() {
+	metaWatch.Do(func() {
+		go watchFileMeta()
+		go refreshFileMetaLoop()
+	})
+}
+
+// watchFileMeta 订阅文件目录事件，文件新增或变化时加入刷新队列，文件目录重建后订阅新的目录
+func watchFileMeta() {
+	cat := currentCatalog()
+	for cat != nil {
+		events, cancel := cat.Subscribe(metaEventBufferSize)
+		for event := range events {
+			absPath, err := filepath.Abs(event.File.Path)
+			if err != nil {
+				continue
+			}
+			switch event.Type {
+			case catalog.EventRemoved:
+				forgetFileMeta(absPath)
+				forgetFormatStats(absPath)
+				forgetContainerFormat(absPath)
+			case catalog.EventRotated:
+				// 截断后可能已写入超过原大小的内容，无法通过大小判断，需要重新统计
+				forgetFileMeta(absPath)
+				forgetFormatStats(absPath)
+				forgetContainerFormat(absPath)
+				enqueueFileMeta(absPath)
+			default:
+				enqueueFileMeta(absPath)
+			}
+		}
+		cancel()
+
+		// 文件目录已重建，配置（如编码、解析器）可能已变化，重新统计所有文件
+		next := currentCatalog()
+		if next == cat {
+			return
+		}
+		cat = next
+		metaMu.Lock()
+		metaEntries = make(map[string]*metaEntry)
+		metaMu.Unlock()
+	}
+}
+
+// refreshFileMetaLoop 逐个刷新队列中的文件
+func refreshFileMetaLoop() {
+	for absPath := range metaQueue {
+		metaMu.Lock()
+		delete(metaPending, absPath)
+		metaMu.Unlock()
+		if err := refreshFileMeta(absPath); err != nil {
+			fmt.Printf("统计文件信息失败 %s: %v\n", absPath, err)
+		}
+	}
+}
+
+// enqueueFileMeta 将文件加入刷新队列，已在队列中或队列已满时忽略
+func enqueueFileMeta(absPath string) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	if metaPending[absPath] {
+		return
+	}
+	select {
+	case metaQueue <- absPath:
+		metaPending[absPath] = true
+	default:
+	}
+}
+
+// forgetFileMeta 删除文件的统计缓存
+func forgetFileMeta(absPath string) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	delete(metaEntries, absPath)
+	delete(metaSamples, absPath)
+}
+
+// cachedFileMeta 返回文件已缓存的统计信息，不读取文件；没有缓存或需要重新检查时加入刷新队列
+func cachedFileMeta(filePath string) *FileMeta {
+	entry, samples, ok := cachedFileMetaEntry(filePath)
+	if !ok {
+		return nil
+	}
+	return entry.meta(samples, time.Now())
+}
+
+// cachedFileMetaEntry 返回文件统计缓存的副本，没有缓存或需要重新检查时加入刷新队列
+func cachedFileMetaEntry(filePath string) (metaEntry, []metaSample, bool) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return metaEntry{}, nil, false
+	}
+	entry, samples, ok := lookupFileMeta(absPath)
+	if !ok || time.Since(entry.checked) > metaRecheckInterval {
+		enqueueFileMeta(absPath)
+	}
+	return entry, samples, ok
+}
+
+// lookupFileMeta 返回文件统计缓存的副本
+func lookupFileMeta(absPath string) (metaEntry, []metaSample, bool) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	entry, ok := metaEntries[absPath]
+	if !ok {
+		return metaEntry{}, nil, false
+	}
+	return *entry, metaSamples[absPath], true
+}
+
+// meta 将缓存转换为接口返回的统计信息
+func (e *metaEntry) meta(samples []metaSample, now time.Time) *FileMeta {
+	meta := &FileMeta{
+		Lines:      e.lines(),
+		LinesExact: e.exact,
+		Format:     e.format,
+		Encoding:   e.encoding,
+		Compressed: e.compression != "",
+		WriteRate:  writeRate(samples, now),
+	}
+	if !e.first.IsZero() {
+		meta.FirstTime = e.first.Format("2006-01-02 15:04:05")
+	}
+	if !e.last.IsZero() {
+		meta.LastTime = e.last.Format("2006-01-02 15:04:05")
+	}
+	return meta
+}
+
+// familyMeta 汇总轮转文件组各成员的统计信息：行数相加，时间取最早和最晚的成员，
+// 格式、编码和写入速度取最新的成员（通常为活动文件）；有成员尚未统计时返回 nil
+func familyMeta(f *family) *FileMeta {
+	now := time.Now()
+	merged := &FileMeta{LinesExact: true}
+	var first, last time.Time
+	missing := false
+	for i, m := range f.members {
+		// 继续遍历其他成员，使所有未统计的成员都加入刷新队列
+		entry, samples, ok := cachedFileMetaEntry(m.file.Path)
+		if !ok {
+			missing = true
+			continue
+		}
+		merged.Lines += entry.lines()
+		merged.LinesExact = merged.LinesExact && entry.exact
+		merged.Compressed = merged.Compressed || entry.compression != ""
+		if !entry.first.IsZero() && (first.IsZero() || entry.first.Before(first)) {
+			first = entry.first
+		}
+		if entry.last.After(last) {
+			last = entry.last
+		}
+		if i == len(f.members)-1 {
+			merged.Format = entry.format
+			merged.Encoding = entry.encoding
+			merged.WriteRate = writeRate(samples, now)
+		}
+	}
+	if missing {
+		return nil
+	}
+	if !first.IsZero() {
+		merged.FirstTime = first.Format("2006-01-02 15:04:05")
+	}
+	if !last.IsZero() {
+		merged.LastTime = last.Format("2006-01-02 15:04:05")
+	}
+	return merged
+}
+
+// refreshFileMeta 重新统计文件：文件只是追加了内容时只读取新增部分，被替换或截断时重新统计
+func refreshFileMeta(absPath string) error {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		forgetFileMeta(absPath)
+		return nil
+	}
+	now := time.Now()
+	recordFileSize(absPath, info.Size(), now)
+
+	previous, _, ok := lookupFileMeta(absPath)
+	var entry *metaEntry
+	switch {
+	case ok && previous.compression == "" && os.SameFile(previous.info, info) &&
+		info.Size() >= previous.scanned && info.Size()-previous.scanned <= metaExactLimit:
+		entry = &previous
+		if entry.encoding == "binary" {
+			entry.scanned = info.Size()
+		} else if info.Size() > previous.scanned {
+			err = appendFileMeta(absPath, entry, info.Size())
+		}
+	case ok && previous.compression != "" && os.SameFile(previous.info, info) &&
+		info.Size() == previous.scanned && info.ModTime().Equal(previous.info.ModTime()):
+		// 压缩文件不会追加内容，没有变化时不重新解压
+		entry = &previous
+	default:
+		entry, err = scanFileMeta(absPath, info)
+	}
+	if err != nil {
+		return err
+	}
+	entry.info = info
+	entry.checked = now
+
+	metaMu.Lock()
+	metaEntries[absPath] = entry
+	metaMu.Unlock()
+	return nil
+}
+
+// scanFileMeta 完整统计文件
+func scanFileMeta(absPath string, info os.FileInfo) (*metaEntry, error) {
+	entry := &metaEntry{compression: rotation.Detect(absPath)}
+	if entry.compression == "" {
+		if binary, _ := isBinaryFile(absPath); binary {
+			entry.encoding = "binary"
+			entry.exact = true
+			entry.scanned = info.Size()
+			return entry, nil
+		}
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var head, tail metaScan
+	switch {
+	case entry.compression != "":
+		// 解压后超过精确统计的大小时，按已读取的压缩数据比例估算行数
+		counted := &countingReader{r: file}
+		r, err := rotation.NewReader(counted, entry.compression)
+		if err != nil {
+			return nil, fmt.Errorf("解压失败: %w", err)
+		}
+		defer r.Close()
+		head, err = scanMetaContent(r, metaExactLimit)
+		if err != nil {
+			return nil, err
+		}
+		entry.exact = head.eof
+		entry.newlines = head.newlines
+		if !head.eof && counted.n > 0 {
+			entry.newlines = head.newlines * info.Size() / counted.n
+		}
+		if head.eof {
+			tail = head
+		}
+
+	case info.Size() <= metaExactLimit:
+		head, err = scanMetaContent(file, metaExactLimit)
+		if err != nil {
+			return nil, err
+		}
+		entry.exact = true
+		entry.newlines = head.newlines
+		tail = head
+
+	default:
+		head, err = scanMetaContent(file, metaSampleSize)
+		if err != nil {
+			return nil, err
+		}
+		if head.read > 0 {
+			entry.newlines = head.newlines * info.Size() / head.read
+		}
+		if _, err := file.Seek(info.Size()-metaTailSize, io.SeekStart); err != nil {
+			return nil, err
+		}
+		tail, err = scanMetaContent(file, metaTailSize)
+		if err != nil {
+			return nil, err
+		}
+		tail.truncated = true
+	}
+	entry.scanned = info.Size()
+	entry.partial = tail.read > 0 && tail.lastByte != '\n'
+
+	sample := head.headLines()
+	p := metaParser(absPath, sample)
+	entry.format = p.Name()
+	entry.encoding = metaEncoding(absPath, head.head)
+	entry.first, _ = metaTimes(absPath, p, sample)
+	if tail.read > 0 {
+		_, entry.last = metaTimes(absPath, p, tail.tailLines())
+	}
+	return entry, nil
+}
+
+// appendFileMeta 统计文件追加的内容
+func appendFileMeta(absPath string, entry *metaEntry, size int64) error {
+	file, err := os.Open(absPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Seek(entry.scanned, io.SeekStart); err != nil {
+		return err
+	}
+	appended, err := scanMetaContent(io.LimitReader(file, size-entry.scanned), metaExactLimit)
+	if err != nil {
+		return err
+	}
+
+	entry.scanned += appended.read
+	entry.newlines += appended.newlines
+	if appended.read > 0 {
+		entry.partial = appended.lastByte != '\n'
+	}
+	p, err := parser.Lookup(entry.format)
+	if err != nil || p == nil {
+		p, _ = parser.Get(parser.NameText)
+	}
+	if _, last := metaTimes(absPath, p, appended.tailLines()); !last.IsZero() {
+		entry.last = last
+	}
+	if entry.first.IsZero() {
+		entry.first, _ = metaTimes(absPath, p, appended.headLines())
+	}
+	return nil
+}
+
+// metaScan 流式读取内容的统计结果
+type metaScan struct {
+	head      []byte // 开头的内容，最多 metaSampleSize
+	tail      []byte // 末尾的内容，最多 metaTailSize
+	read      int64  // 读取的字节数
+	newlines  int64
+	lastByte  byte
+	eof       bool // 是否读到了内容末尾
+	truncated bool // tail 是否不是从内容开头开始
+}
+
+// scanMetaContent 读取内容统计换行符，保留开头和末尾的内容，最多读取 limit 字节
+func scanMetaContent(r io.Reader, limit int64) (metaScan, error) {
+	var s metaScan
+	buf := make([]byte, 64*1024)
+	for s.read < limit {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			s.read += int64(n)
+			s.newlines += int64(bytes.Count(chunk, []byte{'\n'}))
+			s.lastByte = chunk[n-1]
+			if room := metaSampleSize - len(s.head); room > 0 {
+				s.head = append(s.head, chunk[:min(room, n)]...)
+			}
+			s.tail = append(s.tail, chunk...)
+			if drop := len(s.tail) - metaTailSize; drop > 0 {
+				s.tail = append(s.tail[:0], s.tail[drop:]...)
+				s.truncated = true
+			}
+		}
+		if err == io.EOF {
+			s.eof = true
+			break
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// headLines 返回开头内容中的完整行，没有读完时去掉不完整的最后一行
+func (s *metaScan) headLines() []string {
+	content := s.head
+	if !s.eof || int64(len(s.head)) < s.read {
+		if i := bytes.LastIndexByte(content, '\n'); i >= 0 {
+			content = content[:i]
+		}
+	}
+	return splitMetaLines(content)
+}
+
+// tailLines 返回末尾内容中的行，内容被截断时去掉不完整的第一行
+func (s *metaScan) tailLines() []string {
+	content := s.tail
+	if s.truncated {
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			content = content[i+1:]
+		}
+	}
+	return splitMetaLines(content)
+}
+
+// splitMetaLines 按行切分内容，去掉行尾的 \r 和空行
+func splitMetaLines(content []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// metaParser 返回统计时间使用的解析器：配置规则分配的解析器，未分配时按样本检测
+// 不使用 parserForFile，避免统计时计入格式解析统计
+func metaParser(absPath string, sample []string) parser.Parser {
+	p, _ := parser.Lookup(assignedParserName(absPath))
+	if p != nil {
+		return p
+	}
+	if len(sample) > detectSampleLines {
+		sample = sample[:detectSampleLines]
+	}
+	return parser.Detect(sample)
+}
+
+// metaEncoding 返回文件编码：日志源配置的编码，否则按开头的内容判断是否为 UTF-8
+func metaEncoding(absPath string, head []byte) string {
+	if source, ok := sourceOf(config.GetConfig(), absPath); ok && source.Encoding != "" {
+		return strings.ToLower(source.Encoding)
+	}
+	// 开头样本可能在多字节字符中间截断
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	if utf8.Valid(head) {
+		return "utf-8"
+	}
+	return "unknown"
+}
+
+// metaTimes 返回行中第一个和最后一个可识别的时间，行按日志源编码转换并解开容器日志格式
+func metaTimes(absPath string, p parser.Parser, lines []string) (first, last time.Time) {
+	unwrap := newContainerUnwrapper(absPath, "")
+	decoder := sourceDecoder(absPath)
+	tracker := lineTimeTracker{parser: p}
+	observe := func(line string) {
+		if ts, ok := tracker.observe(stripANSI(line)); ok {
+			if first.IsZero() {
+				first = ts
+			}
+			last = ts
+		}
+	}
+	for i, line := range lines {
+		line = decodeLine(decoder, line)
+		if unwrap == nil {
+			observe(line)
+			continue
+		}
+		if unwrapped, ok := unwrap.feed(i+1, 0, line); ok {
+			observe(unwrapped.text)
+		}
+	}
+	if unwrap != nil {
+		for _, line := range unwrap.flush() {
+			observe(line.text)
+		}
+	}
+	return first, last
+}
+
+// recordFileSize 记录文件大小的采样，文件变小（轮转或截断）时重新开始采样
+func recordFileSize(absPath string, size int64, now time.Time) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	samples := metaSamples[absPath]
+	if n := len(samples); n > 0 && samples[n-1].size > size {
+		samples = nil
+	}
+	if n := len(samples); n > 0 && samples[n-1].size == size {
+		return
+	}
+	samples = append(samples, metaSample{at: now, size: size})
+
+	// 只保留时间窗口内的采样和窗口开始前的最后一个采样
+	from := now.Add(-metaRateWindow)
+	keep := 0
+	for keep+1 < len(samples) && !samples[keep+1].at.After(from) {
+		keep++
+	}
+	metaSamples[absPath] = append([]metaSample(nil), samples[keep:]...)
+}
+
+// writeRate 按时间窗口内的大小变化计算写入速度（字节/秒）
+// 采样只在大小变化时记录，窗口开始前的最后一个采样即为窗口开始时的大小
+func writeRate(samples []metaSample, now time.Time) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	from := now.Add(-metaRateWindow)
+	base, start := samples[0], samples[0].at
+	for _, s := range samples[1:] {
+		if s.at.After(from) {
+			break
+		}
+		base = s
+	}
+	if start.Before(from) {
+		start = from
+	}
+	elapsed := now.Sub(start).Seconds()
+	if elapsed < 1 {
+		return 0
+	}
+	growth := samples[len(samples)-1].size - base.size
+	if growth <= 0 {
+		return 0
+	}
+	return float64(growth) / elapsed
+}
+
+// countingReader 统计已读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read 读取并计数
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteRate(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	sample := func(ago time.Duration, size int64) metaSample {
+		return metaSample{at: now.Add(-ago), size: size}
+	}
+	tests := []struct {
+		name    string
+		samples []metaSample
+		want    float64
+	}{
+		{"no samples", nil, 0},
+		{"single sample", []metaSample{sample(time.Minute, 100)}, 0},
+		{"within window", []metaSample{sample(100*time.Second, 0), sample(0, 1000)}, 10},
+		// 窗口开始前的采样作为基准，时长按窗口计算
+		{"before window", []metaSample{sample(10*time.Minute, 0), sample(6*time.Minute, 600), sample(0, 3600)}, 10},
+		{"truncated", []metaSample{sample(time.Minute, 1000), sample(0, 10)}, 0},
+		{"too short", []metaSample{sample(500*time.Millisecond, 0), sample(0, 1000)}, 0},
+	}
+	for _, tt := range tests {
+		if got := writeRate(tt.samples, now); got != tt.want {
+			t.Errorf("%s: writeRate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+	"github.com/anjude/log-tools/query"
+	"github.com/anjude/log-tools/redact"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 查询执行限制的默认值
+const (
+	defaultQueryMaxScanLines  = 5000000
+	defaultQueryMaxGroups     = 10000
+	defaultQueryMaxResultRows = 1000
+	defaultQueryTimeout       = 30 * time.Second
+)
+
+// QueryRequest 查询请求结构
+type QueryRequest struct {
+	Query string `json:"query" binding:"required"` // 查询语句
+}
+
+// queryLimits 根据配置返回查询执行限制
+func queryLimits(cfg *config.Config) query.Limits {
+	limits := query.Limits{
+		MaxScanRows:   defaultQueryMaxScanLines,
+		MaxGroups:     defaultQueryMaxGroups,
+		MaxResultRows: defaultQueryMaxResultRows,
+		Timeout:       defaultQueryTimeout,
+	}
+	if cfg.Logs.Query.MaxScanLines > 0 {
+		limits.MaxScanRows = cfg.Logs.Query.MaxScanLines
+	}
+	if cfg.Logs.Query.MaxGroups > 0 {
+		limits.MaxGroups = cfg.Logs.Query.MaxGroups
+	}
+	if cfg.Logs.Query.MaxResultRows > 0 {
+		limits.MaxResultRows = cfg.Logs.Query.MaxResultRows
+	}
+	if cfg.Logs.Query.Timeout > 0 {
+		limits.Timeout = time.Duration(cfg.Logs.Query.Timeout) * time.Second
+	}
+	return limits
+}
+
+// QueryLogs 使用类SQL查询语言查询解析后的日志记录，返回表格形式的结果
+// 示例：SELECT path, count(*), p95(request_time) FROM 'nginx/*.log' WHERE status >= 500 AND ts > now()-1h GROUP BY path ORDER BY 2 DESC LIMIT 20
+func QueryLogs(c *gin.Context) {
+	var req QueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	q, err := query.Parse(req.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("查询语句错误: %v", err),
+		})
+		return
+	}
+
+	cfg := config.GetConfig()
+	fmt.Printf("执行查询: %s\n", req.Query)
+
+	// 需要脱敏的用户查询脱敏后的记录，WHERE、GROUP BY 等条件都不能使用被隐藏的内容，结果中的值也都来自脱敏后的记录
+	redaction := newRedaction(c)
+	result, err := query.Execute(c.Request.Context(), q, newLogQuerySource(cfg, redaction), queryLimits(cfg))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("查询执行失败: %v", err),
+		})
+		return
+	}
+
+	fmt.Printf("查询完成: 扫描 %d 行，匹配 %d 行，耗时 %dms\n", result.Stats.Scanned, result.Stats.Matched, result.Stats.ElapsedMs)
+
+	response := gin.H{
+		"columns": result.Columns,
+		"rows":    result.Rows,
+		"stats":   result.Stats,
+	}
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// logQuerySource 基于日志文件列表和格式解析器的查询数据源
+type logQuerySource struct {
+	cfg       *config.Config
+	display   map[string]string // 绝对路径 -> 显示路径
+	redaction *redact.Session   // 不为空时扫描脱敏后的行
+}
+
+func newLogQuerySource(cfg *config.Config, redaction *redact.Session) *logQuerySource {
+	return &logQuerySource{cfg: cfg, display: make(map[string]string), redaction: redaction}
+}
+
+// Files 返回匹配文件模式的日志文件
+// 模式按 glob 规则匹配文件列表中的显示路径（固定文件的配置路径、扫描文件相对于日志目录的路径）、文件名或绝对路径
+func (s *logQuerySource) Files(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(strings.TrimPrefix(pattern, "./"))
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("无效的文件模式 %s: %v", pattern, err)
+	}
+
+	logFiles, err := collectLogFiles(s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("获取日志文件失败: %v", err)
+	}
+
+	var matched []string
+	for _, logFile := range logFiles {
+		if logFile.Binary {
+			continue
+		}
+		// journal 虚拟日志源只按完整路径匹配（如 journal://*），避免 * 匹配到所有单元
+		if isJournalSource(logFile.Path) {
+			if ok, _ := path.Match(pattern, logFile.Path); !ok {
+				continue
+			}
+			absPath, err := resolveJournalSource(logFile.Path)
+			if err != nil {
+				fmt.Printf("转换journal日志源失败 %s: %v\n", logFile.Path, err)
+				continue
+			}
+			if _, seen := s.display[absPath]; !seen {
+				s.display[absPath] = logFile.Path
+				matched = append(matched, absPath)
+			}
+			continue
+		}
+		// 轮转文件组按活动文件的路径（包括相对于日志目录的路径）或文件名匹配，查询整个文件组
+		if isFamilySource(logFile.Path) {
+			active := filepath.ToSlash(strings.TrimPrefix(logFile.Path, familyScheme))
+			ok, _ := path.Match(pattern, logFile.Path)
+			if !ok {
+				ok, _ = path.Match(pattern, active)
+			}
+			if !ok {
+				ok, _ = path.Match(pattern, path.Join(filepath.ToSlash(logFile.Directory), path.Base(active)))
+			}
+			if !ok {
+				ok, _ = path.Match(pattern, path.Base(active))
+			}
+			if !ok {
+				continue
+			}
+			absPath, err := resolveFamilySource(logFile.Path)
+			if err != nil {
+				fmt.Printf("合并轮转文件组失败 %s: %v\n", logFile.Path, err)
+				continue
+			}
+			if _, seen := s.display[absPath]; !seen {
+				s.display[absPath] = logFile.Path
+				matched = append(matched, absPath)
+			}
+			continue
+		}
+		absPath, err := filepath.Abs(logFile.FullPath)
+		if err != nil {
+			continue
+		}
+		if _, seen := s.display[absPath]; seen {
+			continue
+		}
+		display := filepath.ToSlash(strings.TrimPrefix(logFile.Path, "./"))
+		ok, _ := path.Match(pattern, display)
+		if !ok {
+			ok, _ = path.Match(pattern, path.Base(display))
+		}
+		if !ok {
+			ok, _ = path.Match(pattern, filepath.ToSlash(absPath))
+		}
+		if ok {
+			s.display[absPath] = display
+			matched = append(matched, absPath)
+		}
+	}
+	return matched, nil
+}
+
+// Scan 逐行解析文件，没有时间戳的行沿用上一行的时间
+// 需要脱敏时先对整行脱敏再解析，所有字段都来自脱敏后的内容，命中次数在扫描时记录
+func (s *logQuerySource) Scan(_ context.Context, file string, fn func(query.Row) bool) error {
+	p := parserForFile(file)
+	var tracker lineTimeTracker
+	row := &recordRow{file: s.display[file]}
+
+	return scanFileLines(file, func(lineNumber int, line string) bool {
+		if strings.TrimSpace(line) == "" {
+			return true
+		}
+		line = s.redaction.String(line)
+		record := parseFileLine(p, lineNumber, line)
+		tracker.inRangeRecord(record, timeRange{})
+
+		row.line = lineNumber
+		row.raw = line
+		row.record = record
+		row.ts, row.hasTime = tracker.last, tracker.known
+		return fn(row)
+	})
+}
+
+// recordRow 查询中的一行日志记录
+// 内置字段：file、line、ts、level、message、raw，其余字段来自格式解析结果
+type recordRow struct {
+	file    string
+	line    int
+	raw     string
+	record  *parser.Record
+	ts      time.Time
+	hasTime bool
+}
+
+// Field 返回字段值
+func (r *recordRow) Field(name string) (query.Value, bool) {
+	switch strings.ToLower(name) {
+	case "file":
+		return r.file, true
+	case "line":
+		return float64(r.line), true
+	case "raw":
+		return r.raw, true
+	case "ts", "time", "timestamp":
+		if !r.hasTime {
+			return nil, false
+		}
+		return r.ts, true
+	case "level":
+		level := r.record.Level
+		if level == "" {
+			level = parser.DetectLevel(r.raw)
+		}
+		if level == "" {
+			return nil, false
+		}
+		return level, true
+	case "message", "msg":
+		return r.record.Message, true
+	}
+	value, ok := r.record.Field(name)
+	if !ok {
+		return nil, false
+	}
+	return value, true
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/query"
+	"github.com/anjude/log-tools/redact"
+)
+
+func TestQueryRedactionCountedOnce(t *testing.T) {
+	dir := t.TempDir()
+	lines := []string{
+		"INFO call 13800138000 ok",
+		"INFO mail alice@example.com",
+		"WARN token=abc123 expired",
+		"INFO nothing here",
+	}
+	os.WriteFile(filepath.Join(dir, "app.log"), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	cfg := &config.Config{Logs: config.LogsConfig{
+		Pattern: `\.log$`,
+		Sources: []config.SourceConfig{{Name: "app", Directories: []config.DirectoryConfig{{Path: dir}}}},
+	}}
+
+	r, err := redact.New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sql  string
+		want []string
+		hits map[string]int
+	}{
+		{
+			"SELECT raw FROM 'app.log'",
+			[]string{"INFO call 138****8000 ok", "INFO mail a****@example.com", "WARN token=*** expired", "INFO nothing here"},
+			map[string]int{redact.DetectorPhone: 1, redact.DetectorEmail: 1, redact.DetectorCredential: 1},
+		},
+		// 条件不能匹配被隐藏的内容
+		{"SELECT raw FROM 'app.log' WHERE raw LIKE '%13800138000%'", nil, map[string]int{redact.DetectorPhone: 1, redact.DetectorEmail: 1, redact.DetectorCredential: 1}},
+	}
+	for _, tt := range tests {
+		session := r.NewSession()
+		q, err := query.Parse(tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := query.Execute(context.Background(), q, newLogQuerySource(cfg, session), query.Limits{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, row := range result.Rows {
+			got = append(got, row[0].(string))
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: rows = %q", tt.sql, got)
+		}
+		hits := session.Hits()
+		for name, n := range tt.hits {
+			if hits[name] != n {
+				t.Errorf("%s: hits = %v, want %v", tt.sql, hits, tt.hits)
+				break
+			}
+		}
+		if session.Total() != 3 {
+			t.Errorf("%s: total = %d", tt.sql, session.Total())
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/middleware"
+	"github.com/anjude/log-tools/parser"
+	"github.com/anjude/log-tools/redact"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 当前生效的脱敏配置，配置重新加载时更新
+var (
+	redactor       *redact.Redactor
+	redactExempt   map[string]bool
+	redactAuditLog string
+	redactMu       sync.RWMutex
+	auditMu        sync.Mutex
+)
+
+// RegisterRedaction 根据配置编译脱敏规则，规则有误时保留上一次的配置
+func RegisterRedaction(cfg *config.Config) {
+	rc := cfg.Redaction
+	var r *redact.Redactor
+	if rc.Enabled {
+		custom := make([]redact.Rule, 0, len(rc.Rules))
+		for _, rule := range rc.Rules {
+			custom = append(custom, redact.Rule{
+				Name:        rule.Name,
+				Regex:       rule.Regex,
+				Replacement: rule.Replacement,
+			})
+		}
+		var err error
+		r, err = redact.New(rc.Detectors, custom)
+		if err != nil {
+			fmt.Printf("脱敏配置错误，继续使用原有配置: %v\n", err)
+			return
+		}
+	}
+
+	exempt := make(map[string]bool)
+	for _, user := range rc.ExemptUsers {
+		exempt[user] = true
+	}
+
+	redactMu.Lock()
+	redactor = r
+	redactExempt = exempt
+	redactAuditLog = rc.AuditFile
+	redactMu.Unlock()
+
+	if r != nil {
+		fmt.Printf("脱敏已启用: 检测器=%v, 自定义规则=%d, 豁免用户=%v\n", rc.Detectors, len(rc.Rules), rc.ExemptUsers)
+	}
+}
+
+// newRedaction 创建当前请求的脱敏会话，未启用脱敏或当前用户被豁免时返回 nil
+func newRedaction(c *gin.Context) *redact.Session {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+	if redactor == nil || redactExempt[middleware.GetCurrentUser(c)] {
+		return nil
+	}
+	return redactor.NewSession()
+}
+
+// redactRecord 返回脱敏后的记录副本，不修改原记录
+func redactRecord(s *redact.Session, record *parser.Record) *parser.Record {
+	if s == nil || record == nil {
+		return record
+	}
+	copied := *record
+	copied.Message = s.String(record.Message)
+	if record.Fields != nil {
+		copied.Fields = make(map[string]string, len(record.Fields))
+		for key, value := range record.Fields {
+			copied.Fields[key] = s.String(value)
+		}
+	}
+	return &copied
+}
+
+// finishRedaction 在响应中附带脱敏命中次数，并记录审计日志
+func finishRedaction(c *gin.Context, s *redact.Session, response gin.H) {
+	if s == nil {
+		return
+	}
+	total := s.Total()
+	response["redacted"] = total
+	if total == 0 {
+		return
+	}
+	response["redactions"] = s.Hits()
+	auditRedaction(c, s)
+}
+
+// auditRedaction 记录脱敏审计日志，没有命中时不记录
+func auditRedaction(c *gin.Context, s *redact.Session) {
+	if s.Total() == 0 {
+		return
+	}
+
+	entry := fmt.Sprintf("%s 用户=%s 接口=%s %s 命中=%s\n",
+		time.Now().Format("2006-01-02 15:04:05"), middleware.GetCurrentUser(c),
+		c.Request.Method, c.Request.URL.Path, s.Summary())
+	fmt.Printf("脱敏记录: %s", entry)
+
+	redactMu.RLock()
+	auditFile := redactAuditLog
+	redactMu.RUnlock()
+	if auditFile == "" {
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(auditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		fmt.Printf("写入脱敏审计日志失败: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(entry); err != nil {
+		fmt.Printf("写入脱敏审计日志失败: %v\n", err)
+	}
+}
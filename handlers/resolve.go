@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+)
+
+// fileIDPrefix 文件ID的前缀，其后为 base64url 编码的 "<日志源>\x00<日志目录>\x00<日志源中的路径>"
+const fileIDPrefix = "id:"
+
+// fixedScheme 文件ID中日志源确定文件的路径前缀，其后为配置中的路径
+const fixedScheme = "file://"
+
+// fileID 生成文件的不透明ID，由日志源名称、所属日志目录和文件在目录中的路径组成，三者不变时ID不变
+// 目录中的文件使用清理后的日志目录和相对于它的路径，同一日志源的多个目录中相同的相对路径不会冲突；
+// 确定的文件为 file://<配置中的路径>，轮转文件组为 family://<相对路径>，journal 虚拟日志源为其 journal:// 路径，
+// 这些文件不属于日志目录，dir 为空
+func fileID(source, dir, p string) string {
+	if dir != "" {
+		dir = filepath.ToSlash(filepath.Clean(dir))
+	}
+	return fileIDPrefix + base64.RawURLEncoding.EncodeToString([]byte(source+"\x00"+dir+"\x00"+p))
+}
+
+// parseFileID 解析文件ID，返回日志源名称、日志目录和日志源中的路径
+// 兼容不含日志目录的旧ID "<日志源>\x00<路径>"，此时 dir 为空
+func parseFileID(id string) (source, dir, p string, ok bool) {
+	encoded, found := strings.CutPrefix(id, fileIDPrefix)
+	if !found {
+		return "", "", "", false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", "", false
+	}
+	parts := strings.Split(string(decoded), "\x00")
+	switch len(parts) {
+	case 2:
+		source, p = parts[0], parts[1]
+	case 3:
+		source, dir, p = parts[0], parts[1], parts[2]
+	default:
+		return "", "", "", false
+	}
+	return source, dir, p, source != "" && p != ""
+}
+
+// resolveFile 将请求中的文件解析为可读取的绝对路径，所有读取文件的接口都通过它访问文件
+// 文件可以是文件列表中的 id，也可以是文件列表中的路径或绝对路径（兼容旧的调用方式）
+// 结果必须是日志源中确定的文件，或文件目录中的文件且真实路径位于日志目录内；
+// 轮转文件组和 journal 虚拟日志源返回合并或转换后的缓存文件
+func resolveFile(ref string) (string, error) {
+	cfg := config.GetConfig()
+	if source, dir, p, ok := parseFileID(ref); ok {
+		return resolveSourcePath(cfg, source, dir, p)
+	}
+	if isJournalSource(ref) {
+		return resolveJournalSource(ref)
+	}
+	if isFamilySource(ref) {
+		return resolveFamilySource(ref)
+	}
+	return resolvePath(cfg, ref)
+}
+
+// resolveSourcePath 解析文件ID中日志源内的路径，dir 为空（旧ID）时在日志源的各目录中查找，匹配多个时报错
+func resolveSourcePath(cfg *config.Config, sourceName, dir, p string) (string, error) {
+	if sourceName == config.JournalSourceName && isJournalSource(p) {
+		return resolveJournalSource(p)
+	}
+	source, ok := findSource(cfg, sourceName)
+	if !ok {
+		return "", fmt.Errorf("日志源不存在: %s", sourceName)
+	}
+
+	if configured, ok := strings.CutPrefix(p, fixedScheme); ok {
+		for _, file := range source.Files {
+			if file == configured {
+				return resolveFixedFile(file)
+			}
+		}
+		return "", fmt.Errorf("日志源 %s 中没有配置该文件", source.Title())
+	}
+
+	rel, isFamily := strings.CutPrefix(p, familyScheme)
+	var found []string
+	for _, directory := range source.Directories {
+		if dir != "" && filepath.ToSlash(filepath.Clean(directory.Path)) != dir {
+			continue
+		}
+		candidate := filepath.Join(directory.Path, filepath.FromSlash(rel))
+		var absPath string
+		var err error
+		if isFamily {
+			absPath, err = resolveFamilySource(familyScheme + candidate)
+		} else {
+			absPath, err = resolveCatalogPath(cfg, candidate)
+		}
+		if err == nil {
+			found = append(found, absPath)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("文件不存在或不在允许的目录内")
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("日志源 %s 的多个目录中存在该文件，请刷新文件列表", source.Title())
+	}
+}
+
+// resolvePath 解析文件路径：日志源中确定的文件按配置路径匹配，相对路径依次相对于各日志目录和运行目录查找
+func resolvePath(cfg *config.Config, filePath string) (string, error) {
+	cleanPath := filepath.Clean(filePath)
+	for _, source := range cfg.LogSources() {
+		for _, file := range source.Files {
+			if filepath.Clean(file) == cleanPath || samePath(file, cleanPath) {
+				return resolveFixedFile(file)
+			}
+		}
+	}
+
+	candidates := []string{cleanPath}
+	if !filepath.IsAbs(cleanPath) {
+		candidates = nil
+		for _, dir := range cfg.LogDirectories() {
+			candidates = append(candidates, filepath.Join(dir, cleanPath))
+		}
+		candidates = append(candidates, cleanPath)
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		absPath, err := resolveCatalogPath(cfg, candidate)
+		if err == nil {
+			return absPath, nil
+		}
+		lastErr = err
+	}
+	fmt.Printf("文件路径解析失败 %s: %v\n", filePath, lastErr)
+	return "", lastErr
+}
+
+// resolveCatalogPath 检查文件位于日志目录内（包括解析符号链接后的真实路径）且在文件目录中，返回绝对路径
+func resolveCatalogPath(cfg *config.Config, candidate string) (string, error) {
+	absPath, err := filepath.Abs(candidate)
+	if err != nil {
+		return "", fmt.Errorf("文件路径格式错误")
+	}
+	if !catalog.Allows(catalogRoots(cfg), absPath) {
+		return "", fmt.Errorf("文件路径不在允许的目录内")
+	}
+	if !inCatalog(cfg, absPath) {
+		return "", fmt.Errorf("文件不存在或不在文件列表中")
+	}
+	return absPath, nil
+}
+
+// inCatalog 判断文件是否在文件目录中，文件目录未建立时遍历目录
+func inCatalog(cfg *config.Config, absPath string) bool {
+	if cat := currentCatalog(); cat != nil {
+		_, ok := cat.Lookup(absPath)
+		return ok
+	}
+	files, err := catalogFiles(cfg)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if abs, err := filepath.Abs(f.Path); err == nil && abs == absPath {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveFixedFile 返回日志源中确定的文件的绝对路径，确定的文件可以不在日志目录内
+func resolveFixedFile(configured string) (string, error) {
+	absPath, err := filepath.Abs(configured)
+	if err != nil {
+		return "", fmt.Errorf("文件路径格式错误")
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return "", fmt.Errorf("文件不存在: %s", filepath.Base(absPath))
+	}
+	return absPath, nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anjude/log-tools/catalog"
+	"github.com/anjude/log-tools/config"
+)
+
+func TestParseFileID(t *testing.T) {
+	tests := []struct {
+		source, dir, path string
+	}{
+		{"default", "/var/log", "app.log"},
+		{"order", "logs/order", "sub/dir/order.log"},
+		{"order", "", fixedScheme + "/var/log/订单.log"},
+		{"default", "/var/log", familyScheme + "app.log"},
+		{config.JournalSourceName, "", "journal://?unit=nginx.service"},
+	}
+	for _, tt := range tests {
+		id := fileID(tt.source, tt.dir, tt.path)
+		if !strings.HasPrefix(id, fileIDPrefix) || strings.ContainsAny(id, "/+= ") {
+			t.Errorf("fileID(%q, %q, %q) = %q", tt.source, tt.dir, tt.path, id)
+		}
+		source, dir, p, ok := parseFileID(id)
+		if !ok || source != tt.source || dir != tt.dir || p != tt.path {
+			t.Errorf("parseFileID(%q) = %q, %q, %q, %v", id, source, dir, p, ok)
+		}
+	}
+
+	// 日志目录清理后写入ID
+	if _, dir, _, _ := parseFileID(fileID("default", "/var/log/", "app.log")); dir != "/var/log" {
+		t.Errorf("dir = %q, want /var/log", dir)
+	}
+	// 不含日志目录的旧ID
+	legacy := fileIDPrefix + base64.RawURLEncoding.EncodeToString([]byte("default\x00app.log"))
+	if source, dir, p, ok := parseFileID(legacy); !ok || source != "default" || dir != "" || p != "app.log" {
+		t.Errorf("parseFileID(legacy) = %q, %q, %q, %v", source, dir, p, ok)
+	}
+
+	invalid := []string{
+		"app.log",
+		"/var/log/app.log",
+		fileIDPrefix + "!!!",
+		fileID("", "/var/log", "app.log"),
+		fileID("default", "/var/log", ""),
+		fileIDPrefix + "ZGVmYXVsdA", // 没有分隔符
+	}
+	for _, id := range invalid {
+		if source, dir, p, ok := parseFileID(id); ok {
+			t.Errorf("parseFileID(%q) = %q, %q, %q, true", id, source, dir, p)
+		}
+	}
+}
+
+func TestResolveSourcePath(t *testing.T) {
+	root := t.TempDir()
+	logs := filepath.Join(root, "logs")
+	os.MkdirAll(filepath.Join(logs, "sub"), 0755)
+	os.WriteFile(filepath.Join(logs, "sub", "app.log"), []byte("a\n"), 0644)
+	pinned := filepath.Join(root, "pinned.log")
+	os.WriteFile(pinned, []byte("p\n"), 0644)
+	os.WriteFile(filepath.Join(root, "secret.log"), []byte("s\n"), 0644)
+
+	// 同一日志源的两个目录中有相同相对路径的文件
+	web1, web2 := filepath.Join(root, "web1"), filepath.Join(root, "web2")
+	for _, dir := range []string{web1, web2} {
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "access.log"), []byte(dir+"\n"), 0644)
+	}
+
+	cfg := &config.Config{Logs: config.LogsConfig{
+		Pattern: `\.log$`,
+		Sources: []config.SourceConfig{{
+			Name:        "app",
+			Directories: []config.DirectoryConfig{{Path: logs}},
+			Files:       []string{pinned},
+		}, {
+			Name:        "web",
+			Directories: []config.DirectoryConfig{{Path: web1}, {Path: web2 + "/"}},
+		}},
+	}}
+	tests := []struct {
+		source, dir, path string
+		want              string
+	}{
+		{"app", logs, "sub/app.log", filepath.Join(logs, "sub", "app.log")},
+		{"app", "", "sub/app.log", filepath.Join(logs, "sub", "app.log")},
+		{"app", "", fixedScheme + pinned, pinned},
+		{"app", logs, "../secret.log", ""},
+		{"app", "", fixedScheme + filepath.Join(root, "secret.log"), ""},
+		{"app", logs, "missing.log", ""},
+		{"other", logs, "sub/app.log", ""},
+		{"web", web1, "access.log", filepath.Join(web1, "access.log")},
+		{"web", web2, "access.log", filepath.Join(web2, "access.log")},
+		{"web", logs, "access.log", ""},
+		// 旧ID没有日志目录，多个目录中都存在时无法确定
+		{"web", "", "access.log", ""},
+	}
+	for _, tt := range tests {
+		dir := tt.dir
+		if dir != "" {
+			dir = filepath.ToSlash(filepath.Clean(dir))
+		}
+		got, err := resolveSourcePath(cfg, tt.source, dir, tt.path)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("resolveSourcePath(%q, %q, %q) = %q, %v", tt.source, tt.dir, tt.path, got, err)
+		}
+	}
+
+	// 文件列表中两个目录的文件ID不同，且各自解析回原来的文件
+	ids := map[string]bool{}
+	for _, dir := range []string{web1, web2} {
+		file := catalogLogFile(catalog.File{Path: filepath.Join(dir, "access.log"), Root: dir, Source: "web"})
+		ids[file.ID] = true
+		source, fileDir, p, _ := parseFileID(file.ID)
+		if got, err := resolveSourcePath(cfg, source, fileDir, p); err != nil || got != file.FullPath {
+			t.Errorf("resolve %s = %q, %v", file.FullPath, got, err)
+		}
+	}
+	if len(ids) != 2 {
+		t.Errorf("file IDs collide: %v", ids)
+	}
+}
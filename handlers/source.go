@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// SourceInfo 文件列表接口返回的日志源信息
+type SourceInfo struct {
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Parser      string            `json:"parser,omitempty"`
+	Encoding    string            `json:"encoding,omitempty"`
+	Retention   string            `json:"retention,omitempty"`
+	Freshness   string            `json:"freshness,omitempty"`  // 新鲜度要求
+	LastWrite   string            `json:"last_write,omitempty"` // 最近一次写入时间，只在配置了新鲜度要求时返回
+	Stale       bool              `json:"stale"`                // 是否已超过新鲜度要求没有写入
+}
+
+// 文件列表的分组方式
+const (
+	groupByDirectory   = "directory" // 日志源和子目录（默认）
+	groupBySource      = "source"    // 日志源
+	groupByLabelPrefix = "label:"    // 指定标签的值，如 label:env
+)
+
+// findSource 按名称查找日志源
+func findSource(cfg *config.Config, name string) (config.SourceConfig, bool) {
+	if cfg == nil {
+		return config.SourceConfig{}, false
+	}
+	for _, source := range cfg.LogSources() {
+		if source.Name == name {
+			return source, true
+		}
+	}
+	return config.SourceConfig{}, false
+}
+
+// sourceOf 返回文件所属的日志源：配置为该日志源的确定文件，或位于该日志源的目录内
+// 轮转文件组的缓存按其活动文件查找
+func sourceOf(cfg *config.Config, filePath string) (config.SourceConfig, bool) {
+	if cfg == nil {
+		return config.SourceConfig{}, false
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return config.SourceConfig{}, false
+	}
+	absPath = familyOrigin(absPath)
+	sources := cfg.LogSources()
+	for _, source := range sources {
+		for _, file := range source.Files {
+			if samePath(file, absPath) {
+				return source, true
+			}
+		}
+	}
+	for _, source := range sources {
+		for _, dir := range source.Directories {
+			if pathWithin(dir.Path, absPath) {
+				return source, true
+			}
+		}
+	}
+	return config.SourceConfig{}, false
+}
+
+// sourceDecoder 返回文件所属日志源配置的编码解码器，UTF-8 或未配置时返回 nil
+func sourceDecoder(filePath string) *encoding.Decoder {
+	source, ok := sourceOf(config.GetConfig(), filePath)
+	if !ok || source.Encoding == "" {
+		return nil
+	}
+	enc, err := htmlindex.Get(source.Encoding)
+	if err != nil {
+		fmt.Printf("日志源 %s 的编码不支持 %s: %v\n", source.Name, source.Encoding, err)
+		return nil
+	}
+	if enc == unicode.UTF8 {
+		return nil
+	}
+	return enc.NewDecoder()
+}
+
+// decodeLine 使用解码器转换一行内容，解码失败时返回原内容
+func decodeLine(decoder *encoding.Decoder, line string) string {
+	if decoder == nil {
+		return line
+	}
+	decoded, err := decoder.String(line)
+	if err != nil {
+		return line
+	}
+	return decoded
+}
+
+// sourceInfos 返回所有日志源的信息，配置了新鲜度要求的日志源同时返回是否已停止写入
+func sourceInfos(cfg *config.Config) []SourceInfo {
+	freshness := make(map[string]SourceFreshness)
+	for _, item := range sourceFreshness(cfg, time.Now()) {
+		freshness[item.Name] = item
+	}
+
+	sources := cfg.LogSources()
+	infos := make([]SourceInfo, 0, len(sources)+1)
+	for _, source := range sources {
+		item := freshness[source.Name]
+		infos = append(infos, SourceInfo{
+			Name:        source.Name,
+			DisplayName: source.Title(),
+			Description: source.Description,
+			Labels:      source.Labels,
+			Parser:      source.Parser,
+			Encoding:    source.Encoding,
+			Retention:   source.Retention,
+			Freshness:   source.Freshness,
+			LastWrite:   item.LastWrite,
+			Stale:       item.Stale,
+		})
+	}
+	if len(cfg.Logs.Journal.Directories) > 0 {
+		infos = append(infos, SourceInfo{
+			Name:        config.JournalSourceName,
+			DisplayName: journalSourceTitle,
+			Description: "systemd journal 中的各单元",
+		})
+	}
+	return infos
+}
+
+// parseLabelFilters 解析标签筛选条件，每项为 key:value 或 key=value，只有 key 时表示有该标签即可
+func parseLabelFilters(values []string) (map[string]string, error) {
+	filters := make(map[string]string)
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			key, val, found := strings.Cut(item, ":")
+			if !found {
+				key, val, _ = strings.Cut(item, "=")
+			}
+			key = strings.ToLower(strings.TrimSpace(key))
+			if key == "" {
+				return nil, fmt.Errorf("无效的标签筛选条件: %s", item)
+			}
+			filters[key] = strings.TrimSpace(val)
+		}
+	}
+	return filters, nil
+}
+
+// matchLabels 判断文件标签是否满足所有筛选条件，条件值为空时只要求存在该标签
+func matchLabels(labels, filters map[string]string) bool {
+	for key, want := range filters {
+		got, ok := labels[key]
+		if !ok || (want != "" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterLogFiles 按日志源和标签筛选文件
+func filterLogFiles(files []LogFile, sources []string, labels map[string]string) []LogFile {
+	if len(sources) == 0 && len(labels) == 0 {
+		return files
+	}
+	allowed := make(map[string]bool)
+	for _, value := range sources {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				allowed[name] = true
+			}
+		}
+	}
+
+	filtered := make([]LogFile, 0, len(files))
+	for _, file := range files {
+		if len(allowed) > 0 && !allowed[file.Source] {
+			continue
+		}
+		if !matchLabels(file.Labels, labels) {
+			continue
+		}
+		filtered = append(filtered, file)
+	}
+	return filtered
+}
+
+// groupLogFiles 设置文件的分组并排序：按分组排序，分组内确定的文件在最前面，然后按文件名排序
+func groupLogFiles(files []LogFile, groupBy string) error {
+	var label string
+	switch {
+	case groupBy == "" || groupBy == groupByDirectory || groupBy == groupBySource:
+	case strings.HasPrefix(groupBy, groupByLabelPrefix):
+		label = strings.ToLower(strings.TrimPrefix(groupBy, groupByLabelPrefix))
+		if label == "" {
+			return fmt.Errorf("分组方式缺少标签名: %s", groupBy)
+		}
+	default:
+		return fmt.Errorf("不支持的分组方式: %s，可选 directory、source 或 label:<标签名>", groupBy)
+	}
+
+	for i := range files {
+		file := &files[i]
+		switch {
+		case label != "":
+			if value, ok := file.Labels[label]; ok {
+				file.Group = label + "=" + value
+			} else {
+				file.Group = "未设置 " + label
+			}
+		case groupBy == groupBySource || file.Directory == "":
+			file.Group = file.SourceName
+		default:
+			file.Group = file.SourceName + " / " + file.Directory
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Group != files[j].Group {
+			return files[i].Group < files[j].Group
+		}
+		if files[i].Pinned != files[j].Pinned {
+			return files[i].Pinned
+		}
+		return files[i].Name < files[j].Name
+	})
+	return nil
+}
+
+// labelValues 汇总文件中出现的标签及其取值，用于前端筛选
+func labelValues(files []LogFile) map[string][]string {
+	seen := make(map[string]map[string]bool)
+	for _, file := range files {
+		for key, value := range file.Labels {
+			if seen[key] == nil {
+				seen[key] = make(map[string]bool)
+			}
+			seen[key][value] = true
+		}
+	}
+	values := make(map[string][]string, len(seen))
+	for key, set := range seen {
+		for value := range set {
+			values[key] = append(values[key], value)
+		}
+		sort.Strings(values[key])
+	}
+	return values
+}
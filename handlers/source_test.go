@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupLogFiles(t *testing.T) {
+	files := func() []LogFile {
+		return []LogFile{
+			{Name: "b.log", SourceName: "order", Directory: "api", Labels: map[string]string{"env": "prod"}},
+			{Name: "pinned.log", SourceName: "order", Pinned: true, Labels: map[string]string{"env": "prod"}},
+			{Name: "a.log", SourceName: "order", Directory: "api", Labels: map[string]string{"env": "prod"}},
+			{Name: "app.log", SourceName: "default", Pinned: true},
+			{Name: "z.log", SourceName: "default"},
+		}
+	}
+	tests := []struct {
+		groupBy string
+		want    string // 分组:文件名，按顺序
+		wantErr bool
+	}{
+		{"", "default:app.log default:z.log order:pinned.log order / api:a.log order / api:b.log", false},
+		{"source", "default:app.log default:z.log order:pinned.log order:a.log order:b.log", false},
+		{"label:env", "env=prod:pinned.log env=prod:a.log env=prod:b.log 未设置 env:app.log 未设置 env:z.log", false},
+		{"label:", "", true},
+		{"owner", "", true},
+	}
+	for _, tt := range tests {
+		list := files()
+		err := groupLogFiles(list, tt.groupBy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("groupLogFiles(%q) error = %v", tt.groupBy, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		var got []string
+		for _, f := range list {
+			got = append(got, f.Group+":"+f.Name)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("groupLogFiles(%q) = %v", tt.groupBy, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"github.com/anjude/log-tools/parser"
+)
+
+// LogEntry 结构化的日志行
+type LogEntry struct {
+	LineNumber int               `json:"line_number"`         // 行号
+	Offset     int64             `json:"offset"`              // 行起始的字节偏移
+	Timestamp  string            `json:"timestamp,omitempty"` // 解析出的时间
+	Level      string            `json:"level,omitempty"`     // 日志级别
+	Message    string            `json:"message"`             // 日志消息
+	Fields     map[string]string `json:"fields,omitempty"`    // 其他字段
+	Raw        string            `json:"raw"`                 // 原始行内容
+}
+
+// rawLine 带位置信息的原始行
+type rawLine struct {
+	LineNumber int
+	Offset     int64
+	Text       string
+}
+
+// readLastNRawLines 读取文件最后N行及其行号和字节偏移，stream 为容器日志的输出流，为空表示全部
+// keep 可选，只保留返回true的行；所有行都会按顺序传给keep（已去除ANSI转义序列），以便其跟踪上下文状态
+// 返回的行保留原始内容，由调用方决定如何处理ANSI转义序列
+func readLastNRawLines(filePath, stream string, n int, reverse bool, keep func(line string) bool) ([]rawLine, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	// 环形缓冲区保存最新的N行
+	ring := make([]rawLine, 0, n)
+	next := 0
+	err := scanStreamLines(filePath, stream, func(lineNumber int, offset int64, line string) bool {
+		if keep != nil && !keep(stripANSI(line)) {
+			return true
+		}
+		item := rawLine{LineNumber: lineNumber, Offset: offset, Text: line}
+		if len(ring) < n {
+			ring = append(ring, item)
+		} else {
+			ring[next] = item
+			next = (next + 1) % n
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]rawLine, 0, len(ring))
+	lines = append(lines, ring[next:]...)
+	lines = append(lines, ring[:next]...)
+
+	if reverse {
+		for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+			lines[i], lines[j] = lines[j], lines[i]
+		}
+	}
+	return lines, nil
+}
+
+// buildLogEntries 使用解析器将原始行转换为结构化日志行
+func buildLogEntries(p parser.Parser, lines []rawLine) []LogEntry {
+	entries := make([]LogEntry, 0, len(lines))
+	for _, line := range lines {
+		record := parseFileLine(p, line.LineNumber, line.Text)
+		entries = append(entries, LogEntry{
+			LineNumber: line.LineNumber,
+			Offset:     line.Offset,
+			Timestamp:  record.Timestamp,
+			Level:      record.Level,
+			Message:    record.Message,
+			Fields:     record.Fields,
+			Raw:        line.Text,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/parser"
+)
+
+// timeRange 时间范围，零值表示不限制
+type timeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// parseTimeRange 解析请求中的起止时间
+func parseTimeRange(start, end string) (timeRange, error) {
+	var r timeRange
+	var err error
+	if start != "" {
+		if r.Start, err = parseQueryTime(start); err != nil {
+			return r, fmt.Errorf("开始时间格式错误: %s", start)
+		}
+	}
+	if end != "" {
+		if r.End, err = parseQueryTime(end); err != nil {
+			return r, fmt.Errorf("结束时间格式错误: %s", end)
+		}
+	}
+	if !r.Start.IsZero() && !r.End.IsZero() && r.End.Before(r.Start) {
+		return r, fmt.Errorf("结束时间不能早于开始时间")
+	}
+	return r, nil
+}
+
+// parseQueryTime 解析请求中的时间参数，支持RFC3339和 "2006-01-02 15:04:05"
+func parseQueryTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s", value)
+}
+
+// isZero 是否未设置任何时间限制
+func (r timeRange) isZero() bool {
+	return r.Start.IsZero() && r.End.IsZero()
+}
+
+// contains 判断时间是否在范围内（左闭右开）
+func (r timeRange) contains(t time.Time) bool {
+	if !r.Start.IsZero() && t.Before(r.Start) {
+		return false
+	}
+	if !r.End.IsZero() && !t.Before(r.End) {
+		return false
+	}
+	return true
+}
+
+// lineTimeTracker 跟踪行时间，没有时间戳的行（如堆栈）沿用上一行的时间
+// 设置了解析器时使用解析出的时间，否则从行首识别时间戳
+type lineTimeTracker struct {
+	parser parser.Parser
+	last   time.Time
+	known  bool
+}
+
+// observe 处理一行，返回该行对应的时间
+func (t *lineTimeTracker) observe(line string) (time.Time, bool) {
+	if t.parser != nil {
+		if record, ok := t.parser.Parse(line); ok && !record.Time.IsZero() {
+			t.last = record.Time
+			t.known = true
+			return t.last, t.known
+		}
+	}
+	if ts, ok := parser.DetectTimestamp(line); ok {
+		t.last = ts
+		t.known = true
+	}
+	return t.last, t.known
+}
+
+// inRange 处理一行并判断其是否在时间范围内
+func (t *lineTimeTracker) inRange(line string, r timeRange) bool {
+	ts, ok := t.observe(line)
+	if r.isZero() {
+		return true
+	}
+	return ok && r.contains(ts)
+}
+
+// inRangeRecord 使用已解析的记录判断时间范围，记录没有时间时沿用上一行的时间
+func (t *lineTimeTracker) inRangeRecord(record *parser.Record, r timeRange) bool {
+	if !record.Time.IsZero() {
+		t.last = record.Time
+		t.known = true
+	}
+	if r.isZero() {
+		return true
+	}
+	return t.known && r.contains(t.last)
+}
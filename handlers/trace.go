@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultTraceFields 未配置 trace_fields 时使用的常见请求/链路ID字段
+var defaultTraceFields = []string{
+	"trace_id", "traceid", "trace.id", "request_id", "requestid", "req_id",
+	"x-request-id", "x_request_id", "http_x_request_id", "correlation_id",
+}
+
+// serviceFields 用于识别服务名称的字段，均不存在时使用文件名
+var serviceFields = []string{"service", "service.name", "app", "application"}
+
+// TraceRequest 链路追踪请求结构
+type TraceRequest struct {
+	TraceID string   `json:"trace_id" binding:"required"` // 请求/链路ID
+	Files   []string `json:"files"`                       // 可选，默认搜索所有日志文件
+	Start   string   `json:"start"`                       // 可选，开始时间
+	End     string   `json:"end"`                         // 可选，结束时间
+	Limit   int      `json:"limit"`                       // 可选，最多返回的行数
+}
+
+// TraceEntry 时间线中的一行日志
+type TraceEntry struct {
+	Service      string `json:"service"`                 // 处理该步骤的服务
+	File         string `json:"file"`                    // 文件名
+	FilePath     string `json:"file_path"`               // 完整文件路径
+	LineNumber   int    `json:"line_number"`             // 行号
+	Timestamp    string `json:"timestamp,omitempty"`     // 时间
+	Level        string `json:"level,omitempty"`         // 日志级别
+	Content      string `json:"content"`                 // 原始内容
+	MatchedField string `json:"matched_field,omitempty"` // 匹配到ID的字段，为空表示在文本中匹配
+	GapMs        int64  `json:"gap_ms"`                  // 距上一行的间隔（毫秒）
+
+	time time.Time
+}
+
+// TraceStep 时间线中的一个步骤，同一服务连续的多行合并为一个步骤
+type TraceStep struct {
+	Service    string `json:"service"`     // 服务名称
+	File       string `json:"file"`        // 文件名
+	Start      string `json:"start"`       // 步骤开始时间
+	End        string `json:"end"`         // 步骤结束时间
+	DurationMs int64  `json:"duration_ms"` // 步骤持续时间（毫秒）
+	GapMs      int64  `json:"gap_ms"`      // 距上一步骤结束的间隔（毫秒）
+	Lines      int    `json:"lines"`       // 行数
+	Errors     int    `json:"errors"`      // ERROR 及以上级别的行数
+
+	filePath   string
+	start, end time.Time
+}
+
+// traceFieldsFor 返回日志格式对应的链路ID字段
+func traceFieldsFor(cfg *config.Config, format string) []string {
+	if fields, ok := cfg.Logs.TraceFields[format]; ok && len(fields) > 0 {
+		return fields
+	}
+	if fields, ok := cfg.Logs.TraceFields["default"]; ok && len(fields) > 0 {
+		return fields
+	}
+	return defaultTraceFields
+}
+
+// containsToken 判断 id 是否作为完整的词出现在行中，避免匹配到更长ID的一部分
+func containsToken(line, id string) bool {
+	lineLower := strings.ToLower(line)
+	idLower := strings.ToLower(id)
+	for from := 0; ; {
+		i := strings.Index(lineLower[from:], idLower)
+		if i < 0 {
+			return false
+		}
+		start := from + i
+		end := start + len(idLower)
+		if (start == 0 || !isIDChar(lineLower[start-1])) && (end == len(lineLower) || !isIDChar(lineLower[end])) {
+			return true
+		}
+		from = start + 1
+	}
+}
+
+// isIDChar 判断字符是否可能是ID的一部分
+func isIDChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_'
+}
+
+// serviceName 返回记录所属的服务名称
+func serviceName(record *parser.Record, filePath string) string {
+	if record != nil {
+		for _, field := range serviceFields {
+			if value, ok := record.Field(field); ok && value != "" {
+				return value
+			}
+		}
+	}
+	name := filepath.Base(filePath)
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// TraceLogs 按请求/链路ID关联所有日志文件中的行，按时间排序生成时间线
+// 基于搜索功能查找包含ID的行，再按格式配置的ID字段或完整词匹配进行确认
+func TraceLogs(c *gin.Context) {
+	var req TraceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+	traceID := strings.TrimSpace(req.TraceID)
+	if traceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "链路ID不能为空",
+		})
+		return
+	}
+
+	window, err := parseTimeRange(req.Start, req.End)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	cfg := config.GetConfig()
+	var files []string
+	if len(req.Files) > 0 {
+		files = resolveRequestFiles(req.Files)
+	} else {
+		logFiles, err := collectLogFiles(cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("获取日志文件失败: %v", err),
+			})
+			return
+		}
+		seen := make(map[string]bool)
+		for _, logFile := range logFiles {
+			// journal 虚拟日志源需要在 files 中显式指定，避免逐个转换所有单元
+			if isJournalSource(logFile.Path) || logFile.Binary {
+				continue
+			}
+			absPath, err := filepath.Abs(logFile.FullPath)
+			// 轮转文件组读取合并后的缓存
+			if isFamilySource(logFile.Path) {
+				absPath, err = resolveFamilySource(logFile.Path)
+			}
+			if err != nil || seen[absPath] {
+				continue
+			}
+			seen[absPath] = true
+			files = append(files, absPath)
+		}
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "没有找到有效的文件进行搜索",
+		})
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > cfg.Logs.MaxSearchResults {
+		limit = cfg.Logs.MaxSearchResults
+	}
+
+	fmt.Printf("链路追踪请求: ID=%s, 文件数=%d\n", traceID, len(files))
+
+	// 需要脱敏的用户在脱敏后的行上查找和确认链路ID
+	redaction := newRedaction(c)
+	searchQuery := &SearchQuery{
+		Keywords:  []SearchKeyword{{Value: traceID, Type: "literal"}},
+		Logic:     "and",
+		Parse:     true,
+		Redaction: redaction,
+	}
+	// 先找出所有包含ID的行，按时间范围过滤并排序后再截取，避免时间范围外的行占用数量限制
+	results, err := searchInMultipleFiles(files, searchQuery, cfg.Logs.MaxSearchResults, false, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("搜索失败: %v", err),
+		})
+		return
+	}
+
+	var entries []TraceEntry
+	for _, result := range results {
+		content := redaction.Silent(result.Content)
+		record := result.Record
+		if record == nil || content != result.Content {
+			record = parseLine(parserForFile(result.FilePath), content)
+		}
+
+		matchedField := ""
+		for _, field := range traceFieldsFor(cfg, parserForFile(result.FilePath).Name()) {
+			if value, ok := record.Field(field); ok && strings.EqualFold(value, traceID) {
+				matchedField = field
+				break
+			}
+		}
+		if matchedField == "" && !containsToken(content, traceID) {
+			continue
+		}
+
+		ts := record.Time
+		if ts.IsZero() {
+			ts, _ = parser.DetectTimestamp(result.Content)
+		}
+		if !window.isZero() && (ts.IsZero() || !window.contains(ts)) {
+			continue
+		}
+
+		level := record.Level
+		if level == "" {
+			level = parser.DetectLevel(result.Content)
+		}
+
+		entry := TraceEntry{
+			Service:      serviceName(record, result.FilePath),
+			File:         result.File,
+			FilePath:     result.FilePath,
+			LineNumber:   result.LineNumber,
+			Level:        level,
+			Content:      result.Content,
+			MatchedField: matchedField,
+			time:         ts,
+		}
+		if !ts.IsZero() {
+			entry.Timestamp = ts.Format("2006-01-02 15:04:05.000")
+		}
+		entries = append(entries, entry)
+	}
+
+	// 有时间的行按时间排序，时间相同时保持文件内的顺序；没有时间的行排在最后
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.time.IsZero() != b.time.IsZero() {
+			return !a.time.IsZero()
+		}
+		if !a.time.Equal(b.time) {
+			return a.time.Before(b.time)
+		}
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		return a.LineNumber < b.LineNumber
+	})
+	truncated := len(entries) > limit
+	if truncated {
+		entries = entries[:limit]
+	}
+
+	steps := buildTraceSteps(entries)
+
+	var services []string
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		if !seen[step.Service] {
+			seen[step.Service] = true
+			services = append(services, step.Service)
+		}
+	}
+
+	for i := range entries {
+		entries[i].Content = redaction.String(entries[i].Content)
+	}
+
+	response := gin.H{
+		"trace_id":  traceID,
+		"entries":   entries,
+		"steps":     steps,
+		"services":  services,
+		"count":     len(entries),
+		"files":     len(files),
+		"truncated": truncated,
+	}
+	if len(steps) > 0 {
+		first, last := steps[0].start, steps[0].end
+		for _, step := range steps {
+			if !step.end.IsZero() && step.end.After(last) {
+				last = step.end
+			}
+		}
+		if !first.IsZero() {
+			response["start"] = first.Format("2006-01-02 15:04:05.000")
+			response["end"] = last.Format("2006-01-02 15:04:05.000")
+			response["duration_ms"] = last.Sub(first).Milliseconds()
+		}
+	}
+
+	finishRedaction(c, redaction, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// buildTraceSteps 计算行间隔，并将同一文件连续的行合并为步骤
+func buildTraceSteps(entries []TraceEntry) []TraceStep {
+	var steps []TraceStep
+	var prev time.Time
+	for i := range entries {
+		entry := &entries[i]
+		if !entry.time.IsZero() && !prev.IsZero() {
+			entry.GapMs = entry.time.Sub(prev).Milliseconds()
+		}
+		if !entry.time.IsZero() {
+			prev = entry.time
+		}
+
+		isError := parser.LevelRank(entry.Level) >= parser.LevelRank("ERROR")
+		if n := len(steps); n > 0 && steps[n-1].filePath == entry.FilePath && steps[n-1].Service == entry.Service {
+			step := &steps[n-1]
+			step.Lines++
+			if isError {
+				step.Errors++
+			}
+			if !entry.time.IsZero() {
+				if step.start.IsZero() {
+					step.start = entry.time
+				}
+				step.end = entry.time
+			}
+			continue
+		}
+
+		step := TraceStep{
+			Service:  entry.Service,
+			File:     entry.File,
+			Lines:    1,
+			filePath: entry.FilePath,
+			start:    entry.time,
+			end:      entry.time,
+		}
+		if isError {
+			step.Errors = 1
+		}
+		if n := len(steps); n > 0 && !steps[n-1].end.IsZero() && !entry.time.IsZero() {
+			step.GapMs = entry.time.Sub(steps[n-1].end).Milliseconds()
+		}
+		steps = append(steps, step)
+	}
+
+	for i := range steps {
+		step := &steps[i]
+		if step.start.IsZero() {
+			continue
+		}
+		step.Start = step.start.Format("2006-01-02 15:04:05.000")
+		step.End = step.end.Format("2006-01-02 15:04:05.000")
+		step.DurationMs = step.end.Sub(step.start).Milliseconds()
+	}
+	return steps
+}
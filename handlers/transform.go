@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+)
+
+// 内置的行转换
+const (
+	transformPrettyJSON = "pretty_json" // 格式化JSON行，在其他转换之后执行
+	transformTimezone   = "timezone"    // 时间戳转换到指定时区，如 timezone:Asia/Shanghai
+	transformCollapse   = "collapse"    // 连续相同的行折叠为一行并标注 ×N
+	transformDrop       = "drop"        // 删除字段，如 drop:headers,request.body
+	transformProject    = "project"     // 只保留指定字段，输出为 key=value 的紧凑格式
+)
+
+// transformTimeLayout 时区转换后的时间格式，保留时区偏移
+const transformTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// jsonTimeKeys JSON行中会被时区转换的时间字段
+var jsonTimeKeys = map[string]bool{
+	"time": true, "ts": true, "timestamp": true, "@timestamp": true, "datetime": true, "date": true,
+}
+
+// lineTransform 单行转换函数，p 为文件对应的格式解析器
+type lineTransform func(line string, p parser.Parser) string
+
+// transformPipeline 行转换管道，按顺序执行逐行转换
+// 格式化JSON和折叠连续相同的行属于展示步骤，总是在其他转换之后执行
+type transformPipeline struct {
+	steps    []lineTransform
+	pretty   bool
+	collapse bool
+}
+
+// newTransformPipeline 根据转换列表和预设创建转换管道，两者都为空时返回 nil
+// 转换格式为 "名称" 或 "名称:参数"；预设在配置 logs.transform_presets 中定义，
+// 可以通过 preset 参数、"preset:名称" 或直接使用预设名称引用
+func newTransformPipeline(specs []string, preset string) (*transformPipeline, error) {
+	var expanded []string
+	if preset != "" {
+		steps, err := expandPreset(preset, 0)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, steps...)
+	}
+	for _, spec := range specs {
+		steps, err := expandSpec(spec, 0)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, steps...)
+	}
+	if len(expanded) == 0 {
+		return nil, nil
+	}
+
+	pipeline := &transformPipeline{}
+	for _, spec := range expanded {
+		name, arg := splitTransformSpec(spec)
+		switch name {
+		case transformPrettyJSON:
+			pipeline.pretty = true
+		case transformCollapse:
+			pipeline.collapse = true
+		case transformTimezone:
+			if arg == "" {
+				return nil, fmt.Errorf("timezone 需要指定时区，如 timezone:Asia/Shanghai")
+			}
+			loc, err := time.LoadLocation(arg)
+			if err != nil {
+				return nil, fmt.Errorf("未知的时区 %s: %v", arg, err)
+			}
+			pipeline.steps = append(pipeline.steps, timezoneTransform(loc))
+		case transformDrop:
+			fields := splitTransformFields(arg)
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("drop 需要指定字段，如 drop:headers,request.body")
+			}
+			pipeline.steps = append(pipeline.steps, dropTransform(fields))
+		case transformProject:
+			fields := splitTransformFields(arg)
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("project 需要指定字段，如 project:ts,level,message")
+			}
+			pipeline.steps = append(pipeline.steps, projectTransform(fields))
+		default:
+			return nil, fmt.Errorf("未知的转换: %s", name)
+		}
+	}
+	return pipeline, nil
+}
+
+// expandSpec 展开单个转换，预设引用会被替换为预设中的转换列表
+func expandSpec(spec string, depth int) ([]string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	name, arg := splitTransformSpec(spec)
+	if name == "preset" {
+		return expandPreset(arg, depth)
+	}
+	if arg == "" && !isBuiltinTransform(name) {
+		if _, ok := transformPresets()[name]; ok {
+			return expandPreset(name, depth)
+		}
+	}
+	return []string{spec}, nil
+}
+
+// expandPreset 展开预设，预设中可以引用其他预设
+func expandPreset(name string, depth int) ([]string, error) {
+	if depth > 5 {
+		return nil, fmt.Errorf("转换预设 %s 嵌套过深", name)
+	}
+	specs, ok := transformPresets()[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("未知的转换预设: %s", name)
+	}
+	var expanded []string
+	for _, spec := range specs {
+		steps, err := expandSpec(spec, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, steps...)
+	}
+	return expanded, nil
+}
+
+// transformPresets 返回配置中的转换预设
+func transformPresets() map[string][]string {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return nil
+	}
+	return cfg.Logs.TransformPresets
+}
+
+func isBuiltinTransform(name string) bool {
+	switch name {
+	case transformPrettyJSON, transformTimezone, transformCollapse, transformDrop, transformProject:
+		return true
+	}
+	return false
+}
+
+// splitTransformSpec 将 "名称:参数" 拆分为名称和参数
+func splitTransformSpec(spec string) (string, string) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	return strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(arg)
+}
+
+// splitTransformFields 拆分逗号分隔的字段列表
+func splitTransformFields(arg string) []string {
+	var fields []string
+	for _, field := range strings.Split(arg, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// transform 对一行执行所有逐行转换
+func (t *transformPipeline) transform(line string, p parser.Parser) string {
+	for _, step := range t.steps {
+		line = step(line, p)
+	}
+	if t.pretty {
+		line = prettyJSONLine(line)
+	}
+	return line
+}
+
+// apply 转换多行内容，返回转换后的行以及每行对应的原始行下标
+func (t *transformPipeline) apply(lines []string, p parser.Parser) ([]string, []int) {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = t.transform(line, p)
+	}
+	if !t.collapse {
+		keep := make([]int, len(out))
+		for i := range keep {
+			keep[i] = i
+		}
+		return out, keep
+	}
+
+	keep, counts := collapseRuns(len(out), func(i, j int) bool { return out[i] == out[j] })
+	collapsed := make([]string, len(keep))
+	for i, index := range keep {
+		collapsed[i] = withRepeat(out[index], counts[i])
+	}
+	return collapsed, keep
+}
+
+// collapseRuns 将连续相同的元素合并，返回每组第一个元素的下标和组内元素数量
+func collapseRuns(n int, same func(i, j int) bool) ([]int, []int) {
+	var keep, counts []int
+	for i := 0; i < n; i++ {
+		if last := len(keep) - 1; last >= 0 && same(keep[last], i) {
+			counts[last]++
+			continue
+		}
+		keep = append(keep, i)
+		counts = append(counts, 1)
+	}
+	return keep, counts
+}
+
+// keepIndexes 按下标保留切片中的元素
+func keepIndexes[T any](items []T, indexes []int) []T {
+	kept := make([]T, len(indexes))
+	for i, index := range indexes {
+		kept[i] = items[index]
+	}
+	return kept
+}
+
+// transformSearchResults 转换搜索结果的内容，同一文件中连续相同的结果会被折叠
+func transformSearchResults(pipeline *transformPipeline, results []SearchResult) []SearchResult {
+	parsers := make(map[string]parser.Parser)
+	for i := range results {
+		p, ok := parsers[results[i].FilePath]
+		if !ok {
+			p = parserForFile(results[i].FilePath)
+			parsers[results[i].FilePath] = p
+		}
+		results[i].Content = pipeline.transform(results[i].Content, p)
+	}
+	if !pipeline.collapse {
+		return results
+	}
+
+	keep, counts := collapseRuns(len(results), func(i, j int) bool {
+		return results[i].FilePath == results[j].FilePath && results[i].Content == results[j].Content
+	})
+	collapsed := keepIndexes(results, keep)
+	for i := range collapsed {
+		if counts[i] > 1 {
+			collapsed[i].Repeat = counts[i]
+			collapsed[i].Content = withRepeat(collapsed[i].Content, counts[i])
+		}
+	}
+	return collapsed
+}
+
+// withRepeat 为折叠的行添加 ×N 标注
+func withRepeat(line string, count int) string {
+	if count <= 1 {
+		return line
+	}
+	return fmt.Sprintf("%s ×%d", line, count)
+}
+
+// prettyJSONLine 格式化JSON对象或数组，其他内容保持不变
+func prettyJSONLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return line
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil {
+		return line
+	}
+	return buf.String()
+}
+
+// timezoneTransform 将时间戳转换到指定时区
+// JSON行转换常见的时间字段，其他行转换行首的时间戳
+func timezoneTransform(loc *time.Location) lineTransform {
+	return func(line string, _ parser.Parser) string {
+		if obj, ok := decodeOrderedObject(line); ok {
+			changed := false
+			for i, member := range obj {
+				if !jsonTimeKeys[strings.ToLower(member.key)] {
+					continue
+				}
+				var value string
+				if err := json.Unmarshal(member.value, &value); err != nil {
+					continue
+				}
+				if t, ok := parser.ParseTime(value); ok {
+					obj[i].value, _ = json.Marshal(t.In(loc).Format(transformTimeLayout))
+					changed = true
+				}
+			}
+			if changed {
+				return obj.encode()
+			}
+			return line
+		}
+
+		start, end, t, ok := parser.FindTimestamp(line)
+		if !ok {
+			return line
+		}
+		return line[:start] + t.In(loc).Format(transformTimeLayout) + line[end:]
+	}
+}
+
+// dropTransform 删除指定字段，支持JSON行（可用点号指定嵌套字段）和 key=value 形式的行
+// 没有删除任何字段的行保持原样，不重新编码也不调整空白
+func dropTransform(fields []string) lineTransform {
+	patterns := make([]*regexp.Regexp, len(fields))
+	for i, field := range fields {
+		patterns[i] = regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(field) + `=(?:"(?:[^"\\]|\\.)*"|\S*)`)
+	}
+	return func(line string, _ parser.Parser) string {
+		if obj, ok := decodeOrderedObject(line); ok {
+			dropped := false
+			for _, field := range fields {
+				var changed bool
+				obj, changed = obj.drop(strings.Split(field, "."))
+				dropped = dropped || changed
+			}
+			if !dropped {
+				return line
+			}
+			return obj.encode()
+		}
+		for _, re := range patterns {
+			line = dropKeyValue(re, line)
+		}
+		return line
+	}
+}
+
+// dropKeyValue 删除 key=value 形式的字段及其前面的一个空白，位于行首时删除其后的空白，其余内容保持不变
+func dropKeyValue(re *regexp.Regexp, line string) string {
+	matches := re.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return line
+	}
+	var buf strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if start < last {
+			continue
+		}
+		if m[3] == m[2] {
+			end += len(line[end:]) - len(strings.TrimLeft(line[end:], " \t"))
+		}
+		buf.WriteString(line[last:start])
+		last = end
+	}
+	buf.WriteString(line[last:])
+	return buf.String()
+}
+
+// projectTransform 只保留指定字段，输出为 key=value 的紧凑格式，没有任何字段时保持原行
+func projectTransform(fields []string) lineTransform {
+	return func(line string, p parser.Parser) string {
+		record := parseLine(p, line)
+		var parts []string
+		for _, field := range fields {
+			value, ok := record.Field(field)
+			if !ok || value == "" {
+				continue
+			}
+			if strings.ContainsAny(value, " \t\"=") {
+				value = strconv.Quote(value)
+			}
+			parts = append(parts, field+"="+value)
+		}
+		if len(parts) == 0 {
+			return line
+		}
+		return strings.Join(parts, " ")
+	}
+}
+
+// jsonMember JSON对象的一个成员
+type jsonMember struct {
+	key   string
+	value json.RawMessage
+}
+
+// orderedObject 保持字段顺序的JSON对象
+type orderedObject []jsonMember
+
+// decodeOrderedObject 按原有顺序解析JSON对象的顶层字段
+func decodeOrderedObject(line string) (orderedObject, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	var obj orderedObject
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false
+		}
+		obj = append(obj, jsonMember{key: key, value: value})
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('}') {
+		return nil, false
+	}
+	return obj, true
+}
+
+// drop 删除路径对应的字段，路径中间的对象会被递归处理，返回是否删除了字段
+func (obj orderedObject) drop(path []string) (orderedObject, bool) {
+	out := obj[:0:0]
+	dropped := false
+	for _, member := range obj {
+		if member.key != path[0] {
+			out = append(out, member)
+			continue
+		}
+		if len(path) == 1 {
+			dropped = true
+			continue
+		}
+		if nested, ok := decodeOrderedObject(string(member.value)); ok {
+			if nested, changed := nested.drop(path[1:]); changed {
+				member.value = json.RawMessage(nested.encode())
+				dropped = true
+			}
+		}
+		out = append(out, member)
+	}
+	return out, dropped
+}
+
+// encode 编码为紧凑的JSON
+func (obj orderedObject) encode() string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range obj {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(member.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		if err := json.Compact(&buf, member.value); err != nil {
+			buf.Write(member.value)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/anjude/log-tools/parser"
+)
+
+func TestDropTransform(t *testing.T) {
+	drop := dropTransform([]string{"headers", "request.body", "token"})
+	tests := []struct {
+		name, line, want string
+	}{
+		{"json", `{"a":1,"headers":{"x":"y"},"b":2}`, `{"a":1,"b":2}`},
+		{"json nested", `{"request": {"body": "x", "path": "/"}, "a": 1}`, `{"request":{"path":"/"},"a":1}`},
+		// 没有删除字段的行保持原样
+		{"json unchanged", `{"a": 1,  "b": {"c": 2}}`, `{"a": 1,  "b": {"c": 2}}`},
+		{"json nested unchanged", `{ "request": {"path": "/"} }`, `{ "request": {"path": "/"} }`},
+		{"kv middle", `level=info  token=abc msg="a  b"`, `level=info  msg="a  b"`},
+		{"kv start", `token="a b"   level=info`, `level=info`},
+		{"kv end", "msg=x\ttoken=abc", "msg=x"},
+		{"kv unchanged", "GET /index   200  mytoken=abc", "GET /index   200  mytoken=abc"},
+		{"text unchanged", "  indented   text\twith tabs  ", "  indented   text\twith tabs  "},
+	}
+	for _, tt := range tests {
+		if got := drop(tt.line, nil); got != tt.want {
+			t.Errorf("%s: drop(%q) = %q, want %q", tt.name, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTransformPipeline(t *testing.T) {
+	old := config.GetConfig()
+	config.SetConfig(&config.Config{Logs: config.LogsConfig{TransformPresets: map[string][]string{
+		"quiet":  {"drop:headers", "collapse"},
+		"nested": {"preset:quiet", "pretty_json"},
+		"loop":   {"loop"},
+	}}})
+	defer config.SetConfig(old)
+
+	logfmt, _ := parser.Get(parser.NameLogfmt)
+	tests := []struct {
+		specs   []string
+		preset  string
+		lines   []string
+		want    []string
+		wantErr string
+	}{
+		{
+			specs: []string{"timezone:UTC"},
+			lines: []string{`{"ts":"2026-10-19T08:00:00+08:00","msg":"a"}`, "2026-10-19T08:00:00+08:00 start"},
+			want:  []string{`{"ts":"2026-10-19T00:00:00.000Z","msg":"a"}`, "2026-10-19T00:00:00.000Z start"},
+		},
+		{
+			specs: []string{"collapse"},
+			lines: []string{"a", "a", "b", "a"},
+			want:  []string{"a ×2", "b", "a"},
+		},
+		{
+			preset: "quiet",
+			lines:  []string{`{"headers":1,"a":1}`, `{"a":1}`, `{"a":2}`},
+			want:   []string{`{"a":1} ×2`, `{"a":2}`},
+		},
+		{
+			specs: []string{"nested"},
+			lines: []string{`{"headers":1,"a":1}`},
+			want:  []string{"{\n  \"a\": 1\n}"},
+		},
+		{
+			specs: []string{"project:user,path"},
+			lines: []string{`level=info path="/a b" user=1`, "no fields"},
+			want:  []string{`user=1 path="/a b"`, "no fields"},
+		},
+		{specs: []string{"unknown"}, wantErr: "未知的转换"},
+		{specs: []string{"drop"}, wantErr: "drop 需要指定字段"},
+		{specs: []string{"timezone:Nowhere/City"}, wantErr: "未知的时区"},
+		{preset: "missing", wantErr: "未知的转换预设"},
+		{preset: "loop", wantErr: "嵌套过深"},
+	}
+	for _, tt := range tests {
+		pipeline, err := newTransformPipeline(tt.specs, tt.preset)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%v %q: error = %v, want %q", tt.specs, tt.preset, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v %q: %v", tt.specs, tt.preset, err)
+		}
+		got, _ := pipeline.apply(tt.lines, logfmt)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%v %q: apply = %q, want %q", tt.specs, tt.preset, got, tt.want)
+		}
+	}
+
+	if pipeline, err := newTransformPipeline(nil, ""); pipeline != nil || err != nil {
+		t.Errorf("empty pipeline = %v, %v", pipeline, err)
+	}
+}
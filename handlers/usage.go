@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anjude/log-tools/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// usageSampleInterval 后台记录各目录、日志源和文件组总大小的间隔，用于计算增长
+	usageSampleInterval = 10 * time.Minute
+	// usageMinSampleGap 两次记录的最小间隔，请求磁盘占用报告时也会记录
+	usageMinSampleGap = time.Minute
+	// usageGrowthWindow 统计增长的时间窗口
+	usageGrowthWindow = 24 * time.Hour
+	// defaultUsageTop 每组默认返回的最大文件数
+	defaultUsageTop = 10
+	// maxUsageTop 每组最多返回的最大文件数
+	maxUsageTop = 100
+	// maxUsageProjectionDays 预计写满的天数超过该值时不返回写满时间
+	maxUsageProjectionDays = 36500
+	// usageMinProjectionWindow 预测写满时间所需的最短增长记录时长，服务刚启动时的短时写入不足以推算
+	usageMinProjectionWindow = time.Hour
+)
+
+// UsageFile 磁盘占用报告中的文件
+type UsageFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mod_time"`
+}
+
+// UsageSummary 一组文件（日志目录、日志源或轮转文件组）的磁盘占用
+type UsageSummary struct {
+	Name         string      `json:"name"`                   // 目录路径、日志源名称或文件组路径
+	DisplayName  string      `json:"display_name,omitempty"` // 日志源的显示名称
+	Source       string      `json:"source,omitempty"`       // 目录和文件组所属的日志源
+	Size         int64       `json:"size"`                   // 总大小
+	Files        int         `json:"files"`                  // 文件数
+	Largest      []UsageFile `json:"largest"`                // 最大的文件，按大小降序
+	Growth24h    int64       `json:"growth_24h"`             // 最近24小时的净增长（字节），记录不足24小时时为已记录时间内的增长
+	GrowthWindow int64       `json:"growth_window"`          // 增长实际覆盖的秒数，服务启动后开始记录
+}
+
+// FilesystemUsage 日志目录所在文件系统的容量和写满预测
+type FilesystemUsage struct {
+	Directories   []string `json:"directories"`       // 位于该文件系统上的日志目录
+	Total         uint64   `json:"total"`             // 总容量
+	Free          uint64   `json:"free"`              // 剩余空间
+	Available     uint64   `json:"available"`         // 非特权用户可用的空间
+	UsedPercent   float64  `json:"used_percent"`      // 已用百分比
+	GrowthPerDay  int64    `json:"growth_per_day"`    // 按各日志目录的增长推算的每天增长
+	DaysUntilFull float64  `json:"days_until_full"`   // 按当前增长预计写满可用空间的天数，没有增长或记录不足1小时时为 -1
+	FullAt        string   `json:"full_at,omitempty"` // 预计写满的时间，没有增长、记录不足1小时或过于久远时为空
+}
+
+// diskStat 文件系统的容量（字节）
+type diskStat struct {
+	device    uint64
+	total     uint64
+	free      uint64
+	available uint64
+}
+
+// usageGroup 统计中的一组文件
+type usageGroup struct {
+	key         string // 增长记录的键
+	name        string
+	displayName string
+	source      string
+	files       []UsageFile
+	size        int64
+}
+
+// add 将文件加入分组
+func (g *usageGroup) add(path string, size int64, modTime time.Time) {
+	g.files = append(g.files, UsageFile{Path: path, Size: size, ModTime: modTime.Format("2006-01-02 15:04:05")})
+	g.size += size
+}
+
+// usageSample 分组总大小的记录
+type usageSample struct {
+	at   time.Time
+	size int64
+}
+
+var (
+	usageSamples     = make(map[string][]usageSample)
+	usageSamplesMu   sync.Mutex
+	usageSamplerOnce sync.Once
+)
+
+// startUsageSampler 启动后台任务，定期记录各分组的总大小
+func startUsageSampler() {
+	usageSamplerOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(usageSampleInterval)
+			defer ticker.Stop()
+			for {
+				if cfg := config.GetConfig(); cfg != nil {
+					dirs, sources, families := collectUsage(cfg)
+					recordUsage(time.Now(), dirs, sources, families)
+				}
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// collectUsage 按日志目录、日志源和轮转文件组统计文件目录中的文件和日志源中确定的文件
+// 磁盘占用不受保留时长影响，已超过保留时长的文件也计入
+func collectUsage(cfg *config.Config) (dirs, sources, families []*usageGroup) {
+	files, err := catalogFiles(cfg)
+	if err != nil {
+		fmt.Printf("统计磁盘占用失败: %v\n", err)
+	}
+
+	dirIndex := make(map[string]*usageGroup)
+	sourceIndex := make(map[string]*usageGroup)
+	for _, source := range cfg.LogSources() {
+		group := &usageGroup{key: "source:" + source.Name, name: source.Name, displayName: source.Title()}
+		sourceIndex[source.Name] = group
+		sources = append(sources, group)
+		for _, dir := range source.Directories {
+			root := filepath.Clean(dir.Path)
+			if _, ok := dirIndex[root]; ok {
+				continue
+			}
+			group := &usageGroup{key: "dir:" + root, name: dir.Path, source: source.Name}
+			dirIndex[root] = group
+			dirs = append(dirs, group)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range files {
+		if absPath, err := filepath.Abs(f.Path); err == nil {
+			seen[absPath] = true
+		}
+		if group, ok := dirIndex[filepath.Clean(f.Root)]; ok {
+			group.add(f.Path, f.Size, f.ModTime)
+		}
+		if group, ok := sourceIndex[f.Source]; ok {
+			group.add(f.Path, f.Size, f.ModTime)
+		}
+	}
+
+	// 确定的文件只计入日志源，已在文件目录中的不重复计算
+	for _, source := range cfg.LogSources() {
+		for _, file := range source.Files {
+			absPath, err := filepath.Abs(file)
+			if err != nil || seen[absPath] {
+				continue
+			}
+			info, err := os.Stat(absPath)
+			if err != nil {
+				continue
+			}
+			seen[absPath] = true
+			sourceIndex[source.Name].add(file, info.Size(), info.ModTime())
+		}
+	}
+
+	_, fams := groupFamilies(files, currentRotation(cfg))
+	for _, fam := range fams {
+		group := &usageGroup{key: "family:" + fam.active, name: familyScheme + fam.active, source: fam.members[0].file.Source}
+		for _, m := range fam.members {
+			group.add(m.file.Path, m.file.Size, m.file.ModTime)
+		}
+		families = append(families, group)
+	}
+	sort.SliceStable(families, func(i, j int) bool { return families[i].size > families[j].size })
+	return dirs, sources, families
+}
+
+// recordUsage 记录各分组的总大小，距上次记录不足 usageMinSampleGap 时跳过；不再存在的分组删除记录
+func recordUsage(now time.Time, groups ...[]*usageGroup) {
+	usageSamplesMu.Lock()
+	defer usageSamplesMu.Unlock()
+
+	next := make(map[string][]usageSample)
+	for _, list := range groups {
+		for _, g := range list {
+			samples := usageSamples[g.key]
+			if n := len(samples); n == 0 || now.Sub(samples[n-1].at) >= usageMinSampleGap {
+				samples = append(samples, usageSample{at: now, size: g.size})
+			}
+			// 只保留时间窗口内的记录和窗口开始前的最后一条
+			from := now.Add(-usageGrowthWindow)
+			keep := 0
+			for keep+1 < len(samples) && !samples[keep+1].at.After(from) {
+				keep++
+			}
+			next[g.key] = samples[keep:]
+		}
+	}
+	usageSamples = next
+}
+
+// usageGrowth 返回分组在时间窗口内的净增长和实际覆盖的时长
+// 以窗口开始前的最后一条记录为基准，没有时以最早的记录为基准
+func usageGrowth(key string, size int64, now time.Time) (int64, time.Duration) {
+	usageSamplesMu.Lock()
+	defer usageSamplesMu.Unlock()
+	samples := usageSamples[key]
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	from := now.Add(-usageGrowthWindow)
+	base := samples[0]
+	for _, s := range samples[1:] {
+		if s.at.After(from) {
+			break
+		}
+		base = s
+	}
+	start := base.at
+	if start.Before(from) {
+		start = from
+	}
+	return size - base.size, now.Sub(start)
+}
+
+// summarize 生成分组的磁盘占用，largest 最多返回 top 个文件
+func (g *usageGroup) summarize(top int, now time.Time) UsageSummary {
+	sort.SliceStable(g.files, func(i, j int) bool { return g.files[i].Size > g.files[j].Size })
+	largest := g.files
+	if len(largest) > top {
+		largest = largest[:top]
+	}
+	growth, window := usageGrowth(g.key, g.size, now)
+	return UsageSummary{
+		Name:         g.name,
+		DisplayName:  g.displayName,
+		Source:       g.source,
+		Size:         g.size,
+		Files:        len(g.files),
+		Largest:      append([]UsageFile{}, largest...),
+		Growth24h:    growth,
+		GrowthWindow: int64(window / time.Second),
+	}
+}
+
+// filesystemUsage 读取各日志目录所在文件系统的容量，按目录的增长速度预测写满时间
+// 同一文件系统上的多个目录合并为一项，增长速度相加；有目录的增长记录不足 usageMinProjectionWindow 时不预测
+func filesystemUsage(dirs []UsageSummary, now time.Time) []FilesystemUsage {
+	var result []FilesystemUsage
+	index := make(map[uint64]int)
+	rates := make(map[uint64]float64)
+	short := make(map[uint64]bool)
+	for _, dir := range dirs {
+		stat, err := statDisk(dir.Name)
+		if err != nil {
+			fmt.Printf("读取文件系统容量失败 %s: %v\n", dir.Name, err)
+			continue
+		}
+		i, ok := index[stat.device]
+		if !ok {
+			i = len(result)
+			index[stat.device] = i
+			fs := FilesystemUsage{
+				Total:         stat.total,
+				Free:          stat.free,
+				Available:     stat.available,
+				DaysUntilFull: -1,
+			}
+			if stat.total > 0 {
+				fs.UsedPercent = float64(stat.total-stat.free) / float64(stat.total) * 100
+			}
+			result = append(result, fs)
+		}
+		result[i].Directories = append(result[i].Directories, dir.Name)
+		if dir.GrowthWindow > 0 {
+			rates[stat.device] += float64(dir.Growth24h) / float64(dir.GrowthWindow)
+		}
+		if time.Duration(dir.GrowthWindow)*time.Second < usageMinProjectionWindow {
+			short[stat.device] = true
+		}
+	}
+
+	for device, i := range index {
+		rate := rates[device]
+		result[i].GrowthPerDay = int64(rate * 86400)
+		if rate <= 0 || short[device] {
+			continue
+		}
+		days := float64(result[i].Available) / rate / 86400
+		result[i].DaysUntilFull = days
+		if days <= maxUsageProjectionDays {
+			result[i].FullAt = now.Add(time.Duration(days * float64(24*time.Hour))).Format("2006-01-02 15:04:05")
+		}
+	}
+	return result
+}
+
+// GetDiskUsage 磁盘占用报告：按日志目录、日志源和轮转文件组统计总大小、文件数、最大的文件和最近24小时的增长，
+// 并根据目录所在文件系统的剩余空间和增长速度预测写满时间
+// top 指定每组返回的最大文件数，默认10
+func GetDiskUsage(c *gin.Context) {
+	top := defaultUsageTop
+	if value := c.Query("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "top 参数无效",
+			})
+			return
+		}
+		top = min(n, maxUsageTop)
+	}
+
+	cfg := config.GetConfig()
+	now := time.Now()
+	dirs, sources, families := collectUsage(cfg)
+	recordUsage(now, dirs, sources, families)
+
+	summarize := func(groups []*usageGroup) []UsageSummary {
+		result := make([]UsageSummary, 0, len(groups))
+		for _, g := range groups {
+			result = append(result, g.summarize(top, now))
+		}
+		return result
+	}
+	dirUsage := summarize(dirs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"directories": dirUsage,
+		"sources":     summarize(sources),
+		"families":    summarize(families),
+		"filesystems": filesystemUsage(dirUsage, now),
+		"time":        now.Format("2006-01-02 15:04:05"),
+	})
+}
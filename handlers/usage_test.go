@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageGrowth(t *testing.T) {
+	usageSamplesMu.Lock()
+	saved := usageSamples
+	usageSamples = make(map[string][]usageSample)
+	usageSamplesMu.Unlock()
+	defer func() {
+		usageSamplesMu.Lock()
+		usageSamples = saved
+		usageSamplesMu.Unlock()
+	}()
+
+	start := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	record := func(offset time.Duration, size int64) {
+		recordUsage(start.Add(offset), []*usageGroup{{key: "dir:/logs", size: size}})
+	}
+	record(0, 100)
+	record(30*time.Second, 150) // 距上次不足 usageMinSampleGap，不记录
+	record(time.Hour, 400)
+	record(20*time.Hour, 1000)
+	record(30*time.Hour, 1600)
+
+	tests := []struct {
+		name   string
+		key    string
+		size   int64
+		at     time.Duration
+		growth int64
+		window time.Duration
+	}{
+		{"no samples", "dir:/other", 50, time.Hour, 0, 0},
+		// 窗口开始前的最后一条记录（1小时处）为基准，覆盖整个窗口
+		{"full window", "dir:/logs", 1800, 30 * time.Hour, 1400, 24 * time.Hour},
+		{"shrink", "dir:/logs", 200, 30 * time.Hour, -200, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		growth, window := usageGrowth(tt.key, tt.size, start.Add(tt.at))
+		if growth != tt.growth || window != tt.window {
+			t.Errorf("%s: usageGrowth = %d, %v, want %d, %v", tt.name, growth, window, tt.growth, tt.window)
+		}
+	}
+
+	// 记录不足24小时时以最早的记录为基准
+	usageSamplesMu.Lock()
+	usageSamples = make(map[string][]usageSample)
+	usageSamplesMu.Unlock()
+	record(0, 100)
+	if growth, window := usageGrowth("dir:/logs", 160, start.Add(2*time.Hour)); growth != 60 || window != 2*time.Hour {
+		t.Errorf("partial window: usageGrowth = %d, %v", growth, window)
+	}
+}
+
+func TestFilesystemUsageProjection(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		dirs    []UsageSummary
+		project bool
+	}{
+		{"no growth", []UsageSummary{{Name: dir, Growth24h: 0, GrowthWindow: 86400}}, false},
+		{"shrinking", []UsageSummary{{Name: dir, Growth24h: -1 << 20, GrowthWindow: 86400}}, false},
+		{"short window", []UsageSummary{{Name: dir, Growth24h: 1 << 30, GrowthWindow: 600}}, false},
+		{"one short directory", []UsageSummary{{Name: dir, Growth24h: 1 << 30, GrowthWindow: 86400}, {Name: dir, Growth24h: 1 << 20, GrowthWindow: 60}}, false},
+		{"growing", []UsageSummary{{Name: dir, Growth24h: 1 << 30, GrowthWindow: 7200}}, true},
+	}
+	for _, tt := range tests {
+		result := filesystemUsage(tt.dirs, now)
+		if len(result) != 1 {
+			t.Fatalf("%s: %d filesystems", tt.name, len(result))
+		}
+		fs := result[0]
+		if len(fs.Directories) != len(tt.dirs) {
+			t.Errorf("%s: directories = %v", tt.name, fs.Directories)
+		}
+		if projected := fs.DaysUntilFull >= 0; projected != tt.project {
+			t.Errorf("%s: days until full = %v", tt.name, fs.DaysUntilFull)
+		}
+		if !tt.project && fs.FullAt != "" {
+			t.Errorf("%s: full at = %s", tt.name, fs.FullAt)
+		}
+	}
+}
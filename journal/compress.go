@@ -0,0 +1,52 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// zstdDecoder 共享的 zstd 解码器，DecodeAll 可并发调用
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxObjectSize))
+
+// decompress 按对象标志解压 DATA 对象的内容
+func decompress(flags byte, payload []byte) ([]byte, error) {
+	switch {
+	case flags&objectCompressedZSTD != 0:
+		return zstdDecoder.DecodeAll(payload, nil)
+	case flags&objectCompressedLZ4 != 0:
+		return decompressLZ4(payload)
+	case flags&objectCompressedXZ != 0:
+		r, err := xz.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(io.LimitReader(r, maxObjectSize))
+	}
+	return payload, nil
+}
+
+// decompressLZ4 解压 journald 的 LZ4 数据：8字节小端原始长度，后接 LZ4 块
+func decompressLZ4(payload []byte) ([]byte, error) {
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("LZ4数据长度不足")
+	}
+	size := binary.LittleEndian.Uint64(payload[:8])
+	if size > maxObjectSize {
+		return nil, fmt.Errorf("LZ4原始长度过大: %d", size)
+	}
+	dst := make([]byte, size)
+	n, err := lz4.UncompressBlock(payload[8:], dst)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) != size {
+		return nil, fmt.Errorf("LZ4解压长度不匹配: 期望 %d 实际 %d", size, n)
+	}
+	return dst, nil
+}
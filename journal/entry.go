@@ -0,0 +1,114 @@
+package journal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PriorityNames syslog 优先级名称，下标即优先级数值
+var PriorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// defaultPriority 条目没有 PRIORITY 字段时使用的优先级（info）
+const defaultPriority = 6
+
+// Entry journal 条目
+type Entry struct {
+	Seqnum    uint64
+	Realtime  time.Time
+	Monotonic time.Duration
+	BootID    string
+	Fields    map[string]string
+}
+
+// Message 返回 MESSAGE 字段
+func (e *Entry) Message() string {
+	return e.Fields["MESSAGE"]
+}
+
+// Priority 返回条目的优先级，没有或无效时返回 info
+func (e *Entry) Priority() int {
+	p, err := strconv.Atoi(e.Fields["PRIORITY"])
+	if err != nil || p < 0 || p >= len(PriorityNames) {
+		return defaultPriority
+	}
+	return p
+}
+
+// Unit 返回条目所属的单元：系统单元、用户单元，都没有时使用 syslog 标识（如 kernel）
+func (e *Entry) Unit() string {
+	for _, name := range []string{"_SYSTEMD_UNIT", "_SYSTEMD_USER_UNIT", "SYSLOG_IDENTIFIER", "_COMM"} {
+		if value := e.Fields[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Identifier 返回程序标识
+func (e *Entry) Identifier() string {
+	if value := e.Fields["SYSLOG_IDENTIFIER"]; value != "" {
+		return value
+	}
+	return e.Fields["_COMM"]
+}
+
+// PID 返回进程号
+func (e *Entry) PID() string {
+	if value := e.Fields["_PID"]; value != "" {
+		return value
+	}
+	return e.Fields["SYSLOG_PID"]
+}
+
+// ParsePriority 解析优先级，支持数值（0-7）和名称（如 err、warning，也接受 error、warn）
+func ParsePriority(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if p, err := strconv.Atoi(value); err == nil {
+		if p < 0 || p >= len(PriorityNames) {
+			return 0, fmt.Errorf("优先级超出范围: %d", p)
+		}
+		return p, nil
+	}
+	switch value {
+	case "error":
+		value = "err"
+	case "warn":
+		value = "warning"
+	case "critical":
+		value = "crit"
+	case "emergency":
+		value = "emerg"
+	}
+	for i, name := range PriorityNames {
+		if name == value {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("未知的优先级: %s", value)
+}
+
+// Filter 条目过滤条件
+type Filter struct {
+	Unit     string // 单元名称，可省略 .service 后缀，为空表示全部
+	Priority int    // 只保留该优先级及更严重的条目，小于0表示不过滤
+	BootID   string // 启动ID（可为前缀），为空表示全部
+}
+
+// Match 判断条目是否满足过滤条件
+func (f Filter) Match(e *Entry) bool {
+	if f.Unit != "" {
+		unit := e.Unit()
+		if unit != f.Unit && unit != f.Unit+".service" {
+			return false
+		}
+	}
+	if f.Priority >= 0 && e.Priority() > f.Priority {
+		return false
+	}
+	if f.BootID != "" && !strings.HasPrefix(e.BootID, strings.ToLower(f.BootID)) {
+		return false
+	}
+	return true
+}
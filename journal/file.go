@@ -0,0 +1,357 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// signature journal 文件头签名
+var signature = []byte("LPKSHHRH")
+
+// 对象类型
+const (
+	objectData       = 1
+	objectEntry      = 3
+	objectEntryArray = 6
+)
+
+// 对象标志，表示 DATA 对象的压缩方式
+const (
+	objectCompressedXZ   = 1 << 0
+	objectCompressedLZ4  = 1 << 1
+	objectCompressedZSTD = 1 << 2
+)
+
+// 文件头不兼容标志
+const (
+	incompatibleCompressedXZ   = 1 << 0
+	incompatibleCompressedLZ4  = 1 << 1
+	incompatibleKeyedHash      = 1 << 2
+	incompatibleCompressedZSTD = 1 << 3
+	incompatibleCompact        = 1 << 4
+
+	incompatibleSupported = incompatibleCompressedXZ | incompatibleCompressedLZ4 |
+		incompatibleKeyedHash | incompatibleCompressedZSTD | incompatibleCompact
+)
+
+const (
+	// minHeaderSize 读取所需的最小文件头长度（到 tail_entry_monotonic 为止）
+	minHeaderSize = 208
+	// objectHeaderSize 对象头长度：type、flags、reserved[6]、size
+	objectHeaderSize = 16
+	// entryHeaderSize ENTRY 对象中条目项之前的长度
+	entryHeaderSize = 64
+	// entryArrayHeaderSize ENTRY_ARRAY 对象中条目偏移之前的长度
+	entryArrayHeaderSize = 24
+	// maxObjectSize 单个对象的最大长度，防止损坏的文件导致分配过多内存
+	maxObjectSize = 64 << 20
+	// maxCachedFields DATA 对象缓存的最大数量，同一字段值在条目间共享，缓存可减少重复读取
+	maxCachedFields = 8192
+)
+
+// field 解析后的 DATA 对象
+type field struct {
+	name  string
+	value string
+	ok    bool // false 表示数据不是 字段名=值 的形式
+}
+
+// File 单个 journal 文件的读取器，按条目写入顺序遍历
+type File struct {
+	path     string
+	f        *os.File
+	size     int64
+	compact  bool
+	id       string
+	headTime time.Time
+
+	nEntries   uint64
+	read       uint64
+	firstArray uint64
+	array      uint64   // 当前 ENTRY_ARRAY 的偏移
+	items      []uint64 // 当前 ENTRY_ARRAY 中的条目偏移
+	index      int
+	nextArray  uint64
+	lastArray  uint64
+
+	fields map[uint64]field
+}
+
+// Position 文件中已读取到的位置，用于之后从该位置继续读取新追加的条目
+type Position struct {
+	Array uint64 // 当前条目数组的偏移，0 表示尚未读取
+	Index int    // 下一个条目在数组中的下标
+	Read  uint64 // 已读取的条目数
+}
+
+// Open 打开 journal 文件并校验文件头
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	header := make([]byte, minHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("无效的journal文件 %s: 文件头不完整", path)
+	}
+	if !bytes.Equal(header[:8], signature) {
+		f.Close()
+		return nil, fmt.Errorf("无效的journal文件 %s: 签名不匹配", path)
+	}
+	incompatible := binary.LittleEndian.Uint32(header[12:16])
+	if incompatible&^incompatibleSupported != 0 {
+		f.Close()
+		return nil, fmt.Errorf("不支持的journal文件 %s: 未知的不兼容标志 %#x", path, incompatible)
+	}
+	if headerSize := binary.LittleEndian.Uint64(header[88:96]); headerSize < minHeaderSize {
+		f.Close()
+		return nil, fmt.Errorf("无效的journal文件 %s: 文件头长度 %d", path, headerSize)
+	}
+
+	file := &File{
+		path:       path,
+		f:          f,
+		size:       info.Size(),
+		compact:    incompatible&incompatibleCompact != 0,
+		id:         hex.EncodeToString(header[24:40]),
+		nEntries:   binary.LittleEndian.Uint64(header[152:160]),
+		firstArray: binary.LittleEndian.Uint64(header[176:184]),
+		fields:     make(map[uint64]field),
+	}
+	file.nextArray = file.firstArray
+	if head := binary.LittleEndian.Uint64(header[184:192]); head != 0 {
+		file.headTime = time.UnixMicro(int64(head))
+	}
+	return file, nil
+}
+
+// ID 返回文件头中的文件ID，journald 归档文件时只重命名，文件ID不变
+func (f *File) ID() string {
+	return f.id
+}
+
+// HeadTime 返回文件中第一个条目的时间，没有条目时返回零值
+func (f *File) HeadTime() time.Time {
+	return f.headTime
+}
+
+// Position 返回已读取的条目之后的位置
+func (f *File) Position() Position {
+	return Position{Array: f.array, Index: f.index, Read: f.read}
+}
+
+// Seek 从之前读取到的位置继续读取，之后 Next 只返回该位置之后追加的条目
+// 位置与文件内容不符（如文件已被替换或截断）时返回错误
+func (f *File) Seek(p Position) error {
+	if p.Read > f.nEntries || (p.Array == 0 && (p.Index != 0 || p.Read != 0)) {
+		return fmt.Errorf("journal文件 %s 的读取位置无效", f.path)
+	}
+	f.items, f.index, f.read, f.array, f.lastArray = f.items[:0], 0, 0, 0, 0
+	f.nextArray = f.firstArray
+	if p.Array == 0 {
+		return nil
+	}
+	if err := f.loadArray(p.Array); err != nil {
+		return err
+	}
+	if p.Index > len(f.items) {
+		return fmt.Errorf("journal文件 %s 的读取位置无效", f.path)
+	}
+	f.index, f.read = p.Index, p.Read
+	return nil
+}
+
+// Path 返回文件路径
+func (f *File) Path() string {
+	return f.path
+}
+
+// Close 关闭文件
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// Next 返回下一个条目，没有更多条目时返回 io.EOF
+func (f *File) Next() (*Entry, error) {
+	for f.read < f.nEntries {
+		if f.index >= len(f.items) {
+			if f.nextArray == 0 {
+				return nil, io.EOF
+			}
+			if err := f.loadArray(f.nextArray); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		offset := f.items[f.index]
+		if offset == 0 {
+			// 数组中尚未使用的位置，之后追加的条目会写在这里
+			return nil, io.EOF
+		}
+		f.index++
+		f.read++
+		return f.readEntry(offset)
+	}
+	return nil, io.EOF
+}
+
+// loadArray 读取 ENTRY_ARRAY 对象
+func (f *File) loadArray(offset uint64) error {
+	// 数组按追加顺序链接，偏移不递增说明文件已损坏，避免死循环
+	if offset <= f.lastArray {
+		return fmt.Errorf("journal文件 %s 的条目数组链接异常: %d", f.path, offset)
+	}
+	f.lastArray = offset
+
+	obj, _, err := f.readObject(offset, objectEntryArray)
+	if err != nil {
+		return err
+	}
+	if len(obj) < entryArrayHeaderSize {
+		return fmt.Errorf("journal文件 %s 的条目数组长度异常: %d", f.path, offset)
+	}
+	f.nextArray = binary.LittleEndian.Uint64(obj[16:24])
+
+	f.array = offset
+	f.items = f.items[:0]
+	f.index = 0
+	body := obj[entryArrayHeaderSize:]
+	if f.compact {
+		for i := 0; i+4 <= len(body); i += 4 {
+			f.items = append(f.items, uint64(binary.LittleEndian.Uint32(body[i:])))
+		}
+	} else {
+		for i := 0; i+8 <= len(body); i += 8 {
+			f.items = append(f.items, binary.LittleEndian.Uint64(body[i:]))
+		}
+	}
+	return nil
+}
+
+// readEntry 读取 ENTRY 对象及其引用的字段
+func (f *File) readEntry(offset uint64) (*Entry, error) {
+	obj, _, err := f.readObject(offset, objectEntry)
+	if err != nil {
+		return nil, err
+	}
+	if len(obj) < entryHeaderSize {
+		return nil, fmt.Errorf("journal文件 %s 的条目长度异常: %d", f.path, offset)
+	}
+
+	entry := &Entry{
+		Seqnum:    binary.LittleEndian.Uint64(obj[16:24]),
+		Realtime:  time.UnixMicro(int64(binary.LittleEndian.Uint64(obj[24:32]))),
+		Monotonic: time.Duration(binary.LittleEndian.Uint64(obj[32:40])) * time.Microsecond,
+		BootID:    hex.EncodeToString(obj[40:56]),
+		Fields:    make(map[string]string),
+	}
+
+	itemSize := 16
+	if f.compact {
+		itemSize = 4
+	}
+	for i := entryHeaderSize; i+itemSize <= len(obj); i += itemSize {
+		var dataOffset uint64
+		if f.compact {
+			dataOffset = uint64(binary.LittleEndian.Uint32(obj[i:]))
+		} else {
+			dataOffset = binary.LittleEndian.Uint64(obj[i:])
+		}
+		fd, err := f.readField(dataOffset)
+		if err != nil {
+			return nil, err
+		}
+		if !fd.ok {
+			continue
+		}
+		// 同名字段可能出现多次，保留第一个
+		if _, exists := entry.Fields[fd.name]; !exists {
+			entry.Fields[fd.name] = fd.value
+		}
+	}
+	return entry, nil
+}
+
+// readField 读取 DATA 对象，解析为 字段名=值
+func (f *File) readField(offset uint64) (field, error) {
+	if fd, ok := f.fields[offset]; ok {
+		return fd, nil
+	}
+
+	obj, flags, err := f.readObject(offset, objectData)
+	if err != nil {
+		return field{}, err
+	}
+	start := 64
+	if f.compact {
+		start = 72
+	}
+	if len(obj) < start {
+		return field{}, fmt.Errorf("journal文件 %s 的数据对象长度异常: %d", f.path, offset)
+	}
+	payload := obj[start:]
+
+	if flags&(objectCompressedXZ|objectCompressedLZ4|objectCompressedZSTD) != 0 {
+		payload, err = decompress(flags, payload)
+		if err != nil {
+			return field{}, fmt.Errorf("journal文件 %s 的数据对象解压失败: %v", f.path, err)
+		}
+	}
+
+	var fd field
+	eq := bytes.IndexByte(payload, '=')
+	if eq <= 0 {
+		f.cacheField(offset, fd)
+		return fd, nil
+	}
+	fd = field{name: string(payload[:eq]), value: string(payload[eq+1:]), ok: true}
+	f.cacheField(offset, fd)
+	return fd, nil
+}
+
+// cacheField 缓存解析后的字段，超过上限时清空重新缓存
+func (f *File) cacheField(offset uint64, fd field) {
+	if len(f.fields) >= maxCachedFields {
+		f.fields = make(map[uint64]field)
+	}
+	f.fields[offset] = fd
+}
+
+// readObject 读取指定偏移的对象，返回包含对象头的完整内容和对象标志
+func (f *File) readObject(offset uint64, wantType byte) ([]byte, byte, error) {
+	if offset%8 != 0 || int64(offset)+objectHeaderSize > f.size {
+		return nil, 0, fmt.Errorf("journal文件 %s 的对象偏移无效: %d", f.path, offset)
+	}
+
+	header := make([]byte, objectHeaderSize)
+	if _, err := f.f.ReadAt(header, int64(offset)); err != nil {
+		return nil, 0, err
+	}
+	objType, flags := header[0], header[1]
+	size := binary.LittleEndian.Uint64(header[8:16])
+	if objType != wantType {
+		return nil, 0, fmt.Errorf("journal文件 %s 的对象类型不匹配: 偏移 %d 期望 %d 实际 %d", f.path, offset, wantType, objType)
+	}
+	if size < objectHeaderSize || size > maxObjectSize || int64(offset+size) > f.size {
+		return nil, 0, fmt.Errorf("journal文件 %s 的对象长度无效: 偏移 %d 长度 %d", f.path, offset, size)
+	}
+
+	obj := make([]byte, size)
+	if _, err := f.f.ReadAt(obj, int64(offset)); err != nil {
+		return nil, 0, err
+	}
+	return obj, flags, nil
+}
@@ -0,0 +1,115 @@
+package journal
+
+import (
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FindFiles 递归查找目录下的 journal 文件（*.journal，以及异常关闭留下的 *.journal~）
+func FindFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无权限的子目录（如其他用户的日志）直接跳过
+			if d != nil && d.IsDir() && path != dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() && (strings.HasSuffix(path, ".journal") || strings.HasSuffix(path, ".journal~")) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Reader 合并读取多个 journal 文件，按条目时间顺序返回
+type Reader struct {
+	files []*File
+	ids   []string
+	heads []*Entry
+	next  []Position // 读取 heads 中的条目之后各文件的位置
+	pos   []Position // 已返回的条目之后各文件的位置
+	errs  []error
+}
+
+// NewReader 创建合并读取器，关闭读取器时会关闭所有文件
+// 文件可以先通过 Seek 定位，只读取之后追加的条目
+func NewReader(files []*File) *Reader {
+	r := &Reader{
+		files: files,
+		ids:   make([]string, len(files)),
+		heads: make([]*Entry, len(files)),
+		next:  make([]Position, len(files)),
+		pos:   make([]Position, len(files)),
+	}
+	for i, f := range files {
+		r.ids[i] = f.ID()
+		r.pos[i] = f.Position()
+	}
+	return r
+}
+
+// Next 返回时间最早的下一个条目，没有更多条目时返回 io.EOF
+// 单个文件读取出错（如正在写入的文件末尾不完整）时停止读取该文件，错误可通过 Errors 获取
+func (r *Reader) Next() (*Entry, error) {
+	next := -1
+	for i, f := range r.files {
+		if f == nil {
+			continue
+		}
+		if r.heads[i] == nil {
+			entry, err := f.Next()
+			if err != nil {
+				if err != io.EOF {
+					r.errs = append(r.errs, err)
+				}
+				r.files[i] = nil
+				f.Close()
+				continue
+			}
+			r.heads[i] = entry
+			r.next[i] = f.Position()
+		}
+		if next < 0 || r.heads[i].Realtime.Before(r.heads[next].Realtime) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return nil, io.EOF
+	}
+	entry := r.heads[next]
+	r.heads[next] = nil
+	r.pos[next] = r.next[next]
+	return entry, nil
+}
+
+// Positions 返回各文件（按文件ID）已返回的条目之后的位置，用于之后只读取新追加的条目
+func (r *Reader) Positions() map[string]Position {
+	positions := make(map[string]Position, len(r.ids))
+	for i, id := range r.ids {
+		positions[id] = r.pos[i]
+	}
+	return positions
+}
+
+// Errors 返回读取过程中遇到的错误
+func (r *Reader) Errors() []error {
+	return r.errs
+}
+
+// Close 关闭所有文件
+func (r *Reader) Close() error {
+	for i, f := range r.files {
+		if f != nil {
+			f.Close()
+			r.files[i] = nil
+		}
+	}
+	return nil
+}
@@ -18,6 +18,13 @@ func main() {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
+	// 注册自定义日志格式，配置重新加载时同步更新
+	config.OnReload(handlers.RegisterFormats)
+	config.OnReload(handlers.RegisterRedaction)
+
+	// 建立日志文件目录，日志目录或文件模式变化时重新建立
+	config.OnReload(handlers.RegisterCatalog)
+
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
 
@@ -47,8 +54,23 @@ func main() {
 		logs.Use(middleware.AuthRequired())
 		{
 			logs.GET("/files", handlers.GetLogFiles)
+			logs.GET("/sources/stale", handlers.GetStaleSources)
+			logs.GET("/usage", handlers.GetDiskUsage)
+			logs.GET("/events", handlers.GetFileEvents)
 			logs.GET("/content", handlers.GetLogContent)
+			logs.GET("/hexdump", handlers.GetHexdump)
+			logs.GET("/journal", handlers.GetJournalInfo)
+			logs.GET("/levels", handlers.GetLevelStats)
+			logs.POST("/stats", handlers.GetFieldStats)
+			logs.GET("/formats", handlers.GetLogFormats)
+			logs.GET("/formats/stats", handlers.GetFormatStats)
+			logs.POST("/formats/test", handlers.TestFormat)
 			logs.POST("/search", handlers.SearchLogs)
+			logs.POST("/query", handlers.QueryLogs)
+			logs.POST("/trace", handlers.TraceLogs)
+			logs.POST("/cluster", handlers.ClusterLogs)
+			logs.POST("/cluster/lines", handlers.GetClusterLines)
+			logs.POST("/cluster/compare", handlers.CompareClusters)
 		}
 	}
 
@@ -8,9 +8,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// 简单的内存存储认证
+// 简单的内存存储认证，token -> 用户名
 var (
-	authenticatedUsers = make(map[string]bool)
+	authenticatedUsers = make(map[string]string)
 	authMutex          sync.RWMutex
 )
 
@@ -71,24 +71,23 @@ func AuthRequired() gin.HandlerFunc {
 	}
 }
 
-// GetCurrentUser 获取当前用户ID
+// GetCurrentUser 获取当前登录的用户名，未登录时返回空字符串
 func GetCurrentUser(c *gin.Context) string {
 	token := c.GetHeader("Authorization")
 	if token == "" {
 		token = c.Query("token")
 	}
 
-	if isAuthenticated(token) {
-		return "milk" // 简化处理
-	}
-	return ""
+	authMutex.RLock()
+	defer authMutex.RUnlock()
+	return authenticatedUsers[token]
 }
 
 // SetAuthenticated 设置用户认证状态
-func SetAuthenticated(token string) {
+func SetAuthenticated(token, username string) {
 	authMutex.Lock()
 	defer authMutex.Unlock()
-	authenticatedUsers[token] = true
+	authenticatedUsers[token] = username
 }
 
 // RemoveAuthenticated 移除用户认证状态
@@ -102,5 +101,6 @@ func RemoveAuthenticated(token string) {
 func isAuthenticated(token string) bool {
 	authMutex.RLock()
 	defer authMutex.RUnlock()
-	return authenticatedUsers[token]
+	_, ok := authenticatedUsers[token]
+	return ok
 }
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Definition 自定义日志格式定义，Grok 和 Regex 任选其一
+type Definition struct {
+	Name            string            // 格式名称
+	Grok            string            // grok风格模式，如 %{TIMESTAMP_ISO8601:time} %{LOGLEVEL:level} %{GREEDYDATA:msg}
+	Regex           string            // Go正则表达式，使用命名捕获组 (?P<name>...)
+	TimestampField  string            // 时间字段名
+	TimestampLayout string            // 时间格式（Go layout，或 unix、unix_ms），为空时自动识别
+	LevelField      string            // 级别字段名，默认 level
+	MessageField    string            // 消息字段名，默认 message
+	Patterns        map[string]string // 额外的grok模式
+}
+
+// Explainer 可以给出解析失败原因的解析器
+type Explainer interface {
+	Explain(line string) (*Record, error)
+}
+
+// regexParser 基于命名捕获组的自定义格式解析器
+type regexParser struct {
+	def        Definition
+	re         *regexp.Regexp
+	fieldNames map[string]string
+}
+
+// NewCustomParser 根据自定义格式定义创建解析器
+func NewCustomParser(def Definition) (Parser, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("自定义格式缺少名称")
+	}
+
+	p := &regexParser{def: def, fieldNames: make(map[string]string)}
+	switch {
+	case def.Grok != "":
+		re, fieldNames, err := CompileGrok(def.Grok, def.Patterns)
+		if err != nil {
+			return nil, fmt.Errorf("格式 %s: %w", def.Name, err)
+		}
+		p.re = re
+		p.fieldNames = fieldNames
+	case def.Regex != "":
+		re, err := regexp.Compile(def.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("格式 %s 正则编译失败: %w", def.Name, err)
+		}
+		p.re = re
+	default:
+		return nil, fmt.Errorf("格式 %s 需要设置 grok 或 regex", def.Name)
+	}
+
+	hasNamedGroup := false
+	for _, name := range p.re.SubexpNames() {
+		if name != "" {
+			hasNamedGroup = true
+			if _, ok := p.fieldNames[name]; !ok {
+				p.fieldNames[name] = name
+			}
+		}
+	}
+	if !hasNamedGroup {
+		return nil, fmt.Errorf("格式 %s 没有任何命名字段", def.Name)
+	}
+	if p.def.TimestampField != "" && !p.hasField(p.def.TimestampField) {
+		return nil, fmt.Errorf("格式 %s 中不存在时间字段 %s", def.Name, def.TimestampField)
+	}
+
+	return p, nil
+}
+
+// hasField 检查模式中是否定义了指定字段
+func (p *regexParser) hasField(field string) bool {
+	for _, name := range p.fieldNames {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *regexParser) Name() string { return p.def.Name }
+
+func (p *regexParser) Parse(line string) (*Record, bool) {
+	record, err := p.Explain(line)
+	return record, err == nil
+}
+
+// Explain 解析一行并返回失败原因
+func (p *regexParser) Explain(line string) (*Record, error) {
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("行与格式 %s 不匹配", p.def.Name)
+	}
+
+	fields := make(map[string]string)
+	for i, group := range p.re.SubexpNames() {
+		if group == "" || i >= len(m) {
+			continue
+		}
+		fields[p.fieldNames[group]] = m[i]
+	}
+
+	record := &Record{Fields: fields}
+
+	if p.def.TimestampField != "" {
+		value := fields[p.def.TimestampField]
+		t, ok := ParseTimeLayout(value, p.def.TimestampLayout)
+		if !ok {
+			return nil, fmt.Errorf("时间字段 %s 的值 %q 无法按格式 %q 解析", p.def.TimestampField, value, p.def.TimestampLayout)
+		}
+		record.SetTime(t)
+		delete(fields, p.def.TimestampField)
+	}
+
+	levelField := p.def.LevelField
+	if levelField == "" {
+		levelField = "level"
+	}
+	if value, ok := fields[levelField]; ok {
+		record.Level = NormalizeLevel(value)
+		delete(fields, levelField)
+	}
+
+	messageField := p.def.MessageField
+	if messageField == "" {
+		messageField = "message"
+	}
+	if value, ok := fields[messageField]; ok {
+		record.Message = value
+		delete(fields, messageField)
+	} else if value, ok := fields["msg"]; ok {
+		record.Message = value
+		delete(fields, "msg")
+	} else {
+		record.Message = line
+	}
+
+	if len(fields) == 0 {
+		record.Fields = nil
+	}
+	return record, nil
+}
+
+// customNames 当前已注册的自定义格式名称，用于重新加载时移除旧格式
+var customNames []string
+
+// RegisterCustom 注册自定义格式，替换上一次注册的所有自定义格式
+// 定义有误的格式会被跳过，错误一并返回
+func RegisterCustom(defs []Definition) []error {
+	registryMu.Lock()
+	for _, name := range customNames {
+		delete(registry, name)
+	}
+	customNames = nil
+	registryMu.Unlock()
+
+	var errs []error
+	for _, def := range defs {
+		if isBuiltin(def.Name) {
+			errs = append(errs, fmt.Errorf("格式名称 %s 与内置格式冲突", def.Name))
+			continue
+		}
+		p, err := NewCustomParser(def)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		Register(p)
+		registryMu.Lock()
+		customNames = append(customNames, def.Name)
+		registryMu.Unlock()
+	}
+	return errs
+}
+
+// isBuiltin 是否为内置格式名称
+func isBuiltin(name string) bool {
+	switch name {
+	case NameAuto, NameText, NameJSON, NameLogfmt, NameNginx, NameSyslog, NameApacheError:
+		return true
+	}
+	return false
+}
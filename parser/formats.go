@@ -0,0 +1,404 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 结构化日志中常见的时间、级别、消息字段名
+var (
+	timeKeys    = []string{"time", "timestamp", "ts", "@timestamp", "datetime", "date", "t"}
+	levelKeys   = []string{"level", "lvl", "severity", "log.level", "loglevel", "levelname"}
+	messageKeys = []string{"msg", "message", "log", "text", "event"}
+)
+
+// recordFromFields 从键值字段构造记录，识别时间、级别和消息字段
+func recordFromFields(fields map[string]string) *Record {
+	record := &Record{Fields: fields}
+
+	for _, key := range timeKeys {
+		if value, ok := fields[key]; ok {
+			if t, ok := ParseTime(value); ok {
+				record.SetTime(t)
+				delete(fields, key)
+				break
+			}
+		}
+	}
+	for _, key := range levelKeys {
+		if value, ok := fields[key]; ok {
+			if level := NormalizeLevel(value); level != "" {
+				record.Level = level
+				delete(fields, key)
+				break
+			}
+		}
+	}
+	for _, key := range messageKeys {
+		if value, ok := fields[key]; ok {
+			record.Message = value
+			delete(fields, key)
+			break
+		}
+	}
+
+	if len(fields) == 0 {
+		record.Fields = nil
+	}
+	return record
+}
+
+// textParser 纯文本解析器，识别行首时间戳和日志级别，整行作为消息
+type textParser struct{}
+
+func (textParser) Name() string { return NameText }
+
+func (textParser) Parse(line string) (*Record, bool) {
+	record := &Record{
+		Message: line,
+		Level:   DetectLevel(line),
+	}
+	if t, ok := DetectTimestamp(line); ok {
+		record.SetTime(t)
+	}
+	return record, true
+}
+
+// jsonParser 每行一个JSON对象的解析器
+type jsonParser struct{}
+
+func (jsonParser) Name() string { return NameJSON }
+
+func (jsonParser) Parse(line string) (*Record, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		return nil, false
+	}
+
+	fields := make(map[string]string)
+	flattenJSON("", obj, fields)
+	return recordFromFields(fields), true
+}
+
+// flattenJSON 将嵌套的JSON对象展开为以点分隔的字段
+func flattenJSON(prefix string, obj map[string]interface{}, fields map[string]string) {
+	for key, value := range obj {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenJSON(name, v, fields)
+		case string:
+			fields[name] = v
+		case nil:
+			fields[name] = ""
+		case float64:
+			fields[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			data, _ := json.Marshal(v)
+			fields[name] = string(data)
+		}
+	}
+}
+
+// logfmtParser key=value 格式解析器
+type logfmtParser struct{}
+
+func (logfmtParser) Name() string { return NameLogfmt }
+
+func (logfmtParser) Parse(line string) (*Record, bool) {
+	fields, plain := parseLogfmt(line)
+	// 大部分内容都应是键值对，避免把普通文本误识别为logfmt
+	if len(fields) < 2 || plain > len(fields) {
+		return nil, false
+	}
+	return recordFromFields(fields), true
+}
+
+// parseLogfmt 解析键值对，返回字段和非键值对的token数量
+func parseLogfmt(line string) (map[string]string, int) {
+	fields := make(map[string]string)
+	plain := 0
+
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if i >= len(line) || line[i] == ' ' || key == "" {
+			plain++
+			continue
+		}
+		i++ // 跳过 '='
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			i++
+			var sb strings.Builder
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				sb.WriteByte(line[i])
+				i++
+			}
+			i++ // 跳过结尾引号
+			value = sb.String()
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			value = line[start:i]
+		}
+		fields[key] = value
+	}
+
+	return fields, plain
+}
+
+// nginxCombinedRegex nginx combined 格式，末尾允许附加自定义字段
+var nginxCombinedRegex = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-) "([^"]*)" "([^"]*)"(.*)$`)
+
+// nginxParser nginx combined 访问日志解析器
+type nginxParser struct{}
+
+func (nginxParser) Name() string { return NameNginx }
+
+func (nginxParser) Parse(line string) (*Record, bool) {
+	m := nginxCombinedRegex.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	fields := map[string]string{
+		"remote_addr":     m[1],
+		"remote_user":     m[2],
+		"request":         m[4],
+		"status":          m[5],
+		"body_bytes_sent": m[6],
+		"http_referer":    m[7],
+		"http_user_agent": m[8],
+	}
+	if parts := strings.SplitN(m[4], " ", 3); len(parts) == 3 {
+		fields["method"] = parts[0]
+		fields["path"] = parts[1]
+		fields["protocol"] = parts[2]
+	}
+	parseNginxExtra(strings.TrimSpace(m[9]), fields)
+
+	record := &Record{
+		Message: m[4],
+		Fields:  fields,
+	}
+	if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[3]); err == nil {
+		record.SetTime(t)
+	}
+
+	status, _ := strconv.Atoi(m[5])
+	switch {
+	case status >= 500:
+		record.Level = "ERROR"
+	case status >= 400:
+		record.Level = "WARN"
+	default:
+		record.Level = "INFO"
+	}
+	return record, true
+}
+
+// parseNginxExtra 解析combined格式之后附加的字段
+// 支持 key=value 形式；第一个带引号的值视为 http_x_forwarded_for，数字依次视为 request_time、upstream_response_time
+func parseNginxExtra(extra string, fields map[string]string) {
+	if extra == "" {
+		return
+	}
+
+	numericNames := []string{"request_time", "upstream_response_time"}
+	for _, token := range splitQuoted(extra) {
+		switch {
+		case strings.HasPrefix(token, `"`):
+			if _, ok := fields["http_x_forwarded_for"]; !ok {
+				fields["http_x_forwarded_for"] = strings.Trim(token, `"`)
+			}
+		case strings.Contains(token, "="):
+			kv := strings.SplitN(token, "=", 2)
+			fields[kv[0]] = strings.Trim(kv[1], `"`)
+		default:
+			if _, err := strconv.ParseFloat(token, 64); err == nil && len(numericNames) > 0 {
+				fields[numericNames[0]] = token
+				numericNames = numericNames[1:]
+			}
+		}
+	}
+}
+
+// splitQuoted 按空格分割，保留双引号内的空格
+func splitQuoted(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == '"' {
+			inQuotes = !inQuotes
+		}
+		if ch == ' ' && !inQuotes {
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteByte(ch)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// syslog 格式：RFC3164 和 RFC5424
+var (
+	syslog3164Regex = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^:\[\s]+)(?:\[(\d+)\])?: ?(.*)$`)
+	syslog5424Regex = regexp.MustCompile(`^<(\d{1,3})>1 (\S+) (\S+) (\S+) (\S+) (\S+) (-|\[.*?\]) ?(.*)$`)
+)
+
+// syslogSeverityLevels syslog severity 对应的日志级别
+var syslogSeverityLevels = []string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+
+// syslogParser syslog 解析器
+type syslogParser struct{}
+
+func (syslogParser) Name() string { return NameSyslog }
+
+func (syslogParser) Parse(line string) (*Record, bool) {
+	if m := syslog5424Regex.FindStringSubmatch(line); m != nil {
+		fields := map[string]string{
+			"host":    m[3],
+			"app":     m[4],
+			"pid":     m[5],
+			"msgid":   m[6],
+			"sd":      m[7],
+			"program": m[4],
+		}
+		record := &Record{Message: m[8], Fields: fields}
+		if t, ok := ParseTime(m[2]); ok {
+			record.SetTime(t)
+		}
+		applySyslogPriority(record, m[1])
+		return record, true
+	}
+
+	if m := syslog3164Regex.FindStringSubmatch(line); m != nil {
+		fields := map[string]string{
+			"host":    m[3],
+			"program": m[4],
+		}
+		if m[5] != "" {
+			fields["pid"] = m[5]
+		}
+		record := &Record{Message: m[6], Fields: fields}
+		if t, ok := parseSyslogTime(m[2]); ok {
+			record.SetTime(t)
+		}
+		if m[1] != "" {
+			applySyslogPriority(record, m[1])
+		} else {
+			record.Level = DetectLevel(m[6])
+		}
+		return record, true
+	}
+
+	return nil, false
+}
+
+// applySyslogPriority 根据PRI计算facility和severity
+func applySyslogPriority(record *Record, pri string) {
+	value, err := strconv.Atoi(pri)
+	if err != nil {
+		return
+	}
+	severity := value % 8
+	record.Fields["facility"] = strconv.Itoa(value / 8)
+	record.Fields["severity"] = strconv.Itoa(severity)
+	record.Level = syslogSeverityLevels[severity]
+}
+
+// parseSyslogTime 解析RFC3164时间，格式中没有年份，取不晚于当前时间的最近年份
+func parseSyslogTime(value string) (time.Time, bool) {
+	t, err := time.ParseInLocation(time.Stamp, value, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	now := time.Now()
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, true
+}
+
+// apacheErrorRegex Apache error log 格式（2.2 和 2.4）
+var apacheErrorRegex = regexp.MustCompile(`^\[([^\]]+)\] \[(?:([\w-]+):)?(\w+)\] (?:\[pid (\d+)(?::tid (\d+))?\] )?(?:\[client ([^\]]+)\] )?(.*)$`)
+
+// apacheErrorParser Apache error log 解析器
+type apacheErrorParser struct{}
+
+func (apacheErrorParser) Name() string { return NameApacheError }
+
+func (apacheErrorParser) Parse(line string) (*Record, bool) {
+	m := apacheErrorRegex.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	t, ok := ParseTime(m[1])
+	if !ok {
+		return nil, false
+	}
+
+	fields := make(map[string]string)
+	if m[2] != "" {
+		fields["module"] = m[2]
+	}
+	if m[4] != "" {
+		fields["pid"] = m[4]
+	}
+	if m[5] != "" {
+		fields["tid"] = m[5]
+	}
+	if m[6] != "" {
+		fields["client"] = m[6]
+	}
+
+	record := &Record{
+		Message: m[7],
+		Level:   NormalizeLevel(m[3]),
+		Fields:  fields,
+	}
+	if record.Level == "" && strings.HasPrefix(m[3], "trace") {
+		record.Level = "TRACE"
+	}
+	record.SetTime(t)
+	return record, true
+}
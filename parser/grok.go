@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// grokPatterns 内置的grok模式库
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"NUMBER":            `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"QS":                `%{QUOTEDSTRING}`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"PATH":              `(?:/[^\s]*)+`,
+	"URIPATH":           `/[^\s?#]*`,
+	"URI":               `\S+`,
+	"LOGLEVEL":          `(?:[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Aa]lert|ALERT|[Ee]merg(?:ency)?|EMERG(?:ENCY)?)`,
+	"YEAR":              `\d{4}`,
+	"MONTHNUM":          `(?:0[1-9]|1[0-2]|[1-9])`,
+	"MONTHDAY":          `(?:0[1-9]|[12][0-9]|3[01]|[1-9])`,
+	"MONTH":             `\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)[a-z]*\b`,
+	"DAY":               `\b(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun)[a-z]*\b`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}:%{SECOND}`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+}
+
+// grokRefRegex 匹配 %{PATTERN} 、%{PATTERN:field} 和 %{PATTERN:field:type}
+var grokRefRegex = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::\w+)?\}`)
+
+// groupNameRegex 捕获组名称中不允许的字符
+var groupNameRegex = regexp.MustCompile(`[^\w]`)
+
+// maxGrokDepth 模式展开的最大嵌套层数，防止循环引用
+const maxGrokDepth = 16
+
+// CompileGrok 将grok模式展开为Go正则表达式
+// extra 为额外的自定义模式，与内置模式同名时覆盖内置模式
+// 返回的映射记录捕获组名称到原始字段名的对应关系
+func CompileGrok(pattern string, extra map[string]string) (*regexp.Regexp, map[string]string, error) {
+	fieldNames := make(map[string]string)
+	expanded, err := expandGrok(pattern, extra, fieldNames, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("grok模式编译失败: %w", err)
+	}
+	return re, fieldNames, nil
+}
+
+// expandGrok 递归展开grok模式引用
+func expandGrok(pattern string, extra map[string]string, fieldNames map[string]string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", fmt.Errorf("grok模式嵌套过深，可能存在循环引用")
+	}
+
+	var expandErr error
+	result := grokRefRegex.ReplaceAllStringFunc(pattern, func(ref string) string {
+		if expandErr != nil {
+			return ""
+		}
+		m := grokRefRegex.FindStringSubmatch(ref)
+		name, field := m[1], m[2]
+
+		definition, ok := extra[name]
+		if !ok {
+			definition, ok = grokPatterns[name]
+		}
+		if !ok {
+			expandErr = fmt.Errorf("未知的grok模式: %s", name)
+			return ""
+		}
+
+		// 引用的模式只展开其内部引用，不保留其中的字段名
+		inner, err := expandGrok(definition, extra, nil, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+
+		if field == "" || fieldNames == nil {
+			return "(?:" + inner + ")"
+		}
+		group := groupNameRegex.ReplaceAllString(field, "_")
+		fieldNames[group] = field
+		return "(?P<" + group + ">" + inner + ")"
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return result, nil
+}
+
+// GrokPatternNames 返回内置grok模式名称
+func GrokPatternNames() []string {
+	names := make([]string, 0, len(grokPatterns))
+	for name := range grokPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileGrok(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		extra   map[string]string
+		line    string
+		want    map[string]string // 字段名 -> 值，nil 表示不匹配
+	}{
+		{
+			name:    "basic",
+			pattern: `%{TIMESTAMP_ISO8601:time} %{LOGLEVEL:level} %{GREEDYDATA:msg}`,
+			line:    "2026-10-19 08:00:00 ERROR disk full",
+			want:    map[string]string{"time": "2026-10-19 08:00:00", "level": "ERROR", "msg": "disk full"},
+		},
+		{
+			name:    "nested references keep only outer field",
+			pattern: `%{IPORHOST:client} %{USER:user}`,
+			line:    "10.0.0.1 alice",
+			want:    map[string]string{"client": "10.0.0.1", "user": "alice"},
+		},
+		{
+			name:    "dotted field names",
+			pattern: `%{INT:http.status} %{NUMBER:req-time:float}`,
+			line:    "502 0.25",
+			want:    map[string]string{"http.status": "502", "req-time": "0.25"},
+		},
+		{
+			name:    "extra pattern overrides builtin",
+			pattern: `%{WORD:id}`,
+			extra:   map[string]string{"WORD": `[A-Z]{3}\d+`},
+			line:    "ord ORD12",
+			want:    map[string]string{"id": "ORD12"},
+		},
+		{
+			name:    "extra pattern references builtin",
+			pattern: `%{ORDER:order}`,
+			extra:   map[string]string{"ORDER": `ORD-%{INT}`},
+			line:    "ORD-42",
+			want:    map[string]string{"order": "ORD-42"},
+		},
+		{
+			name:    "no match",
+			pattern: `^%{INT:n}$`,
+			line:    "abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, names, err := CompileGrok(tt.pattern, tt.extra)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := re.FindStringSubmatch(tt.line)
+			if tt.want == nil {
+				if m != nil {
+					t.Fatalf("unexpected match %q", m)
+				}
+				return
+			}
+			if m == nil {
+				t.Fatalf("%s did not match %q", re, tt.line)
+			}
+			got := make(map[string]string)
+			for i, group := range re.SubexpNames() {
+				if group != "" {
+					got[names[group]] = m[i]
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+			for field, value := range tt.want {
+				if got[field] != value {
+					t.Errorf("%s = %q, want %q", field, got[field], value)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileGrokErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		extra   map[string]string
+		want    string
+	}{
+		{`%{NOPE:x}`, nil, "未知的grok模式"},
+		{`%{A}`, map[string]string{"A": `%{B}`, "B": `%{A}`}, "嵌套过深"},
+		{`%{INT:n}(`, nil, "编译失败"},
+	}
+	for _, tt := range tests {
+		_, _, err := CompileGrok(tt.pattern, tt.extra)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileGrok(%q) error = %v, want %q", tt.pattern, err, tt.want)
+		}
+	}
+}
+
+func TestNewCustomParser(t *testing.T) {
+	tests := []struct {
+		def Definition
+		ok  bool
+	}{
+		{Definition{Name: "app", Grok: `%{LOGLEVEL:level} %{GREEDYDATA:message}`}, true},
+		{Definition{Name: "re", Regex: `(?P<level>\w+) (?P<message>.*)`}, true},
+		{Definition{Grok: `%{GREEDYDATA:message}`}, false},
+		{Definition{Name: "none"}, false},
+		{Definition{Name: "unnamed", Grok: `%{INT}`}, false},
+		{Definition{Name: "ts", Grok: `%{GREEDYDATA:message}`, TimestampField: "time"}, false},
+	}
+	for _, tt := range tests {
+		_, err := NewCustomParser(tt.def)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewCustomParser(%+v) error = %v", tt.def, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 标准日志级别，按严重程度从低到高排列
+var Levels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// 行内日志级别的识别规则，按顺序尝试
+var (
+	// level=error、lvl=warn、"level":"error"、severity: ERROR
+	levelKeyRegex = regexp.MustCompile(`(?i)"?\b(?:level|lvl|severity|loglevel)"?\s*[=:]\s*"?([a-z]+)`)
+	// <11> 形式的syslog优先级前缀
+	syslogPriRegex = regexp.MustCompile(`^<(\d{1,3})>`)
+	// [E]、[W]、[ERROR]、[error]、<warn> 等括号形式
+	levelBracketRegex = regexp.MustCompile(`[\[<(]([A-Za-z]{1,8})[\]>)]`)
+	// glog/klog 格式：E1018 12:00:00.000000
+	glogRegex = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}`)
+	// 大写的级别单词
+	levelWordRegex = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|FATAL|CRITICAL|PANIC)\b`)
+)
+
+// levelAbbreviations 单字母级别缩写
+var levelAbbreviations = map[string]string{
+	"T": "TRACE",
+	"D": "DEBUG",
+	"I": "INFO",
+	"W": "WARN",
+	"E": "ERROR",
+	"F": "FATAL",
+}
+
+// NormalizeLevel 将不同写法的日志级别统一为大写标准名称
+func NormalizeLevel(level string) string {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case "TRACE", "TRC":
+		return "TRACE"
+	case "DEBUG", "DBG":
+		return "DEBUG"
+	case "INFO", "INF", "NOTICE":
+		return "INFO"
+	case "WARN", "WARNING", "WRN":
+		return "WARN"
+	case "ERROR", "ERR":
+		return "ERROR"
+	case "FATAL", "CRITICAL", "CRIT", "PANIC", "ALERT", "EMERG", "EMERGENCY", "SEVERE":
+		return "FATAL"
+	}
+	return ""
+}
+
+// DetectLevel 从非结构化的日志行中识别日志级别
+// 支持 ERROR 单词、[E] 等括号缩写、level=error 键值、JSON level 字段、syslog优先级和glog前缀
+func DetectLevel(line string) string {
+	head := line
+	if len(head) > 256 {
+		head = head[:256]
+	}
+
+	if m := syslogPriRegex.FindStringSubmatch(head); m != nil {
+		if pri, err := strconv.Atoi(m[1]); err == nil {
+			return syslogSeverityLevels[pri%8]
+		}
+	}
+	if m := glogRegex.FindStringSubmatch(head); m != nil {
+		return levelAbbreviations[m[1]]
+	}
+	if m := levelKeyRegex.FindStringSubmatch(head); m != nil {
+		if level := NormalizeLevel(m[1]); level != "" {
+			return level
+		}
+	}
+	for _, m := range levelBracketRegex.FindAllStringSubmatch(head, -1) {
+		if level, ok := levelAbbreviations[m[1]]; ok {
+			return level
+		}
+		if level := NormalizeLevel(m[1]); level != "" {
+			return level
+		}
+	}
+	if match := levelWordRegex.FindString(head); match != "" {
+		return NormalizeLevel(match)
+	}
+	return ""
+}
+
+// LevelRank 返回级别的严重程度，未知级别返回-1
+func LevelRank(level string) int {
+	for i, name := range Levels {
+		if name == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseLevelFilter 解析级别过滤条件，返回允许的级别集合
+// 支持逗号分隔的级别列表（如 "warn,error"），以及 "warn+" 表示该级别及以上
+func ParseLevelFilter(spec string) (map[string]bool, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		andAbove := strings.HasSuffix(item, "+")
+		level := NormalizeLevel(strings.TrimSuffix(item, "+"))
+		if level == "" {
+			return nil, fmt.Errorf("未知的日志级别: %s", item)
+		}
+		if !andAbove {
+			allowed[level] = true
+			continue
+		}
+		for _, name := range Levels[LevelRank(level):] {
+			allowed[name] = true
+		}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDetectLevel(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"2026-10-19 08:00:00 ERROR connection refused", "ERROR"},
+		{"2026-10-19 08:00:00 [W] disk almost full", "WARN"},
+		{"[E] 08:00:00 failed", "ERROR"},
+		{"ts=2026-10-19 level=warning msg=slow", "WARN"},
+		{`{"level":"debug","msg":"x"}`, "DEBUG"},
+		{"<11>Oct 19 08:00:00 host app: failed", "ERROR"},
+		{"<14>Oct 19 08:00:00 host app: started", "INFO"},
+		{"E1019 08:00:00.000000 1 main.go:10] failed", "ERROR"},
+		{"[notice] server ready", "INFO"},
+		{"PANIC: runtime error", "FATAL"},
+		{"\tat com.example.Main.run(Main.java:10)", ""},
+		{"no level information here", ""},
+		// 单词中包含级别名称时不识别
+		{"terrorists and informants", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLevel(tt.line); got != tt.want {
+			t.Errorf("DetectLevel(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseLevelFilter(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"error", "ERROR", false},
+		{"warn,error", "ERROR,WARN", false},
+		{"warning+", "ERROR,FATAL,WARN", false},
+		{" info , fatal ", "FATAL,INFO", false},
+		{"verbose", "", true},
+		{"error,,", "ERROR", false},
+	}
+	for _, tt := range tests {
+		allowed, err := ParseLevelFilter(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevelFilter(%q) error = %v", tt.spec, err)
+			continue
+		}
+		var got []string
+		for level := range allowed {
+			got = append(got, level)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("ParseLevelFilter(%q) = %v, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 内置解析器名称
+const (
+	NameAuto        = "auto"
+	NameText        = "text"
+	NameJSON        = "json"
+	NameLogfmt      = "logfmt"
+	NameNginx       = "nginx"
+	NameSyslog      = "syslog"
+	NameApacheError = "apache_error"
+)
+
+// Record 解析后的日志记录
+type Record struct {
+	Time      time.Time         `json:"-"`                   // 解析出的时间
+	Timestamp string            `json:"timestamp,omitempty"` // 格式化后的时间
+	Level     string            `json:"level,omitempty"`     // 日志级别
+	Message   string            `json:"message"`             // 日志消息
+	Fields    map[string]string `json:"fields,omitempty"`    // 其他字段
+}
+
+// SetTime 设置记录时间，同时更新格式化后的时间
+func (r *Record) SetTime(t time.Time) {
+	r.Time = t
+	if t.IsZero() {
+		r.Timestamp = ""
+		return
+	}
+	r.Timestamp = t.Format("2006-01-02 15:04:05.000")
+}
+
+// Field 获取字段值，支持 timestamp、level、message 等内置字段
+func (r *Record) Field(name string) (string, bool) {
+	switch name {
+	case "timestamp", "ts", "time":
+		return r.Timestamp, r.Timestamp != ""
+	case "level":
+		return r.Level, r.Level != ""
+	case "message", "msg":
+		return r.Message, true
+	}
+	value, ok := r.Fields[name]
+	return value, ok
+}
+
+// Parser 日志格式解析器
+type Parser interface {
+	// Name 解析器名称
+	Name() string
+	// Parse 解析单行日志，无法解析时返回false
+	Parse(line string) (*Record, bool)
+}
+
+// 解析器注册表
+var (
+	registry   = make(map[string]Parser)
+	registryMu sync.RWMutex
+)
+
+// Register 注册解析器，同名解析器会被覆盖
+func Register(p Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[p.Name()] = p
+}
+
+// Get 按名称获取解析器
+func Get(name string) (Parser, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names 返回所有已注册的解析器名称
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup 获取解析器，名称为空或 auto 时返回nil，由调用方自动检测
+func Lookup(name string) (Parser, error) {
+	if name == "" || name == NameAuto {
+		return nil, nil
+	}
+	p, ok := Get(name)
+	if !ok {
+		return nil, fmt.Errorf("未知的日志格式: %s", name)
+	}
+	return p, nil
+}
+
+// detectCandidates 自动检测时依次尝试的解析器
+var detectCandidates = []string{NameJSON, NameNginx, NameSyslog, NameApacheError, NameLogfmt}
+
+// detectThreshold 自动检测时要求的最低解析成功率
+const detectThreshold = 0.6
+
+// Detect 根据样本行自动检测日志格式，无法识别时返回文本解析器
+func Detect(samples []string) Parser {
+	var nonEmpty []string
+	for _, line := range samples {
+		if line != "" {
+			nonEmpty = append(nonEmpty, line)
+		}
+	}
+
+	text, _ := Get(NameText)
+	if len(nonEmpty) == 0 {
+		return text
+	}
+
+	var best Parser
+	bestRate := 0.0
+	for _, name := range detectCandidates {
+		p, ok := Get(name)
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, line := range nonEmpty {
+			if _, ok := p.Parse(line); ok {
+				matched++
+			}
+		}
+		rate := float64(matched) / float64(len(nonEmpty))
+		if rate > bestRate {
+			best = p
+			bestRate = rate
+		}
+	}
+
+	if best == nil || bestRate < detectThreshold {
+		return text
+	}
+	return best
+}
+
+func init() {
+	Register(textParser{})
+	Register(jsonParser{})
+	Register(logfmtParser{})
+	Register(nginxParser{})
+	Register(syslogParser{})
+	Register(apacheErrorParser{})
+}
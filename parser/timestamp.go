@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lineTimeRegex 匹配行首附近常见的时间戳格式
+var lineTimeRegex = regexp.MustCompile(`\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?|\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`)
+
+// timeLayouts 支持的时间格式
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006/01/02 15:04:05.999999999",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.ANSIC,
+	"Mon Jan _2 15:04:05.999999 2006",
+}
+
+// DetectTimestamp 从日志行中提取时间戳，只在行的前部查找
+func DetectTimestamp(line string) (time.Time, bool) {
+	head := line
+	if len(head) > 64 {
+		head = head[:64]
+	}
+
+	match := lineTimeRegex.FindString(head)
+	if match == "" {
+		return time.Time{}, false
+	}
+	return ParseTime(match)
+}
+
+// FindTimestamp 查找行前部的时间戳，返回其在行中的起止位置和解析出的时间
+func FindTimestamp(line string) (start, end int, t time.Time, ok bool) {
+	head := line
+	if len(head) > 64 {
+		head = head[:64]
+	}
+
+	loc := lineTimeRegex.FindStringIndex(head)
+	if loc == nil {
+		return 0, 0, time.Time{}, false
+	}
+	t, ok = ParseTime(head[loc[0]:loc[1]])
+	return loc[0], loc[1], t, ok
+}
+
+// ParseTime 按常见格式解析时间字符串，纯数字按Unix时间戳（秒/毫秒/微秒/纳秒）处理
+func ParseTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if isDigits(value) || isDecimal(value) {
+		return parseEpoch(value)
+	}
+
+	// 逗号分隔的毫秒（如 log4j 格式）统一为小数点
+	value = strings.Replace(value, ",", ".", 1)
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseTimeLayout 按指定格式解析时间，格式为空时使用 ParseTime 自动识别
+// 支持 unix、unix_ms 等特殊格式名称
+func ParseTimeLayout(value, layout string) (time.Time, bool) {
+	switch layout {
+	case "":
+		return ParseTime(value)
+	case "unix", "unix_ms", "unix_us", "unix_ns":
+		return parseEpoch(strings.TrimSpace(value))
+	}
+	t, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseEpoch 解析Unix时间戳，根据数值大小判断精度
+func parseEpoch(value string) (time.Time, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		return time.Time{}, false
+	}
+	switch {
+	case f > 1e17:
+		return time.Unix(0, int64(f)), true
+	case f > 1e14:
+		return time.UnixMicro(int64(f)), true
+	case f > 1e11:
+		return time.UnixMilli(int64(f)), true
+	default:
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+	}
+}
+
+// isDigits 判断字符串是否全为数字
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDecimal 判断字符串是否为带小数点的数字
+func isDecimal(s string) bool {
+	parts := strings.SplitN(s, ".", 2)
+	return len(parts) == 2 && isDigits(parts[0]) && isDigits(parts[1])
+}
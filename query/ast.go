@@ -0,0 +1,140 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr 表达式
+type Expr interface {
+	String() string
+}
+
+// fieldExpr 字段引用
+type fieldExpr struct {
+	name string
+}
+
+// literalExpr 字面量
+type literalExpr struct {
+	value Value
+	text  string
+}
+
+// binaryExpr 二元运算
+type binaryExpr struct {
+	op          string
+	left, right Expr
+}
+
+// unaryExpr 一元运算（NOT、负号）
+type unaryExpr struct {
+	op   string
+	expr Expr
+}
+
+// callExpr 函数调用
+type callExpr struct {
+	name string // 小写函数名
+	args []Expr
+	star bool // count(*)
+}
+
+// inExpr IN 列表判断
+type inExpr struct {
+	expr   Expr
+	list   []Expr
+	negate bool
+}
+
+// isNullExpr IS [NOT] NULL 判断
+type isNullExpr struct {
+	expr   Expr
+	negate bool
+}
+
+func (e *fieldExpr) String() string   { return e.name }
+func (e *literalExpr) String() string { return e.text }
+
+func (e *binaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.left, e.op, e.right)
+}
+
+func (e *unaryExpr) String() string {
+	if e.op == "NOT" {
+		return "NOT " + e.expr.String()
+	}
+	return e.op + e.expr.String()
+}
+
+func (e *callExpr) String() string {
+	if e.star {
+		return e.name + "(*)"
+	}
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.String()
+	}
+	return e.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (e *inExpr) String() string {
+	items := make([]string, len(e.list))
+	for i, item := range e.list {
+		items[i] = item.String()
+	}
+	op := " IN "
+	if e.negate {
+		op = " NOT IN "
+	}
+	return e.expr.String() + op + "(" + strings.Join(items, ", ") + ")"
+}
+
+func (e *isNullExpr) String() string {
+	if e.negate {
+		return e.expr.String() + " IS NOT NULL"
+	}
+	return e.expr.String() + " IS NULL"
+}
+
+// SelectItem 查询的输出列
+type SelectItem struct {
+	Expr  Expr
+	Alias string
+}
+
+// Name 输出列名称
+func (s SelectItem) Name() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Expr.String()
+}
+
+// OrderItem 排序条件
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// Query 解析后的查询语句
+type Query struct {
+	Star    bool         // SELECT *
+	Select  []SelectItem // 输出列
+	From    string       // 文件模式
+	Where   Expr         // 过滤条件
+	GroupBy []Expr       // 分组表达式
+	OrderBy []OrderItem  // 排序条件
+	Limit   int          // 返回行数上限，0 表示不限制
+}
+
+// quoteString 将字符串格式化为查询语言中的字面量
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// formatNumber 格式化数字字面量
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
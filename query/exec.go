@@ -0,0 +1,737 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Row 一条可被查询的日志记录
+type Row interface {
+	// Field 返回字段值，字段不存在时 ok 为 false
+	Field(name string) (Value, bool)
+}
+
+// Source 查询的数据来源
+type Source interface {
+	// Files 返回匹配 FROM 文件模式的文件列表
+	Files(pattern string) ([]string, error)
+	// Scan 依次读取文件中的记录，fn 返回 false 时停止读取
+	Scan(ctx context.Context, file string, fn func(Row) bool) error
+}
+
+// StarColumns SELECT * 输出的列
+var StarColumns = []string{"file", "line", "ts", "level", "message"}
+
+// Limits 查询执行限制
+type Limits struct {
+	MaxScanRows   int64         // 最多扫描的记录数，超出后停止扫描并返回部分结果
+	MaxGroups     int           // 最多的分组数，超出后查询失败
+	MaxResultRows int           // 最多返回的行数
+	Timeout       time.Duration // 执行超时，超时后返回部分结果
+}
+
+// Stats 查询执行统计
+type Stats struct {
+	Files     int    `json:"files"`            // 扫描的文件数
+	Scanned   int64  `json:"scanned"`          // 扫描的记录数
+	Matched   int64  `json:"matched"`          // 满足 WHERE 条件的记录数
+	Groups    int    `json:"groups"`           // 分组数
+	ElapsedMs int64  `json:"elapsed_ms"`       // 执行耗时
+	Truncated bool   `json:"truncated"`        // 结果是否因执行限制而不完整
+	Reason    string `json:"reason,omitempty"` // 结果不完整的原因
+}
+
+// Result 表格形式的查询结果
+type Result struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	Stats   Stats           `json:"stats"`
+}
+
+// orderKey 解析后的排序条件，column 为输出列下标，-1 表示需要单独求值
+type orderKey struct {
+	column int
+	expr   Expr
+	desc   bool
+}
+
+// group 聚合查询的一个分组
+type group struct {
+	keys map[string]Value // 分组表达式 -> 值
+	aggs []aggregator     // 与 executor.aggCalls 一一对应
+}
+
+// outputRow 待排序的结果行
+type outputRow struct {
+	values []Value
+	keys   []Value
+}
+
+// executor 单次查询的执行状态
+type executor struct {
+	query    *Query
+	limits   Limits
+	now      time.Time
+	regexps  map[string]*regexp.Regexp
+	aggCalls []*callExpr
+	aggIndex map[string]int
+	order    []orderKey
+	stats    Stats
+}
+
+// Execute 流式执行查询，逐条读取记录，只在内存中保留分组状态和结果行
+func Execute(ctx context.Context, q *Query, src Source, limits Limits) (*Result, error) {
+	start := time.Now()
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	e := &executor{
+		query:    q,
+		limits:   limits,
+		now:      start,
+		regexps:  make(map[string]*regexp.Regexp),
+		aggIndex: make(map[string]int),
+	}
+	columns := e.columns()
+	if err := e.plan(); err != nil {
+		return nil, err
+	}
+
+	files, err := src.Files(q.From)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("没有匹配 %s 的日志文件", q.From)
+	}
+	e.stats.Files = len(files)
+
+	var rows []outputRow
+	if q.grouped() {
+		rows, err = e.runGrouped(ctx, src, files)
+	} else {
+		rows, err = e.runRows(ctx, src, files)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Columns: columns, Rows: make([][]interface{}, 0, len(rows))}
+	for _, row := range rows {
+		values := make([]interface{}, len(row.values))
+		for i, v := range row.values {
+			values[i] = exportValue(v)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	e.stats.ElapsedMs = time.Since(start).Milliseconds()
+	result.Stats = e.stats
+	return result, nil
+}
+
+// columns 输出列名称
+func (e *executor) columns() []string {
+	if e.query.Star {
+		return append([]string(nil), StarColumns...)
+	}
+	names := make([]string, len(e.query.Select))
+	for i, item := range e.query.Select {
+		names[i] = item.Name()
+	}
+	return names
+}
+
+// selectExprs 输出列对应的表达式
+func (e *executor) selectExprs() []Expr {
+	if e.query.Star {
+		exprs := make([]Expr, len(StarColumns))
+		for i, name := range StarColumns {
+			exprs[i] = &fieldExpr{name: name}
+		}
+		return exprs
+	}
+	exprs := make([]Expr, len(e.query.Select))
+	for i, item := range e.query.Select {
+		exprs[i] = item.Expr
+	}
+	return exprs
+}
+
+// plan 收集聚合函数并解析排序条件
+func (e *executor) plan() error {
+	collect := func(expr Expr) {
+		walk(expr, func(x Expr) bool {
+			if !isAggregate(x) {
+				return true
+			}
+			key := x.String()
+			if _, ok := e.aggIndex[key]; !ok {
+				e.aggIndex[key] = len(e.aggCalls)
+				e.aggCalls = append(e.aggCalls, x.(*callExpr))
+			}
+			return false
+		})
+	}
+
+	exprs := e.selectExprs()
+	names := e.columns()
+	for _, expr := range exprs {
+		collect(expr)
+	}
+
+	groupKeys := make(map[string]bool)
+	for _, expr := range e.query.GroupBy {
+		groupKeys[expr.String()] = true
+	}
+
+	for _, item := range e.query.OrderBy {
+		key := orderKey{column: -1, expr: item.Expr, desc: item.Desc}
+		if lit, ok := item.Expr.(*literalExpr); ok {
+			n, isNum := lit.value.(float64)
+			if !isNum || n != float64(int(n)) || int(n) < 1 || int(n) > len(exprs) {
+				return fmt.Errorf("ORDER BY 位置 %s 超出列的范围", lit.text)
+			}
+			key.column = int(n) - 1
+		} else {
+			for i := range exprs {
+				if item.Expr.String() == exprs[i].String() {
+					key.column = i
+					break
+				}
+				if f, ok := item.Expr.(*fieldExpr); ok && f.name == names[i] {
+					key.column = i
+					break
+				}
+			}
+		}
+		if key.column < 0 {
+			if e.query.grouped() {
+				if field := ungroupedField(item.Expr, groupKeys); field != "" {
+					return fmt.Errorf("ORDER BY 中的字段 %s 需要出现在 GROUP BY 中或放在聚合函数内", field)
+				}
+			}
+			collect(item.Expr)
+		}
+		e.order = append(e.order, key)
+	}
+	return nil
+}
+
+// resultLimit 返回的最大行数
+func (e *executor) resultLimit() int {
+	limit := e.query.Limit
+	if e.limits.MaxResultRows > 0 && (limit == 0 || limit > e.limits.MaxResultRows) {
+		limit = e.limits.MaxResultRows
+	}
+	return limit
+}
+
+// scan 读取所有文件，对满足 WHERE 条件的记录调用 fn，fn 返回 false 时停止
+func (e *executor) scan(ctx context.Context, src Source, files []string, fn func(Row) (bool, error)) error {
+	var fnErr error
+	stopped := false
+	for _, file := range files {
+		err := src.Scan(ctx, file, func(row Row) bool {
+			if e.stats.Scanned%1024 == 0 && ctx.Err() != nil {
+				e.truncate("执行超时")
+				stopped = true
+				return false
+			}
+			if e.limits.MaxScanRows > 0 && e.stats.Scanned >= e.limits.MaxScanRows {
+				e.truncate(fmt.Sprintf("扫描记录数超过上限 %d", e.limits.MaxScanRows))
+				stopped = true
+				return false
+			}
+			e.stats.Scanned++
+
+			if e.query.Where != nil {
+				v, err := e.eval(e.query.Where, row, nil)
+				if err != nil {
+					fnErr = err
+					stopped = true
+					return false
+				}
+				if !truthy(v) {
+					return true
+				}
+			}
+			e.stats.Matched++
+
+			cont, err := fn(row)
+			if err != nil {
+				fnErr = err
+			}
+			if !cont || err != nil {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		if err != nil {
+			fmt.Printf("查询读取文件失败 %s: %v\n", file, err)
+		}
+		if stopped {
+			break
+		}
+	}
+	return nil
+}
+
+// truncate 标记结果因执行限制而不完整
+func (e *executor) truncate(reason string) {
+	e.stats.Truncated = true
+	if e.stats.Reason == "" {
+		e.stats.Reason = reason
+	}
+}
+
+// runRows 执行非聚合查询
+func (e *executor) runRows(ctx context.Context, src Source, files []string) ([]outputRow, error) {
+	exprs := e.selectExprs()
+	limit := e.resultLimit()
+	// 行数上限来自执行限制而不是查询的 LIMIT 时，结果被截断需要标记，无排序时多读一行判断是否还有结果
+	capped := limit > 0 && (e.query.Limit == 0 || limit < e.query.Limit)
+	var rows []outputRow
+
+	err := e.scan(ctx, src, files, func(row Row) (bool, error) {
+		out := outputRow{values: make([]Value, len(exprs))}
+		for i, expr := range exprs {
+			v, err := e.eval(expr, row, nil)
+			if err != nil {
+				return false, err
+			}
+			out.values[i] = v
+		}
+		keys, err := e.orderValues(out.values, row, nil)
+		if err != nil {
+			return false, err
+		}
+		out.keys = keys
+		rows = append(rows, out)
+
+		if len(e.order) == 0 {
+			// 无排序时读到足够的行即可停止
+			if capped {
+				return len(rows) <= limit, nil
+			}
+			return limit == 0 || len(rows) < limit, nil
+		}
+		// 有排序时定期裁剪，只保留前 limit 行
+		if limit > 0 && len(rows) >= 2*limit {
+			e.sortRows(rows)
+			rows = rows[:limit]
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.sortRows(rows)
+	if capped && e.stats.Matched > int64(limit) {
+		e.truncate(fmt.Sprintf("结果行数超过上限 %d", limit))
+	}
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+// runGrouped 执行聚合查询
+func (e *executor) runGrouped(ctx context.Context, src Source, files []string) ([]outputRow, error) {
+	groups := make(map[string]*group)
+	var ordered []*group
+
+	newGroup := func(keys map[string]Value) *group {
+		g := &group{keys: keys, aggs: make([]aggregator, len(e.aggCalls))}
+		for i, call := range e.aggCalls {
+			g.aggs[i] = aggregates[call.name]()
+		}
+		return g
+	}
+
+	err := e.scan(ctx, src, files, func(row Row) (bool, error) {
+		keys := make(map[string]Value, len(e.query.GroupBy))
+		parts := make([]string, len(e.query.GroupBy))
+		for i, expr := range e.query.GroupBy {
+			v, err := e.eval(expr, row, nil)
+			if err != nil {
+				return false, err
+			}
+			keys[expr.String()] = v
+			parts[i] = toString(v)
+		}
+		id := strings.Join(parts, "\x1f")
+
+		g, ok := groups[id]
+		if !ok {
+			if e.limits.MaxGroups > 0 && len(groups) >= e.limits.MaxGroups {
+				return false, fmt.Errorf("分组数超过上限 %d，请缩小查询范围或减少分组字段", e.limits.MaxGroups)
+			}
+			g = newGroup(keys)
+			groups[id] = g
+			ordered = append(ordered, g)
+		}
+
+		for i, call := range e.aggCalls {
+			if call.star {
+				g.aggs[i].add(true)
+				continue
+			}
+			v, err := e.eval(call.args[0], row, nil)
+			if err != nil {
+				return false, err
+			}
+			g.aggs[i].add(v)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有 GROUP BY 的聚合查询总是返回一行
+	if len(e.query.GroupBy) == 0 && len(ordered) == 0 {
+		ordered = append(ordered, newGroup(map[string]Value{}))
+	}
+	e.stats.Groups = len(ordered)
+
+	exprs := e.selectExprs()
+	rows := make([]outputRow, 0, len(ordered))
+	for _, g := range ordered {
+		out := outputRow{values: make([]Value, len(exprs))}
+		for i, expr := range exprs {
+			v, err := e.eval(expr, nil, g)
+			if err != nil {
+				return nil, err
+			}
+			out.values[i] = v
+		}
+		keys, err := e.orderValues(out.values, nil, g)
+		if err != nil {
+			return nil, err
+		}
+		out.keys = keys
+		rows = append(rows, out)
+	}
+
+	e.sortRows(rows)
+	if limit := e.resultLimit(); limit > 0 && len(rows) > limit {
+		if e.query.Limit == 0 || limit < e.query.Limit {
+			e.truncate(fmt.Sprintf("结果行数超过上限 %d", limit))
+		}
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+// orderValues 计算一行的排序键
+func (e *executor) orderValues(values []Value, row Row, g *group) ([]Value, error) {
+	if len(e.order) == 0 {
+		return nil, nil
+	}
+	keys := make([]Value, len(e.order))
+	for i, key := range e.order {
+		if key.column >= 0 {
+			keys[i] = values[key.column]
+			continue
+		}
+		v, err := e.eval(key.expr, row, g)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = v
+	}
+	return keys, nil
+}
+
+// sortRows 按排序条件稳定排序，空值排在最前（降序时排在最后）
+func (e *executor) sortRows(rows []outputRow) {
+	if len(e.order) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k, key := range e.order {
+			c := compareForSort(rows[i].keys[k], rows[j].keys[k])
+			if c == 0 {
+				continue
+			}
+			if key.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+func compareForSort(a, b Value) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := compare(a, b)
+	return c
+}
+
+// regexpFor 编译并缓存正则表达式
+func (e *executor) regexpFor(key string, compile func() (*regexp.Regexp, error)) (*regexp.Regexp, error) {
+	if re, ok := e.regexps[key]; ok {
+		return re, nil
+	}
+	re, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	e.regexps[key] = re
+	return re, nil
+}
+
+// eval 对表达式求值，g 不为空时在分组上下文中求值
+func (e *executor) eval(expr Expr, row Row, g *group) (Value, error) {
+	if g != nil {
+		if v, ok := g.keys[expr.String()]; ok {
+			return v, nil
+		}
+	}
+
+	switch x := expr.(type) {
+	case *literalExpr:
+		return x.value, nil
+
+	case *fieldExpr:
+		if row == nil {
+			return nil, fmt.Errorf("字段 %s 需要出现在 GROUP BY 中或放在聚合函数内", x.name)
+		}
+		v, ok := row.Field(x.name)
+		if !ok {
+			return nil, nil
+		}
+		return v, nil
+
+	case *callExpr:
+		if _, ok := aggregates[x.name]; ok {
+			if g == nil {
+				return nil, fmt.Errorf("聚合函数 %s 只能用于聚合查询", x)
+			}
+			return g.aggs[e.aggIndex[x.String()]].result(), nil
+		}
+		args := make([]Value, len(x.args))
+		for i, arg := range x.args {
+			v, err := e.eval(arg, row, g)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return functions[x.name].call(e, args), nil
+
+	case *unaryExpr:
+		v, err := e.eval(x.expr, row, g)
+		if err != nil {
+			return nil, err
+		}
+		if x.op == "NOT" {
+			return !truthy(v), nil
+		}
+		switch n := v.(type) {
+		case time.Duration:
+			return -n, nil
+		case nil:
+			return nil, nil
+		}
+		if f, ok := toNumber(v); ok {
+			return -f, nil
+		}
+		return nil, nil
+
+	case *isNullExpr:
+		v, err := e.eval(x.expr, row, g)
+		if err != nil {
+			return nil, err
+		}
+		isNull := v == nil || v == ""
+		return isNull != x.negate, nil
+
+	case *inExpr:
+		v, err := e.eval(x.expr, row, g)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return false, nil
+		}
+		for _, item := range x.list {
+			iv, err := e.eval(item, row, g)
+			if err != nil {
+				return nil, err
+			}
+			if c, ok := compare(v, iv); ok && c == 0 {
+				return !x.negate, nil
+			}
+		}
+		return x.negate, nil
+
+	case *binaryExpr:
+		return e.evalBinary(x, row, g)
+	}
+	return nil, fmt.Errorf("无法求值的表达式 %s", expr)
+}
+
+func (e *executor) evalBinary(x *binaryExpr, row Row, g *group) (Value, error) {
+	left, err := e.eval(x.left, row, g)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逻辑运算短路求值
+	switch x.op {
+	case "AND":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := e.eval(x.right, row, g)
+		return truthy(right), err
+	case "OR":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := e.eval(x.right, row, g)
+		return truthy(right), err
+	}
+
+	right, err := e.eval(x.right, row, g)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		c, ok := compare(left, right)
+		if !ok {
+			return false, nil
+		}
+		switch x.op {
+		case "=":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+
+	case "~", "!~":
+		if left == nil {
+			return false, nil
+		}
+		pattern := toString(right)
+		re, err := e.regexpFor("re:"+pattern, func() (*regexp.Regexp, error) { return regexp.Compile(pattern) })
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式 %s: %v", pattern, err)
+		}
+		return re.MatchString(toString(left)) == (x.op == "~"), nil
+
+	case "LIKE":
+		if left == nil {
+			return false, nil
+		}
+		pattern := toString(right)
+		re, err := e.regexpFor("like:"+pattern, func() (*regexp.Regexp, error) { return likeToRegexp(pattern) })
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(toString(left)), nil
+	}
+
+	return arithmetic(x.op, left, right), nil
+}
+
+// arithmetic 算术运算，支持数字、时间与时长（数字按秒处理）之间的加减
+func arithmetic(op string, left, right Value) Value {
+	if left == nil || right == nil {
+		return nil
+	}
+
+	if t, ok := left.(time.Time); ok {
+		var d time.Duration
+		switch r := right.(type) {
+		case time.Duration:
+			d = r
+		case time.Time:
+			if op == "-" {
+				return t.Sub(r)
+			}
+			return nil
+		default:
+			f, ok := toNumber(r)
+			if !ok {
+				return nil
+			}
+			d = time.Duration(f * float64(time.Second))
+		}
+		switch op {
+		case "+":
+			return t.Add(d)
+		case "-":
+			return t.Add(-d)
+		}
+		return nil
+	}
+
+	ld, lok := left.(time.Duration)
+	rd, rok := right.(time.Duration)
+	if lok && rok {
+		switch op {
+		case "+":
+			return ld + rd
+		case "-":
+			return ld - rd
+		}
+		return nil
+	}
+
+	a, ok1 := toNumber(left)
+	b, ok2 := toNumber(right)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		if b == 0 {
+			return nil
+		}
+		return a / b
+	case "%":
+		if b == 0 {
+			return nil
+		}
+		return math.Mod(a, b)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package query
+
+import (
+	"context"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// mapRow 测试用的记录
+type mapRow map[string]Value
+
+func (r mapRow) Field(name string) (Value, bool) {
+	v, ok := r[name]
+	return v, ok
+}
+
+// memorySource 测试用的数据来源，按文件名保存记录
+type memorySource map[string][]mapRow
+
+func (s memorySource) Files(pattern string) ([]string, error) {
+	var files []string
+	for name := range s {
+		if ok, _ := path.Match(pattern, name); ok {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+func (s memorySource) Scan(_ context.Context, file string, fn func(Row) bool) error {
+	for _, row := range s[file] {
+		if !fn(row) {
+			break
+		}
+	}
+	return nil
+}
+
+// run 解析并执行查询，返回结果行
+func run(t *testing.T, src memorySource, input string) [][]interface{} {
+	t.Helper()
+	q, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", input, err)
+	}
+	result, err := Execute(context.Background(), q, src, Limits{})
+	if err != nil {
+		t.Fatalf("Execute(%q): %v", input, err)
+	}
+	return result.Rows
+}
+
+func TestArithmetic(t *testing.T) {
+	src := memorySource{"x": {{"a": 7.0, "b": "2", "line": 3.0}}}
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"a + b", 9.0},
+		{"a - b", 5.0},
+		{"a * b", 14.0},
+		{"a / b", 3.5},
+		{"a % b", 1.0},
+		{"7.5 % 2", 1.5},
+		{"line % 0.5", 0.0},
+		{"-a + 1", -6.0},
+		{"a + b * 2", 11.0},
+		{"(a + b) * 2", 18.0},
+		{"a + missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rows := run(t, src, "SELECT "+tt.expr+" FROM 'x'")
+			if len(rows) != 1 || !reflect.DeepEqual(rows[0][0], tt.want) {
+				t.Fatalf("got %v, want %v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	src := memorySource{"x": {{"a": 7.0, "zero": 0.0}}}
+	for _, expr := range []string{"a / 0", "a % 0", "a / zero", "a % zero", "a % 0.0"} {
+		t.Run(expr, func(t *testing.T) {
+			rows := run(t, src, "SELECT "+expr+" FROM 'x'")
+			if len(rows) != 1 || rows[0][0] != nil {
+				t.Fatalf("got %v, want nil", rows)
+			}
+		})
+	}
+}
+
+func TestWhereOrderLimit(t *testing.T) {
+	src := memorySource{"app.log": {
+		{"level": "ERROR", "n": 3.0},
+		{"level": "INFO", "n": 1.0},
+		{"level": "ERROR", "n": 5.0},
+		{"level": "WARN", "n": 4.0},
+	}}
+	rows := run(t, src, "SELECT n FROM 'app.log' WHERE level IN ('ERROR', 'WARN') ORDER BY n DESC LIMIT 2")
+	want := [][]interface{}{{5.0}, {4.0}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got %v, want %v", rows, want)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	src := memorySource{
+		"a.log": {{"level": "ERROR", "ms": 10.0}, {"level": "INFO", "ms": 2.0}},
+		"b.log": {{"level": "ERROR", "ms": 30.0}},
+	}
+	rows := run(t, src, "SELECT level, count(*), sum(ms), max(ms) FROM '*.log' GROUP BY level ORDER BY level")
+	want := [][]interface{}{{"ERROR", 2.0, 40.0, 30.0}, {"INFO", 1.0, 2.0, 2.0}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got %v, want %v", rows, want)
+	}
+}
+
+func TestNoMatchingFiles(t *testing.T) {
+	q, err := Parse("SELECT * FROM 'none/*.log'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Execute(context.Background(), q, memorySource{}, Limits{}); err == nil {
+		t.Fatal("expected error for missing files")
+	}
+}
+
+func TestResultRowsLimit(t *testing.T) {
+	var rows []mapRow
+	for i := 1; i <= 10; i++ {
+		rows = append(rows, mapRow{"n": float64(i)})
+	}
+	src := memorySource{"app.log": rows}
+	tests := []struct {
+		query     string
+		max       int
+		rows      int
+		truncated bool
+	}{
+		{"SELECT n FROM 'app.log'", 3, 3, true},
+		{"SELECT n FROM 'app.log' ORDER BY n DESC", 3, 3, true},
+		// 查询的 LIMIT 不超过上限时不算截断
+		{"SELECT n FROM 'app.log' LIMIT 2", 3, 2, false},
+		{"SELECT n FROM 'app.log' ORDER BY n LIMIT 2", 3, 2, false},
+		{"SELECT n FROM 'app.log' LIMIT 5", 3, 3, true},
+		{"SELECT n FROM 'app.log' WHERE n > 7", 3, 3, false},
+		{"SELECT n FROM 'app.log' WHERE n > 6", 3, 3, true},
+		{"SELECT n FROM 'app.log'", 0, 10, false},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		result, err := Execute(context.Background(), q, src, Limits{MaxResultRows: tt.max})
+		if err != nil {
+			t.Fatalf("Execute(%q): %v", tt.query, err)
+		}
+		if len(result.Rows) != tt.rows || result.Stats.Truncated != tt.truncated {
+			t.Errorf("%q max %d: %d rows, truncated %v, want %d, %v", tt.query, tt.max, len(result.Rows), result.Stats.Truncated, tt.rows, tt.truncated)
+		}
+	}
+}
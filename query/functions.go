@@ -0,0 +1,329 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/stats"
+)
+
+// scalarFunc 标量函数定义
+type scalarFunc struct {
+	minArgs, maxArgs int
+	call             func(e *executor, args []Value) Value
+}
+
+// functions 支持的标量函数
+var functions = map[string]scalarFunc{
+	// now() 查询开始执行的时间
+	"now": {0, 0, func(e *executor, _ []Value) Value { return e.now }},
+	"lower": {1, 1, func(_ *executor, args []Value) Value {
+		if args[0] == nil {
+			return nil
+		}
+		return strings.ToLower(toString(args[0]))
+	}},
+	"upper": {1, 1, func(_ *executor, args []Value) Value {
+		if args[0] == nil {
+			return nil
+		}
+		return strings.ToUpper(toString(args[0]))
+	}},
+	"length": {1, 1, func(_ *executor, args []Value) Value {
+		if args[0] == nil {
+			return nil
+		}
+		return float64(len([]rune(toString(args[0]))))
+	}},
+	// coalesce(a, b, ...) 返回第一个非空值
+	"coalesce": {1, -1, func(_ *executor, args []Value) Value {
+		for _, arg := range args {
+			if arg != nil && arg != "" {
+				return arg
+			}
+		}
+		return nil
+	}},
+	// bucket(ts, 5m) 将时间向下取整到指定间隔，用于按时间分组
+	"bucket": {2, 2, func(_ *executor, args []Value) Value {
+		t, ok := toTime(args[0])
+		if !ok {
+			return nil
+		}
+		d, ok := args[1].(time.Duration)
+		if !ok || d <= 0 {
+			return nil
+		}
+		return t.Truncate(d)
+	}},
+}
+
+// aggregates 支持的聚合函数及对应的累加器构造函数
+var aggregates = map[string]func() aggregator{
+	"count":          func() aggregator { return &countAgg{} },
+	"count_distinct": func() aggregator { return &distinctAgg{values: make(map[string]struct{})} },
+	"sum":            func() aggregator { return &sumAgg{} },
+	"avg":            func() aggregator { return &sumAgg{avg: true} },
+	"min":            func() aggregator { return &extremeAgg{sign: -1} },
+	"max":            func() aggregator { return &extremeAgg{sign: 1} },
+	"p50":            func() aggregator { return &quantileAgg{q: 0.50, sketch: stats.NewSketch()} },
+	"p90":            func() aggregator { return &quantileAgg{q: 0.90, sketch: stats.NewSketch()} },
+	"p95":            func() aggregator { return &quantileAgg{q: 0.95, sketch: stats.NewSketch()} },
+	"p99":            func() aggregator { return &quantileAgg{q: 0.99, sketch: stats.NewSketch()} },
+}
+
+// maxDistinctValues count_distinct 单个分组记录的不同值上限
+const maxDistinctValues = 100000
+
+// aggregator 聚合累加器
+type aggregator interface {
+	add(v Value)
+	result() Value
+}
+
+// countAgg 统计非空值数量，count(*) 统计行数
+type countAgg struct {
+	n float64
+}
+
+func (a *countAgg) add(v Value) {
+	if v != nil {
+		a.n++
+	}
+}
+
+func (a *countAgg) result() Value { return a.n }
+
+// distinctAgg 统计不同值的数量
+type distinctAgg struct {
+	values map[string]struct{}
+}
+
+func (a *distinctAgg) add(v Value) {
+	if v == nil || len(a.values) >= maxDistinctValues {
+		return
+	}
+	a.values[toString(v)] = struct{}{}
+}
+
+func (a *distinctAgg) result() Value { return float64(len(a.values)) }
+
+// sumAgg 求和或平均值，忽略非数字的值
+type sumAgg struct {
+	avg   bool
+	sum   float64
+	count int
+}
+
+func (a *sumAgg) add(v Value) {
+	if f, ok := toNumber(v); ok && v != nil {
+		a.sum += f
+		a.count++
+	}
+}
+
+func (a *sumAgg) result() Value {
+	if a.count == 0 {
+		return nil
+	}
+	if a.avg {
+		return a.sum / float64(a.count)
+	}
+	return a.sum
+}
+
+// extremeAgg 最小值（sign=-1）或最大值（sign=1），可用于数字、时间和字符串
+type extremeAgg struct {
+	sign  int
+	value Value
+}
+
+func (a *extremeAgg) add(v Value) {
+	if v == nil {
+		return
+	}
+	if a.value == nil {
+		a.value = v
+		return
+	}
+	if c, ok := compare(v, a.value); ok && c == a.sign {
+		a.value = v
+	}
+}
+
+func (a *extremeAgg) result() Value { return a.value }
+
+// quantileAgg 分位数，基于流式分位数草图计算
+type quantileAgg struct {
+	q      float64
+	sketch *stats.Sketch
+}
+
+func (a *quantileAgg) add(v Value) {
+	if f, ok := toNumber(v); ok && v != nil {
+		a.sketch.Add(f)
+	}
+}
+
+func (a *quantileAgg) result() Value {
+	if a.sketch.Count() == 0 {
+		return nil
+	}
+	return a.sketch.Quantile(a.q)
+}
+
+// isAggregate 判断表达式是否为聚合函数调用
+func isAggregate(expr Expr) bool {
+	call, ok := expr.(*callExpr)
+	if !ok {
+		return false
+	}
+	_, ok = aggregates[call.name]
+	return ok
+}
+
+// walk 深度优先遍历表达式，fn 返回 false 时不再进入子表达式
+func walk(expr Expr, fn func(Expr) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+	switch e := expr.(type) {
+	case *binaryExpr:
+		walk(e.left, fn)
+		walk(e.right, fn)
+	case *unaryExpr:
+		walk(e.expr, fn)
+	case *callExpr:
+		for _, arg := range e.args {
+			walk(arg, fn)
+		}
+	case *inExpr:
+		walk(e.expr, fn)
+		for _, item := range e.list {
+			walk(item, fn)
+		}
+	case *isNullExpr:
+		walk(e.expr, fn)
+	}
+}
+
+// containsAggregate 判断表达式中是否包含聚合函数
+func containsAggregate(expr Expr) bool {
+	found := false
+	walk(expr, func(x Expr) bool {
+		if isAggregate(x) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// validate 检查查询语句的语义
+func validate(q *Query) error {
+	var err error
+	check := func(expr Expr, allowAggregate bool) {
+		walk(expr, func(x Expr) bool {
+			if err != nil {
+				return false
+			}
+			call, ok := x.(*callExpr)
+			if !ok {
+				return true
+			}
+			if _, isAgg := aggregates[call.name]; isAgg {
+				if !allowAggregate {
+					err = fmt.Errorf("%s 不能在 WHERE 或 GROUP BY 中使用", call)
+					return false
+				}
+				if call.star && call.name != "count" {
+					err = fmt.Errorf("只有 count 支持 *：%s", call)
+					return false
+				}
+				if !call.star && len(call.args) != 1 {
+					err = fmt.Errorf("聚合函数 %s 需要一个参数", call.name)
+					return false
+				}
+				for _, arg := range call.args {
+					if containsAggregate(arg) {
+						err = fmt.Errorf("聚合函数不能嵌套：%s", call)
+						return false
+					}
+				}
+				return true
+			}
+			fn := functions[call.name]
+			if call.star || len(call.args) < fn.minArgs || (fn.maxArgs >= 0 && len(call.args) > fn.maxArgs) {
+				err = fmt.Errorf("函数 %s 的参数数量不正确", call.name)
+				return false
+			}
+			return true
+		})
+	}
+
+	check(q.Where, false)
+	for _, expr := range q.GroupBy {
+		check(expr, false)
+	}
+	for _, item := range q.Select {
+		check(item.Expr, true)
+	}
+	for _, item := range q.OrderBy {
+		check(item.Expr, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !q.grouped() {
+		for _, item := range q.OrderBy {
+			if containsAggregate(item.Expr) {
+				return fmt.Errorf("ORDER BY 中的聚合函数需要配合 GROUP BY 或聚合查询使用")
+			}
+		}
+		return nil
+	}
+	if q.Star {
+		return fmt.Errorf("SELECT * 不能与 GROUP BY 一起使用")
+	}
+
+	groupKeys := make(map[string]bool)
+	for _, expr := range q.GroupBy {
+		groupKeys[expr.String()] = true
+	}
+	for _, item := range q.Select {
+		if field := ungroupedField(item.Expr, groupKeys); field != "" {
+			return fmt.Errorf("字段 %s 需要出现在 GROUP BY 中或放在聚合函数内", field)
+		}
+	}
+	return nil
+}
+
+// grouped 判断是否为聚合查询
+func (q *Query) grouped() bool {
+	if len(q.GroupBy) > 0 {
+		return true
+	}
+	for _, item := range q.Select {
+		if containsAggregate(item.Expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ungroupedField 返回表达式中既不属于分组表达式、也不在聚合函数内的字段名
+func ungroupedField(expr Expr, groupKeys map[string]bool) string {
+	field := ""
+	walk(expr, func(x Expr) bool {
+		if field != "" || groupKeys[x.String()] || isAggregate(x) {
+			return false
+		}
+		if f, ok := x.(*fieldExpr); ok {
+			field = f.name
+		}
+		return true
+	})
+	return field
+}
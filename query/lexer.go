@@ -0,0 +1,166 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokString
+	tokNumber
+	tokDuration
+	tokOperator
+	tokComma
+	tokLParen
+	tokRParen
+	tokStar
+)
+
+// token 词法单元
+type token struct {
+	kind tokenKind
+	text string // 关键字统一为大写，其余保持原样
+	pos  int    // 在查询语句中的位置
+}
+
+// keywords 查询语言的关键字
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "BY": true,
+	"ORDER": true, "ASC": true, "DESC": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "IN": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+}
+
+// durationUnits 时长字面量支持的单位
+var durationUnits = []string{"ms", "s", "m", "h", "d"}
+
+// lex 将查询语句切分为词法单元
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		ch := input[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case ch == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case ch == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case ch == '*':
+			tokens = append(tokens, token{kind: tokStar, text: "*", pos: i})
+			i++
+		case ch == '\'' || ch == '"':
+			value, next, err := lexString(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: value, pos: i})
+			i = next
+		case ch == '`':
+			end := strings.IndexByte(input[i+1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("位置 %d: 反引号未闭合", i)
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: input[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case isDigit(ch):
+			start := i
+			for i < len(input) && (isDigit(input[i]) || input[i] == '.') {
+				i++
+			}
+			kind := tokNumber
+			for _, unit := range durationUnits {
+				if strings.HasPrefix(input[i:], unit) && !isIdentChar(input, i+len(unit)) {
+					kind = tokDuration
+					i += len(unit)
+					break
+				}
+			}
+			tokens = append(tokens, token{kind: kind, text: input[start:i], pos: start})
+		case isIdentStart(ch):
+			start := i
+			for isIdentChar(input, i) {
+				i++
+			}
+			word := input[start:i]
+			if upper := strings.ToUpper(word); keywords[upper] {
+				tokens = append(tokens, token{kind: tokKeyword, text: upper, pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+			}
+		default:
+			op := lexOperator(input[i:])
+			if op == "" {
+				return nil, fmt.Errorf("位置 %d: 无法识别的字符 %q", i, ch)
+			}
+			tokens = append(tokens, token{kind: tokOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(input)})
+	return tokens, nil
+}
+
+// lexString 读取引号包裹的字符串，支持反斜杠转义和连续两个引号转义
+func lexString(input string, start int) (string, int, error) {
+	quote := input[start]
+	var sb strings.Builder
+	i := start + 1
+	for i < len(input) {
+		ch := input[i]
+		switch {
+		case ch == '\\' && i+1 < len(input):
+			sb.WriteByte(input[i+1])
+			i += 2
+		case ch == quote && i+1 < len(input) && input[i+1] == quote:
+			sb.WriteByte(quote)
+			i += 2
+		case ch == quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(ch)
+			i++
+		}
+	}
+	return "", 0, fmt.Errorf("位置 %d: 字符串未闭合", start)
+}
+
+// lexOperator 读取运算符，优先匹配较长的运算符
+func lexOperator(s string) string {
+	for _, op := range []string{"<=", ">=", "!=", "<>", "!~", "=", "<", ">", "~", "+", "-", "/", "%"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch == '@' || unicode.IsLetter(rune(ch))
+}
+
+// isIdentChar 标识符可以包含字母、数字、下划线、点（嵌套字段）和@
+func isIdentChar(input string, i int) bool {
+	if i >= len(input) {
+		return false
+	}
+	ch := input[i]
+	return isIdentStart(ch) || isDigit(ch) || ch == '.'
+}
@@ -0,0 +1,460 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse 解析查询语句
+// 语法：SELECT 列 FROM '文件模式' [WHERE 条件] [GROUP BY 表达式] [ORDER BY 表达式 [ASC|DESC]] [LIMIT n]
+func Parse(input string) (*Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	q, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveGroupBy(q); err != nil {
+		return nil, err
+	}
+	if err := validate(q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// resolveGroupBy 将 GROUP BY 中的列别名和列位置替换为对应的输出列表达式
+func resolveGroupBy(q *Query) error {
+	for i, expr := range q.GroupBy {
+		switch x := expr.(type) {
+		case *literalExpr:
+			n, ok := x.value.(float64)
+			if !ok || n != float64(int(n)) || int(n) < 1 || int(n) > len(q.Select) {
+				return fmt.Errorf("GROUP BY 位置 %s 超出列的范围", x.text)
+			}
+			q.GroupBy[i] = q.Select[int(n)-1].Expr
+		case *fieldExpr:
+			for _, item := range q.Select {
+				if item.Alias == x.name && item.Expr.String() != x.name {
+					q.GroupBy[i] = item.Expr
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// queryParser 递归下降语法分析器
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// acceptKeyword 当前为指定关键字时消费并返回 true
+func (p *queryParser) acceptKeyword(words ...string) bool {
+	for i, word := range words {
+		if p.pos+i >= len(p.tokens) {
+			return false
+		}
+		tok := p.tokens[p.pos+i]
+		if tok.kind != tokKeyword || tok.text != word {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *queryParser) expectKeyword(words ...string) error {
+	if !p.acceptKeyword(words...) {
+		return p.errorf("缺少 %s", strings.Join(words, " "))
+	}
+	return nil
+}
+
+func (p *queryParser) accept(kind tokenKind) bool {
+	if p.peek().kind == kind {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) acceptOperator(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	tok := p.peek()
+	near := tok.text
+	if tok.kind == tokEOF {
+		near = "语句末尾"
+	}
+	return fmt.Errorf("位置 %d（%s）: %s", tok.pos, near, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) parseQuery() (*Query, error) {
+	q := &Query{}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	if p.accept(tokStar) {
+		q.Star = true
+	} else {
+		for {
+			item, err := p.parseSelectItem()
+			if err != nil {
+				return nil, err
+			}
+			q.Select = append(q.Select, item)
+			if !p.accept(tokComma) {
+				break
+			}
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	from := p.next()
+	if from.kind != tokString && from.kind != tokIdent {
+		return nil, p.errorf("FROM 后需要文件模式，如 'nginx/*.log'")
+	}
+	q.From = from.text
+
+	if p.acceptKeyword("WHERE") {
+		where, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		q.Where = where
+	}
+
+	if p.acceptKeyword("GROUP", "BY") {
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			q.GroupBy = append(q.GroupBy, expr)
+			if !p.accept(tokComma) {
+				break
+			}
+		}
+	}
+
+	if p.acceptKeyword("ORDER", "BY") {
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			item := OrderItem{Expr: expr}
+			if p.acceptKeyword("DESC") {
+				item.Desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			q.OrderBy = append(q.OrderBy, item)
+			if !p.accept(tokComma) {
+				break
+			}
+		}
+	}
+
+	if p.acceptKeyword("LIMIT") {
+		tok := p.next()
+		n, err := strconv.Atoi(tok.text)
+		if tok.kind != tokNumber || err != nil || n < 0 {
+			return nil, fmt.Errorf("位置 %d: LIMIT 需要非负整数", tok.pos)
+		}
+		q.Limit = n
+	}
+
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("多余的内容")
+	}
+	return q, nil
+}
+
+func (p *queryParser) parseSelectItem() (SelectItem, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return SelectItem{}, err
+	}
+	item := SelectItem{Expr: expr}
+	if p.acceptKeyword("AS") {
+		tok := p.next()
+		if tok.kind != tokIdent && tok.kind != tokString {
+			return SelectItem{}, fmt.Errorf("位置 %d: AS 后需要列名", tok.pos)
+		}
+		item.Alias = tok.text
+	} else if p.peek().kind == tokIdent {
+		item.Alias = p.next().text
+	}
+	return item, nil
+}
+
+// parseExpr 表达式优先级从低到高：OR、AND、NOT、比较、加减、乘除、一元负号
+func (p *queryParser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *queryParser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (Expr, error) {
+	if p.acceptKeyword("NOT") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", expr: expr}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (Expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if op, ok := p.acceptOperator("=", "!=", "<>", "<", "<=", ">", ">=", "~", "!~"); ok {
+		if op == "<>" {
+			op = "!="
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryExpr{op: op, left: left, right: right}, nil
+	}
+
+	if p.acceptKeyword("IS") {
+		negate := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullExpr{expr: left, negate: negate}, nil
+	}
+
+	negate := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		var expr Expr = &binaryExpr{op: "LIKE", left: left, right: right}
+		if negate {
+			expr = &unaryExpr{op: "NOT", expr: expr}
+		}
+		return expr, nil
+	case p.acceptKeyword("IN"):
+		if !p.accept(tokLParen) {
+			return nil, p.errorf("IN 后需要括号列表")
+		}
+		in := &inExpr{expr: left, negate: negate}
+		for {
+			item, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+			if !p.accept(tokComma) {
+				break
+			}
+		}
+		if !p.accept(tokRParen) {
+			return nil, p.errorf("IN 列表缺少右括号")
+		}
+		return in, nil
+	case negate:
+		return nil, p.errorf("NOT 后需要 LIKE 或 IN")
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAdditive() (Expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOperator("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *queryParser) parseMultiplicative() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		if p.accept(tokStar) {
+			op = "*"
+		} else if o, ok := p.acceptOperator("/", "%"); ok {
+			op = o
+		} else {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *queryParser) parseUnary() (Expr, error) {
+	if _, ok := p.acceptOperator("-"); ok {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "-", expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (Expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的数字 %s", tok.pos, tok.text)
+		}
+		return &literalExpr{value: f, text: tok.text}, nil
+	case tokDuration:
+		p.next()
+		d, err := parseDuration(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的时长 %s", tok.pos, tok.text)
+		}
+		return &literalExpr{value: d, text: tok.text}, nil
+	case tokString:
+		p.next()
+		return &literalExpr{value: tok.text, text: quoteString(tok.text)}, nil
+	case tokKeyword:
+		switch tok.text {
+		case "NULL":
+			p.next()
+			return &literalExpr{value: nil, text: "NULL"}, nil
+		case "TRUE", "FALSE":
+			p.next()
+			return &literalExpr{value: tok.text == "TRUE", text: tok.text}, nil
+		}
+	case tokLParen:
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(tokRParen) {
+			return nil, p.errorf("缺少右括号")
+		}
+		return expr, nil
+	case tokIdent:
+		p.next()
+		if !p.accept(tokLParen) {
+			return &fieldExpr{name: tok.text}, nil
+		}
+		return p.parseCall(tok)
+	}
+	return nil, p.errorf("需要表达式")
+}
+
+func (p *queryParser) parseCall(name token) (Expr, error) {
+	call := &callExpr{name: strings.ToLower(name.text)}
+	if _, ok := functions[call.name]; !ok {
+		if _, ok := aggregates[call.name]; !ok {
+			return nil, fmt.Errorf("位置 %d: 未知的函数 %s", name.pos, name.text)
+		}
+	}
+
+	if p.accept(tokStar) {
+		call.star = true
+	} else if p.peek().kind != tokRParen {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.accept(tokComma) {
+				break
+			}
+		}
+	}
+	if !p.accept(tokRParen) {
+		return nil, p.errorf("函数 %s 缺少右括号", name.text)
+	}
+	return call, nil
+}
@@ -0,0 +1,95 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tokens, err := lex("select `a b`, 'it''s', 1.5, 5m, x>=2 FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind tokenKind
+		text string
+	}{
+		{tokKeyword, "SELECT"},
+		{tokIdent, "a b"},
+		{tokComma, ","},
+		{tokString, "it's"},
+		{tokComma, ","},
+		{tokNumber, "1.5"},
+		{tokComma, ","},
+		{tokDuration, "5m"},
+		{tokComma, ","},
+		{tokIdent, "x"},
+		{tokOperator, ">="},
+		{tokNumber, "2"},
+		{tokKeyword, "FROM"},
+		{tokIdent, "t"},
+		{tokEOF, ""},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens %v, want %d", len(tokens), tokens, len(want))
+	}
+	for i, w := range want {
+		if tokens[i].kind != w.kind || tokens[i].text != w.text {
+			t.Errorf("token %d = {%d %q}, want {%d %q}", i, tokens[i].kind, tokens[i].text, w.kind, w.text)
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	for _, input := range []string{"SELECT 'abc", "SELECT `abc", "SELECT #"} {
+		if _, err := lex(input); err == nil {
+			t.Errorf("lex(%q): expected error", input)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		grouped bool
+		limit   int
+	}{
+		{"SELECT * FROM 'a.log'", false, 0},
+		{"SELECT line, message FROM 'nginx/*.log' WHERE status >= 500 AND path LIKE '/api%' LIMIT 10", false, 10},
+		{"SELECT level, count(*) AS n FROM '*.log' GROUP BY level ORDER BY n DESC", true, 0},
+		{"SELECT count(*) FROM x WHERE ts > now() - 1h", true, 0},
+		{"SELECT bucket(ts, 5m) AS t, p99(ms) FROM x GROUP BY t", true, 0},
+		{"SELECT a FROM x WHERE b IS NOT NULL AND c NOT IN (1, 2)", false, 0},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if q.grouped() != tt.grouped {
+			t.Errorf("Parse(%q).grouped() = %v, want %v", tt.input, q.grouped(), tt.grouped)
+		}
+		if q.Limit != tt.limit {
+			t.Errorf("Parse(%q).Limit = %d, want %d", tt.input, q.Limit, tt.limit)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"SELECT",
+		"SELECT a",
+		"SELECT a FROM",
+		"SELECT a FROM x LIMIT -1",
+		"SELECT a FROM x LIMIT 1.5",
+		"SELECT a FROM x WHERE",
+		"SELECT a FROM x extra",
+		"SELECT (a FROM x",
+		"SELECT unknown_fn(a) FROM x",
+	} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q): expected error", input)
+		}
+	}
+}
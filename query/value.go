@@ -0,0 +1,175 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anjude/log-tools/parser"
+)
+
+// Value 表达式求值结果，可能是 nil、bool、float64、string、time.Time 或 time.Duration
+type Value interface{}
+
+// toNumber 将值转换为数字，字符串会尝试按数字解析
+func toNumber(v Value) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	case time.Duration:
+		return x.Seconds(), true
+	}
+	return 0, false
+}
+
+// toTime 将值转换为时间，字符串按常见时间格式解析
+func toTime(v Value) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		return parser.ParseTime(x)
+	}
+	return time.Time{}, false
+}
+
+// toString 将值转换为字符串
+func toString(v Value) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return formatNumber(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.000")
+	case time.Duration:
+		return x.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// truthy 判断值在条件中是否为真
+func truthy(v Value) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return true
+}
+
+// compare 比较两个值，返回 -1、0、1；无法比较时 ok 为 false
+// 一侧为数字时按数字比较，一侧为时间时按时间比较，否则按字符串比较
+func compare(a, b Value) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	if aTime || bTime {
+		ta, ok1 := toTime(a)
+		tb, ok2 := toTime(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+
+	_, aStr := a.(string)
+	_, bStr := b.(string)
+	if !aStr || !bStr {
+		fa, ok1 := toNumber(a)
+		fb, ok2 := toNumber(b)
+		if ok1 && ok2 {
+			return compareFloat(fa, fb), true
+		}
+	}
+
+	sa, sb := toString(a), toString(b)
+	// 两个字符串都是数字时按数字比较，避免 "10" < "9"
+	if aStr && bStr {
+		if fa, err := strconv.ParseFloat(sa, 64); err == nil {
+			if fb, err := strconv.ParseFloat(sb, 64); err == nil {
+				return compareFloat(fa, fb), true
+			}
+		}
+	}
+	return strings.Compare(sa, sb), true
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// likeToRegexp 将 LIKE 模式转换为正则表达式，% 匹配任意字符串，_ 匹配单个字符，不区分大小写
+func likeToRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// exportValue 将值转换为适合JSON输出的形式
+func exportValue(v Value) interface{} {
+	switch x := v.(type) {
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.000")
+	case time.Duration:
+		return x.String()
+	}
+	return v
+}
+
+// parseDuration 解析时长字面量，额外支持天（d）
+func parseDuration(text string) (time.Duration, error) {
+	if strings.HasSuffix(text, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(text, "d"), 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(text)
+}
@@ -0,0 +1,387 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 内置检测器名称
+const (
+	DetectorIDCard      = "id_card"
+	DetectorPhone       = "phone"
+	DetectorEmail       = "email"
+	DetectorBearerToken = "bearer_token"
+	DetectorCredential  = "credential"
+)
+
+// secretGroup 规则中的命名捕获组，存在时只替换该分组，其余部分保持原样
+const secretGroup = "secret"
+
+// defaultReplacement 自定义规则未指定替换内容时使用的掩码
+const defaultReplacement = "***"
+
+// Rule 脱敏规则
+type Rule struct {
+	Name        string
+	Regex       string
+	Replacement string              // 替换内容，支持 $1、${name} 引用分组；为空且 Mask 为空时使用 ***
+	Mask        func(string) string // 内置检测器的掩码函数，作用于 secret 分组或整个匹配
+
+	digitBounded bool // 匹配前后不能紧挨数字，在匹配外检查，避免边界字符被匹配消耗后漏掉相邻的号码
+}
+
+// compiledRule 编译后的脱敏规则
+type compiledRule struct {
+	Rule
+	re     *regexp.Regexp
+	secret int // secret 分组下标，-1 表示替换整个匹配
+}
+
+// builtinRules 内置检测器，按顺序执行（身份证号需在手机号之前，避免部分匹配）
+var builtinRules = []Rule{
+	{
+		Name:         DetectorIDCard,
+		Regex:        `(?P<secret>[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[0-9Xx])`,
+		Mask:         keepEnds(6, 4),
+		digitBounded: true,
+	},
+	{
+		Name:         DetectorPhone,
+		Regex:        `(?P<secret>(?:\+?86[- ]?)?1[3-9]\d{9})`,
+		Mask:         keepEnds(3, 4),
+		digitBounded: true,
+	},
+	{
+		Name:  DetectorEmail,
+		Regex: `(?P<secret>[A-Za-z0-9._%+\-]+)@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+		Mask:  keepEnds(1, 0),
+	},
+	{
+		Name:  DetectorBearerToken,
+		Regex: `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`,
+		Mask:  fixedMask,
+	},
+	{
+		Name:  DetectorCredential,
+		Regex: `(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key)["']?\s*[:=]\s*["']?(?P<secret>[^\s"'&,;]+)`,
+		Mask:  fixedMask,
+	},
+}
+
+// BuiltinNames 返回内置检测器名称
+func BuiltinNames() []string {
+	names := make([]string, len(builtinRules))
+	for i, rule := range builtinRules {
+		names[i] = rule.Name
+	}
+	return names
+}
+
+// keepEnds 保留开头 head 个和末尾 tail 个字符，中间替换为 *
+func keepEnds(head, tail int) func(string) string {
+	return func(s string) string {
+		runes := []rune(s)
+		if len(runes) <= head+tail {
+			return strings.Repeat("*", len(runes))
+		}
+		masked := len(runes) - head - tail
+		if masked > 8 {
+			masked = 8
+		}
+		return string(runes[:head]) + strings.Repeat("*", masked) + string(runes[len(runes)-tail:])
+	}
+}
+
+// fixedMask 完全隐藏，不暴露长度
+func fixedMask(string) string {
+	return defaultReplacement
+}
+
+// Redactor 按规则对文本进行脱敏
+type Redactor struct {
+	rules []compiledRule
+}
+
+// New 创建脱敏器，detectors 为启用的内置检测器（为空表示全部启用），custom 为自定义规则
+func New(detectors []string, custom []Rule) (*Redactor, error) {
+	enabled := make(map[string]bool)
+	for _, name := range detectors {
+		enabled[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	var rules []Rule
+	known := make(map[string]bool)
+	for _, rule := range builtinRules {
+		known[rule.Name] = true
+		if len(enabled) == 0 || enabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	for name := range enabled {
+		if !known[name] {
+			return nil, fmt.Errorf("未知的内置检测器: %s", name)
+		}
+	}
+	rules = append(rules, custom...)
+
+	r := &Redactor{}
+	for _, rule := range rules {
+		if rule.Regex == "" {
+			return nil, fmt.Errorf("脱敏规则 %s 缺少正则表达式", rule.Name)
+		}
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏规则 %s 正则表达式错误: %v", rule.Name, err)
+		}
+		if rule.Mask == nil && rule.Replacement == "" {
+			rule.Replacement = defaultReplacement
+		}
+		r.rules = append(r.rules, compiledRule{Rule: rule, re: re, secret: re.SubexpIndex(secretGroup)})
+	}
+	return r, nil
+}
+
+// apply 对文本执行所有规则，返回脱敏后的文本并累加各规则的命中次数，hits 为 nil 时不计数
+func (r *Redactor) apply(s string, hits map[string]int) string {
+	for i := range r.rules {
+		s, _ = r.rules[i].apply(s, hits)
+	}
+	return s
+}
+
+// edit 一次替换：原文本中 [start, end) 被替换为 size 字节的内容
+type edit struct {
+	start, end, size int
+}
+
+// matches 返回规则在文本中的所有匹配，digitBounded 的规则跳过前后紧挨数字的匹配，并从其下一个字符继续查找
+func (rule *compiledRule) matches(s string) [][]int {
+	if !rule.digitBounded {
+		return rule.re.FindAllStringSubmatchIndex(s, -1)
+	}
+	var result [][]int
+	for pos := 0; pos < len(s); {
+		m := rule.re.FindStringSubmatchIndex(s[pos:])
+		if m == nil {
+			break
+		}
+		for i := range m {
+			if m[i] >= 0 {
+				m[i] += pos
+			}
+		}
+		if m[0] > 0 && isDigit(s[m[0]-1]) || m[1] < len(s) && isDigit(s[m[1]]) {
+			pos = m[0] + 1
+			continue
+		}
+		result = append(result, m)
+		pos = max(m[1], m[0]+1)
+	}
+	return result
+}
+
+// isDigit 判断字节是否为 ASCII 数字
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// apply 对文本执行规则，返回替换后的文本和各次替换的位置，hits 为 nil 时不计数
+func (rule *compiledRule) apply(s string, hits map[string]int) (string, []edit) {
+	matches := rule.matches(s)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	var edits []edit
+	last := 0
+	for _, m := range matches {
+		start, end := rule.secretRange(m)
+		if start < last {
+			continue
+		}
+		sb.WriteString(s[last:start])
+		before := sb.Len()
+		if rule.Mask != nil {
+			sb.WriteString(rule.Mask(s[start:end]))
+		} else {
+			sb.Write(rule.re.ExpandString(nil, rule.Replacement, s, m))
+		}
+		edits = append(edits, edit{start: start, end: end, size: sb.Len() - before})
+		last = end
+		if hits != nil {
+			hits[rule.Name]++
+		}
+	}
+	sb.WriteString(s[last:])
+	return sb.String(), edits
+}
+
+// secretRange 返回匹配中需要替换的范围：secret 分组或整个匹配
+func (rule *compiledRule) secretRange(m []int) (int, int) {
+	if rule.secret >= 0 && m[2*rule.secret] >= 0 {
+		return m[2*rule.secret], m[2*rule.secret+1]
+	}
+	return m[0], m[1]
+}
+
+// shiftCut 计算替换后分段边界的新位置，位于被替换内容中间的边界移到替换内容之后
+func shiftCut(cut int, edits []edit) int {
+	delta := 0
+	for _, e := range edits {
+		if cut <= e.start {
+			break
+		}
+		if cut < e.end {
+			return e.start + delta + e.size
+		}
+		delta += e.size - (e.end - e.start)
+	}
+	return cut + delta
+}
+
+// Session 一次请求的脱敏会话，记录各规则的命中次数
+// nil 会话表示不脱敏，所有方法原样返回
+type Session struct {
+	redactor *Redactor
+	mu       sync.Mutex
+	hits     map[string]int
+}
+
+// NewSession 创建脱敏会话
+func (r *Redactor) NewSession() *Session {
+	if r == nil || len(r.rules) == 0 {
+		return nil
+	}
+	return &Session{redactor: r, hits: make(map[string]int)}
+}
+
+// String 对文本脱敏
+func (s *Session) String(text string) string {
+	if s == nil || text == "" {
+		return text
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.redactor.apply(text, s.hits)
+}
+
+// Silent 对文本脱敏但不计入命中次数，用于在脱敏后的文本上匹配搜索和查询条件
+func (s *Session) Silent(text string) string {
+	if s == nil || text == "" {
+		return text
+	}
+	return s.redactor.apply(text, nil)
+}
+
+// Hits 返回各规则的命中次数
+func (s *Session) Hits() map[string]int {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hits := make(map[string]int, len(s.hits))
+	for name, n := range s.hits {
+		hits[name] = n
+	}
+	return hits
+}
+
+// Total 返回总命中次数
+func (s *Session) Total() int {
+	total := 0
+	for _, n := range s.Hits() {
+		total += n
+	}
+	return total
+}
+
+// Summary 返回 "规则=次数" 形式的命中摘要，按规则名排序
+func (s *Session) Summary() string {
+	hits := s.Hits()
+	names := make([]string, 0, len(hits))
+	for name := range hits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, hits[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Segments 将多段文本连接后整体脱敏，再按原来的分段切分返回，用于带样式的片段等
+// 跨越分段的敏感内容同样会被识别，替换内容归入敏感内容开始所在的分段
+func (s *Session) Segments(parts []string) []string {
+	if s == nil || len(parts) == 0 {
+		return parts
+	}
+	cuts := make([]int, 0, len(parts)-1)
+	var sb strings.Builder
+	for i, part := range parts {
+		sb.WriteString(part)
+		if i < len(parts)-1 {
+			cuts = append(cuts, sb.Len())
+		}
+	}
+
+	s.mu.Lock()
+	text := sb.String()
+	for i := range s.redactor.rules {
+		var edits []edit
+		text, edits = s.redactor.rules[i].apply(text, s.hits)
+		for j := range cuts {
+			cuts[j] = shiftCut(cuts[j], edits)
+		}
+	}
+	s.mu.Unlock()
+
+	result := make([]string, len(parts))
+	last := 0
+	for i, cut := range cuts {
+		cut = max(cut, last)
+		result[i] = text[last:cut]
+		last = cut
+	}
+	result[len(parts)-1] = text[last:]
+	return result
+}
+
+// Mask 将敏感内容逐字节替换为 *，长度和其他字节保持不变，用于需要保持偏移的场景（如十六进制视图）
+func (s *Session) Mask(data []byte) []byte {
+	return s.MaskRange(data, 0, len(data))
+}
+
+// MaskRange 同 Mask，但只统计与 [from, to) 有重叠的敏感内容
+// 调用方为识别跨越边界的内容多读取了前后的数据时，只有实际返回部分中的命中计入统计
+func (s *Session) MaskRange(data []byte, from, to int) []byte {
+	if s == nil || len(data) == 0 {
+		return data
+	}
+	masked := append([]byte(nil), data...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.redactor.rules {
+		rule := &s.redactor.rules[i]
+		last := 0
+		for _, m := range rule.matches(string(masked)) {
+			start, end := rule.secretRange(m)
+			if start < last {
+				continue
+			}
+			for j := start; j < end; j++ {
+				masked[j] = '*'
+			}
+			last = end
+			if start < to && end > from {
+				s.hits[rule.Name]++
+			}
+		}
+	}
+	return masked
+}
@@ -0,0 +1,137 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func newSession(t *testing.T, detectors []string, custom []Rule) *Session {
+	t.Helper()
+	r, err := New(detectors, custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.NewSession()
+}
+
+func TestString(t *testing.T) {
+	s := newSession(t, nil, nil)
+	tests := []struct {
+		in, want string
+	}{
+		{"call 13812345678 now", "call 138****5678 now"},
+		{"id 110101199003071234 ok", "id 110101********1234 ok"},
+		{"mail alice@example.com", "mail a****@example.com"},
+		{"Authorization: Bearer abc.def", "Authorization: Bearer ***"},
+		{"password=hunter2&x=1", "password=***&x=1"},
+		{"nothing to hide", "nothing to hide"},
+		// 相邻的号码之间只隔一个字符
+		{"a 13800138000 13900139000", "a 138****8000 139****9000"},
+		{"13800138000,13900139000", "138****8000,139****9000"},
+		{"110101199003071234 110101199003075678", "110101********1234 110101********5678"},
+		{"110101199003071234,13800138000", "110101********1234,138****8000"},
+		{"tel:+86 13800138000;", "tel:+86********8000;"},
+		// 更长的数字串不是手机号或身份证号
+		{"order 138001380001 id 1101011990030712345", "order 138001380001 id 1101011990030712345"},
+		{"x113800138000 186 13800138000", "x113800138000 186 138****8000"},
+	}
+	for _, tt := range tests {
+		if got := s.String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	s := newSession(t, []string{DetectorEmail}, []Rule{{Name: "card", Regex: `\b(?P<secret>\d{12})\d{4}\b`, Replacement: "************"}})
+	if got := s.String("card 6222021234567890"); got != "card ************7890" {
+		t.Fatalf("got %q", got)
+	}
+	if s.Hits()["card"] != 1 || s.Total() != 1 {
+		t.Fatalf("hits = %v", s.Hits())
+	}
+}
+
+func TestSegments(t *testing.T) {
+	s := newSession(t, nil, nil)
+	tests := []struct {
+		parts []string
+		want  []string
+	}{
+		// 敏感内容跨越样式边界
+		{[]string{"token=ab", "cdef done"}, []string{"token=***", " done"}},
+		{[]string{"phone 138", "1234", "5678 end"}, []string{"phone 138****5678", "", " end"}},
+		{[]string{"[INFO] ", "no secret"}, []string{"[INFO] ", "no secret"}},
+		{[]string{"a ", "password=x", " b"}, []string{"a ", "password=***", " b"}},
+	}
+	for _, tt := range tests {
+		got := s.Segments(tt.parts)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Segments(%q) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	s := newSession(t, nil, nil)
+	in := []byte("\x00\x01tel 13812345678\xff password=abc;")
+	got := s.Mask(in)
+	if len(got) != len(in) {
+		t.Fatalf("length changed: %d != %d", len(got), len(in))
+	}
+	want := "\x00\x01tel ***********\xff password=***;"
+	if string(got) != want {
+		t.Fatalf("Mask = %q, want %q", got, want)
+	}
+	if string(in) == string(got) {
+		t.Fatal("input modified or not masked")
+	}
+	if got := s.Mask([]byte("13800138000,13900139000")); string(got) != "***********,***********" {
+		t.Fatalf("Mask adjacent = %q", got)
+	}
+}
+
+func TestMaskRange(t *testing.T) {
+	data := []byte("13800138000 x password=abc y 13900139000")
+	tests := []struct {
+		from, to int
+		want     int
+	}{
+		{0, len(data), 3},
+		{12, 27, 1},
+		// 与窗口部分重叠的内容也计入
+		{10, 12, 1},
+		{11, 12, 0},
+		{29, 30, 1},
+	}
+	for _, tt := range tests {
+		s := newSession(t, nil, nil)
+		got := s.MaskRange(data, tt.from, tt.to)
+		if string(got) != "*********** x password=*** y ***********" {
+			t.Fatalf("MaskRange(%d, %d) = %q", tt.from, tt.to, got)
+		}
+		if s.Total() != tt.want {
+			t.Errorf("MaskRange(%d, %d) counted %d, want %d", tt.from, tt.to, s.Total(), tt.want)
+		}
+	}
+}
+
+func TestNilSession(t *testing.T) {
+	var s *Session
+	if s.String("13812345678") != "13812345678" || s.Total() != 0 {
+		t.Fatal("nil session must not redact")
+	}
+	if string(s.Mask([]byte("x"))) != "x" || len(s.Segments([]string{"a"})) != 1 {
+		t.Fatal("nil session must return input")
+	}
+}
+
+func TestSilent(t *testing.T) {
+	s := newSession(t, nil, nil)
+	if got := s.Silent("token=abc 13812345678"); got != "token=*** 138****5678" {
+		t.Fatalf("Silent = %q", got)
+	}
+	if s.Total() != 0 {
+		t.Fatalf("Silent must not count hits, got %v", s.Hits())
+	}
+}
@@ -0,0 +1,123 @@
+package rotation
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// compression 轮转文件的压缩格式
+type compression struct {
+	ext   string
+	magic string                                       // 文件开头的魔数
+	open  func(r io.Reader) (io.Reader, func(), error) // 返回解压后的内容和释放资源的函数
+}
+
+// compressions 支持的压缩格式，按文件扩展名或文件开头的魔数识别
+var compressions = []compression{
+	{ext: "gz", magic: "\x1f\x8b", open: func(r io.Reader) (io.Reader, func(), error) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, func() { zr.Close() }, nil
+	}},
+	{ext: "bz2", magic: "BZh", open: func(r io.Reader) (io.Reader, func(), error) {
+		return bzip2.NewReader(r), func() {}, nil
+	}},
+	{ext: "zst", magic: "\x28\xb5\x2f\xfd", open: func(r io.Reader) (io.Reader, func(), error) {
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	}},
+	{ext: "xz", magic: "\xfd7zXZ\x00", open: func(r io.Reader) (io.Reader, func(), error) {
+		zr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, func() {}, nil
+	}},
+}
+
+// reader 解压后的文件内容
+type reader struct {
+	io.Reader
+	closer  io.Closer
+	release func()
+}
+
+// Close 关闭解压器和文件
+func (r *reader) Close() error {
+	if r.release != nil {
+		r.release()
+	}
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Open 打开文件组成员，按压缩格式返回解压后的内容
+func Open(path string, m Member) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if m.Compression == "" {
+		return file, nil
+	}
+	r, err := NewReader(file, m.Compression)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("解压 %s 失败: %w", path, err)
+	}
+	return r, nil
+}
+
+// NewReader 按压缩格式返回解压后的内容，关闭时同时关闭 r（如果 r 实现了 io.Closer）
+func NewReader(r io.Reader, format string) (io.ReadCloser, error) {
+	for _, c := range compressions {
+		if c.ext != format {
+			continue
+		}
+		zr, release, err := c.open(bufio.NewReader(r))
+		if err != nil {
+			return nil, err
+		}
+		closer, _ := r.(io.Closer)
+		return &reader{Reader: zr, closer: closer, release: release}, nil
+	}
+	return nil, fmt.Errorf("不支持的压缩格式: %s", format)
+}
+
+// Detect 识别文件的压缩格式：先按扩展名，再按文件开头的魔数，未压缩返回空
+func Detect(path string) string {
+	for _, c := range compressions {
+		if strings.HasSuffix(path, "."+c.ext) {
+			return c.ext
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	header := make([]byte, 8)
+	n, _ := io.ReadFull(file, header)
+	for _, c := range compressions {
+		if bytes.HasPrefix(header[:n], []byte(c.magic)) {
+			return c.ext
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package rotation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 规则中使用的命名分组
+const (
+	groupBase  = "base"  // 活动文件名（或其扩展名之前的部分）
+	groupExt   = "ext"   // 可选，拼接在 base 之后组成活动文件名
+	groupIndex = "index" // 轮转序号，越大越旧
+	groupDate  = "date"  // 轮转日期
+)
+
+// defaultDateLayout 日期分组去掉分隔符后的默认格式
+const defaultDateLayout = "20060102"
+
+// Rule 轮转文件命名规则
+type Rule struct {
+	Name       string
+	Regex      *regexp.Regexp // 匹配去掉压缩后缀的文件名
+	DateLayout string         // date 分组的时间格式，为空时按 20060102 或 2006010215 解析（先去掉 - _ T 分隔符）
+}
+
+// DefaultRules 内置规则：数字后缀（app.log.1）、日期后缀（app.log-20261017、app.log.2026-10-17）、
+// 扩展名前的日期（app-2026-10-17.log）
+func DefaultRules() []Rule {
+	date := `(?P<date>\d{4}-?\d{2}-?\d{2}(?:[-_T]?\d{2})?)`
+	return []Rule{
+		{Name: "numeric", Regex: regexp.MustCompile(`^(?P<base>.+)\.(?P<index>\d{1,4})$`)},
+		{Name: "date", Regex: regexp.MustCompile(`^(?P<base>.+?)[.\-_]` + date + `$`)},
+		{Name: "date_before_ext", Regex: regexp.MustCompile(`^(?P<base>.+?)[.\-_]` + date + `(?P<ext>\.[A-Za-z][A-Za-z0-9]*)$`)},
+	}
+}
+
+// CompileRule 编译自定义规则，正则必须包含 base 分组，以及 index 或 date 分组之一
+func CompileRule(name, pattern, dateLayout string) (Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("轮转规则 %s 的正则错误: %w", name, err)
+	}
+	if re.SubexpIndex(groupBase) < 0 {
+		return Rule{}, fmt.Errorf("轮转规则 %s 缺少命名分组 base", name)
+	}
+	if re.SubexpIndex(groupIndex) < 0 && re.SubexpIndex(groupDate) < 0 {
+		return Rule{}, fmt.Errorf("轮转规则 %s 缺少命名分组 index 或 date", name)
+	}
+	return Rule{Name: name, Regex: re, DateLayout: dateLayout}, nil
+}
+
+// Member 轮转文件组中的一个文件
+type Member struct {
+	Name        string    // 文件名
+	Base        string    // 所属文件组的活动文件名
+	Rule        string    // 匹配的规则名称，活动文件为空
+	Index       int       // 轮转序号，没有时为 -1
+	Date        time.Time // 轮转日期，没有时为零值
+	Compression string    // 压缩格式：gz、bz2、zst、xz，未压缩为空
+	ModTime     time.Time // 修改时间，规则无法确定先后时使用
+}
+
+// Active 是否为活动文件（未轮转的文件）
+func (m Member) Active() bool {
+	return m.Name == m.Base
+}
+
+// Matcher 按规则识别轮转文件
+type Matcher struct {
+	rules []Rule
+}
+
+// NewMatcher 创建匹配器，按顺序尝试各规则
+func NewMatcher(rules []Rule) *Matcher {
+	return &Matcher{rules: rules}
+}
+
+// Parse 解析文件名，返回其所属文件组的信息；不是轮转文件时返回的 Member 为活动文件
+func (m *Matcher) Parse(name string, modTime time.Time) Member {
+	member := Member{Name: name, Base: name, Index: -1, ModTime: modTime}
+	if m == nil {
+		return member
+	}
+
+	stem := name
+	for _, c := range compressions {
+		if trimmed, ok := strings.CutSuffix(name, "."+c.ext); ok && trimmed != "" {
+			stem = trimmed
+			member.Compression = c.ext
+			break
+		}
+	}
+
+	for _, rule := range m.rules {
+		match := rule.Regex.FindStringSubmatch(stem)
+		if match == nil {
+			continue
+		}
+		base := match[rule.Regex.SubexpIndex(groupBase)]
+		if i := rule.Regex.SubexpIndex(groupExt); i >= 0 {
+			base += match[i]
+		}
+		if base == "" || base == name {
+			continue
+		}
+
+		parsed := member
+		parsed.Base = base
+		parsed.Rule = rule.Name
+		ok := false
+		if i := rule.Regex.SubexpIndex(groupIndex); i >= 0 && match[i] != "" {
+			if n, err := strconv.Atoi(match[i]); err == nil {
+				parsed.Index = n
+				ok = true
+			}
+		}
+		if i := rule.Regex.SubexpIndex(groupDate); i >= 0 && match[i] != "" {
+			if t, err := parseDate(match[i], rule.DateLayout); err == nil {
+				parsed.Date = t
+				ok = true
+			}
+		}
+		if ok {
+			return parsed
+		}
+	}
+
+	// 只有压缩后缀的文件（app.log.gz）视为该文件最近一次轮转的结果
+	if member.Compression != "" {
+		member.Base = stem
+		member.Rule = "compressed"
+	}
+	return member
+}
+
+// parseDate 解析日期，未指定格式时去掉分隔符后按长度选择格式
+func parseDate(value, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.ParseInLocation(layout, value, time.Local)
+	}
+	digits := strings.NewReplacer("-", "", "_", "", "T", "").Replace(value)
+	switch len(digits) {
+	case 8:
+		return time.ParseInLocation(defaultDateLayout, digits, time.Local)
+	case 10:
+		return time.ParseInLocation(defaultDateLayout+"15", digits, time.Local)
+	}
+	return time.Time{}, fmt.Errorf("无法识别的日期: %s", value)
+}
+
+// Sort 将同一文件组的成员按时间先后排序（最旧的在前），活动文件始终在最后
+// 都有日期时按日期，都有序号时按序号（越大越旧），否则按修改时间
+func Sort(members []Member) {
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if a.Active() != b.Active() {
+			return b.Active()
+		}
+		if !a.Date.IsZero() && !b.Date.IsZero() && !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.Index >= 0 && b.Index >= 0 && a.Index != b.Index {
+			return a.Index > b.Index
+		}
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.Before(b.ModTime)
+		}
+		return a.Name < b.Name
+	})
+}
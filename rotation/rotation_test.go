@@ -0,0 +1,136 @@
+package rotation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.Local)
+}
+
+func TestParse(t *testing.T) {
+	m := NewMatcher(DefaultRules())
+	tests := []struct {
+		name        string
+		base        string
+		rule        string
+		index       int
+		date        time.Time
+		compression string
+	}{
+		{"app.log", "app.log", "", -1, time.Time{}, ""},
+		{"app.log.1", "app.log", "numeric", 1, time.Time{}, ""},
+		{"app.log.12.gz", "app.log", "numeric", 12, time.Time{}, "gz"},
+		{"app.log-20261017", "app.log", "date", -1, date(2026, 10, 17, 0), ""},
+		{"app.log.2026-10-17", "app.log", "date", -1, date(2026, 10, 17, 0), ""},
+		{"app.log-2026101708.zst", "app.log", "date", -1, date(2026, 10, 17, 8), "zst"},
+		{"app-2026-10-17.log", "app.log", "date_before_ext", -1, date(2026, 10, 17, 0), ""},
+		{"app_20261017.log.xz", "app.log", "date_before_ext", -1, date(2026, 10, 17, 0), "xz"},
+		{"app.log.gz", "app.log", "compressed", -1, time.Time{}, "gz"},
+		// 无法解析的日期不视为轮转文件
+		{"app.log-20261399", "app.log-20261399", "", -1, time.Time{}, ""},
+		{"README", "README", "", -1, time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		got := m.Parse(tt.name, time.Time{})
+		if got.Base != tt.base || got.Rule != tt.rule || got.Index != tt.index || !got.Date.Equal(tt.date) || got.Compression != tt.compression {
+			t.Errorf("Parse(%q) = %+v", tt.name, got)
+		}
+		if got.Active() != (tt.rule == "") {
+			t.Errorf("Parse(%q).Active() = %v", tt.name, got.Active())
+		}
+	}
+}
+
+func TestParseNilMatcher(t *testing.T) {
+	var m *Matcher
+	if got := m.Parse("app.log.1", time.Time{}); !got.Active() {
+		t.Fatalf("nil matcher parsed %+v", got)
+	}
+}
+
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr string
+	}{
+		{`^(?P<base>.+)\.bak(?P<index>\d+)$`, ""},
+		{`^(?P<base>.+)@(?P<date>\d{8})$`, ""},
+		{`^(.+)\.(?P<index>\d+)$`, "缺少命名分组 base"},
+		{`^(?P<base>.+)\.old$`, "缺少命名分组 index 或 date"},
+		{`^(?P<base>.+`, "正则错误"},
+	}
+	for _, tt := range tests {
+		_, err := CompileRule("custom", tt.pattern, "")
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("CompileRule(%q) error: %v", tt.pattern, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("CompileRule(%q) error = %v, want %q", tt.pattern, err, tt.wantErr)
+		}
+	}
+
+	rule, err := CompileRule("custom", `^(?P<base>.+)@(?P<date>\d{4}/\d{2}/\d{2})$`, "2006/01/02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := NewMatcher([]Rule{rule}).Parse("app.log@2026/10/17", time.Time{})
+	if got.Base != "app.log" || !got.Date.Equal(date(2026, 10, 17, 0)) {
+		t.Fatalf("custom rule parsed %+v", got)
+	}
+}
+
+func TestSort(t *testing.T) {
+	m := NewMatcher(DefaultRules())
+	old := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		files []string
+		mod   map[string]time.Time
+		want  []string
+	}{
+		{
+			name:  "index",
+			files: []string{"app.log", "app.log.1", "app.log.10.gz", "app.log.2"},
+			want:  []string{"app.log.10.gz", "app.log.2", "app.log.1", "app.log"},
+		},
+		{
+			name:  "date",
+			files: []string{"app.log-20261018", "app.log", "app.log-20261016.gz", "app.log-20261017"},
+			want:  []string{"app.log-20261016.gz", "app.log-20261017", "app.log-20261018", "app.log"},
+		},
+		{
+			name:  "date without active",
+			files: []string{"app-2026-10-19.log", "app-2026-10-17.log.gz", "app-2026-10-18.log"},
+			want:  []string{"app-2026-10-17.log.gz", "app-2026-10-18.log", "app-2026-10-19.log"},
+		},
+		{
+			// 序号和日期混用时按修改时间
+			name:  "mixed",
+			files: []string{"app.log", "app.log-20261017", "app.log.1"},
+			mod:   map[string]time.Time{"app.log.1": old.Add(time.Hour), "app.log-20261017": old},
+			want:  []string{"app.log-20261017", "app.log.1", "app.log"},
+		},
+		{
+			name:  "compressed only",
+			files: []string{"app.log", "app.log.gz"},
+			want:  []string{"app.log.gz", "app.log"},
+		},
+	}
+	for _, tt := range tests {
+		members := make([]Member, 0, len(tt.files))
+		for _, name := range tt.files {
+			members = append(members, m.Parse(name, tt.mod[name]))
+		}
+		Sort(members)
+		got := make([]string, len(members))
+		for i, member := range members {
+			got[i] = member.Name
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: Sort = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// defaultRelativeAccuracy 分位数估算的默认相对误差
+const defaultRelativeAccuracy = 0.01
+
+// Summary 数值字段的统计摘要
+type Summary struct {
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// Sketch 流式分位数估算器（DDSketch），按对数区间计数，内存占用与数据量无关
+// 估算值的相对误差不超过 relativeAccuracy
+type Sketch struct {
+	gamma    float64
+	logGamma float64
+	positive map[int]int64
+	negative map[int]int64
+	zeros    int64
+	count    int64
+	sum      float64
+	min      float64
+	max      float64
+}
+
+// NewSketch 创建分位数估算器
+func NewSketch() *Sketch {
+	gamma := (1 + defaultRelativeAccuracy) / (1 - defaultRelativeAccuracy)
+	return &Sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		positive: make(map[int]int64),
+		negative: make(map[int]int64),
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+// Add 添加一个数值
+func (s *Sketch) Add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	s.count++
+	s.sum += v
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+
+	switch {
+	case v > 0:
+		s.positive[s.index(v)]++
+	case v < 0:
+		s.negative[s.index(-v)]++
+	default:
+		s.zeros++
+	}
+}
+
+// index 计算数值所在的对数区间
+func (s *Sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value 返回区间的代表值
+func (s *Sketch) value(index int) float64 {
+	return 2 * math.Pow(s.gamma, float64(index)) / (s.gamma + 1)
+}
+
+// Count 返回数值个数
+func (s *Sketch) Count() int64 {
+	return s.count
+}
+
+// Sum 返回数值之和
+func (s *Sketch) Sum() float64 {
+	return s.sum
+}
+
+// Min 返回最小值，没有数据时返回0
+func (s *Sketch) Min() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.min
+}
+
+// Max 返回最大值，没有数据时返回0
+func (s *Sketch) Max() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.max
+}
+
+// Avg 返回平均值
+func (s *Sketch) Avg() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// Quantile 估算分位数，q 取值 0-1
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return s.min
+	}
+	if q >= 1 {
+		return s.max
+	}
+
+	rank := int64(q * float64(s.count-1))
+	var seen int64
+
+	// 负数按绝对值从大到小
+	negIdx := sortedKeys(s.negative)
+	for i := len(negIdx) - 1; i >= 0; i-- {
+		seen += s.negative[negIdx[i]]
+		if seen > rank {
+			return s.clamp(-s.value(negIdx[i]))
+		}
+	}
+
+	seen += s.zeros
+	if seen > rank {
+		return 0
+	}
+
+	for _, idx := range sortedKeys(s.positive) {
+		seen += s.positive[idx]
+		if seen > rank {
+			return s.clamp(s.value(idx))
+		}
+	}
+	return s.max
+}
+
+// clamp 将估算值限制在实际最小值和最大值之间
+func (s *Sketch) clamp(v float64) float64 {
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+// Summary 返回统计摘要
+func (s *Sketch) Summary() Summary {
+	return Summary{
+		Count: s.count,
+		Sum:   s.sum,
+		Min:   s.Min(),
+		Max:   s.Max(),
+		Avg:   s.Avg(),
+		P50:   s.Quantile(0.50),
+		P95:   s.Quantile(0.95),
+		P99:   s.Quantile(0.99),
+	}
+}
+
+// sortedKeys 返回排序后的区间索引
+func sortedKeys(m map[int]int64) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
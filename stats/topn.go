@@ -0,0 +1,71 @@
+package stats
+
+import "sort"
+
+// OtherValue 超出不同值数量上限后，新出现的值计入此项
+const OtherValue = "(other)"
+
+// defaultMaxDistinct 默认最多跟踪的不同值数量
+const defaultMaxDistinct = 100000
+
+// ValueCount 值及其出现次数
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Counter 按值计数，用于统计TopN
+// 不同值的数量超过上限后，新值统一计入 OtherValue，避免高基数字段占用过多内存
+type Counter struct {
+	counts      map[string]int64
+	maxDistinct int
+	total       int64
+}
+
+// NewCounter 创建计数器，maxDistinct 小于等于0时使用默认上限
+func NewCounter(maxDistinct int) *Counter {
+	if maxDistinct <= 0 {
+		maxDistinct = defaultMaxDistinct
+	}
+	return &Counter{
+		counts:      make(map[string]int64),
+		maxDistinct: maxDistinct,
+	}
+}
+
+// Add 计数一次
+func (c *Counter) Add(value string) {
+	c.total++
+	if _, ok := c.counts[value]; !ok && len(c.counts) >= c.maxDistinct {
+		value = OtherValue
+	}
+	c.counts[value]++
+}
+
+// Total 返回总计数
+func (c *Counter) Total() int64 {
+	return c.total
+}
+
+// Distinct 返回不同值的数量
+func (c *Counter) Distinct() int {
+	return len(c.counts)
+}
+
+// Top 返回出现次数最多的前n个值，n小于等于0时返回全部
+func (c *Counter) Top(n int) []ValueCount {
+	values := make([]ValueCount, 0, len(c.counts))
+	for value, count := range c.counts {
+		values = append(values, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if n > 0 && len(values) > n {
+		values = values[:n]
+	}
+	return values
+}